@@ -0,0 +1,152 @@
+package can
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// AdminPermission is the permission guarding the endpoints of
+// AdminHandler: read to list and view roles, create to grant and delete
+// to revoke.
+const AdminPermission = "can_admin"
+
+// AdminHandler returns a handler to manage the roles of store at
+// runtime, guarded by a Guard resolving the role of every request with
+// store (see Guard.AdminHandler).
+func AdminHandler(store *Store) http.Handler {
+	return (&Guard{Store: store}).AdminHandler(store)
+}
+
+// AdminHandler returns a handler to manage the roles of store at
+// runtime, so access can be adjusted without a deploy. Every request is
+// authorized by g against AdminPermission, with the ability of its
+// method. The endpoints are:
+//
+//	GET    /roles                              names of the roles and the revision
+//	GET    /roles/{role}                       the role, in the format of OpenFile
+//	GET    /effective?role=a&role=b            the merged role of a subject with these roles
+//	POST   /roles/{role}/{permission...}       grant the abilities of {"abilities": [...]}
+//	DELETE /roles/{role}/{permission...}       revoke ?ability=..., or the whole permission
+//
+// Mount it under a prefix with http.StripPrefix:
+//
+//	mux.Handle("/admin/", http.StripPrefix("/admin", can.AdminHandler(store)))
+func (g *Guard) AdminHandler(store *Store) http.Handler {
+	a := &admin{store: store}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /roles", a.roles)
+	mux.HandleFunc("GET /roles/{role}", a.role)
+	mux.HandleFunc("GET /effective", a.effective)
+	mux.HandleFunc("POST /roles/{role}/{permission...}", a.grant)
+	mux.HandleFunc("DELETE /roles/{role}/{permission...}", a.revoke)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !g.Check(w, r, AdminPermission, BuildFromMethod(r.Method)) {
+			return
+		}
+
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// admin implements the endpoints of AdminHandler.
+type admin struct {
+	store *Store
+}
+
+func (a *admin) roles(w http.ResponseWriter, r *http.Request) {
+	roles := a.store.Roles()
+	names := make([]string, 0, len(roles))
+	for name := range roles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	writeJSON(w, struct {
+		Roles    []string `json:"roles"`
+		Revision string   `json:"revision"`
+	}{names, a.store.Revision()})
+}
+
+func (a *admin) role(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("role")
+	role, ok := a.store.Role(name)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, Roles{name: role}.Disk()[name])
+}
+
+func (a *admin) effective(w http.ResponseWriter, r *http.Request) {
+	role, ok := a.store.SubjectRole(Subject{Roles: r.URL.Query()["role"]})
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, Roles{"": role}.Disk()[""])
+}
+
+func (a *admin) grant(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Abilities []string `json:"abilities"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || len(body.Abilities) == 0 {
+		http.Error(w, "expected a json body with abilities", http.StatusBadRequest)
+		return
+	}
+
+	abilities, ok := parseAbilities(body.Abilities)
+	if !ok {
+		http.Error(w, "unknown ability in "+strings.Join(body.Abilities, ", "), http.StatusBadRequest)
+		return
+	}
+
+	if err := a.store.Grant(r.Context(), r.PathValue("role"), r.PathValue("permission"), abilities...); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *admin) revoke(w http.ResponseWriter, r *http.Request) {
+	names := r.URL.Query()["ability"]
+	abilities, ok := parseAbilities(names)
+	if !ok {
+		http.Error(w, "unknown ability in "+strings.Join(names, ", "), http.StatusBadRequest)
+		return
+	}
+
+	if err := a.store.Revoke(r.Context(), r.PathValue("role"), r.PathValue("permission"), abilities...); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseAbilities converts ability names and custom ability numbers,
+// reporting false for unknown ones.
+func parseAbilities(names []string) ([]Ability, bool) {
+	abilities := make([]Ability, 0, len(names))
+	for _, name := range names {
+		a := parseAbility(name)
+		if a == None {
+			return nil, false
+		}
+		abilities = append(abilities, a)
+	}
+
+	return abilities, true
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
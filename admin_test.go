@@ -0,0 +1,92 @@
+package can
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAdminHandler(t *testing.T) {
+	store := NewStore(Roles{
+		"ops":  NewRole().Allow(AdminPermission, All).Build(),
+		"user": NewRole().Allow("users", Read).Allow(AdminPermission, Read).Build(),
+	})
+	h := AdminHandler(store)
+
+	do := func(roles []string, method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if roles != nil {
+			req = req.WithContext(WithSubject(req.Context(), Subject{ID: "u1", Roles: roles}))
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+	ops, user := []string{"ops"}, []string{"user"}
+
+	w := do(user, http.MethodGet, "/roles", "")
+	var list struct {
+		Roles    []string `json:"roles"`
+		Revision string   `json:"revision"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil || strings.Join(list.Roles, ",") != "ops,user" || list.Revision != store.Revision() {
+		t.Fatalf("unexpected role list %+v, %v", list, err)
+	}
+
+	if w := do(nil, http.MethodGet, "/roles", ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected anonymous requests to be refused, got %d", w.Code)
+	}
+	if w := do(user, http.MethodPost, "/roles/user/books", `{"abilities":["read"]}`); w.Code != http.StatusForbidden {
+		t.Fatalf("expected user to be denied grants, got %d", w.Code)
+	}
+
+	if w := do(ops, http.MethodPost, "/roles/user/projects/7", `{"abilities":["read","update"]}`); w.Code != http.StatusNoContent {
+		t.Fatalf("expected the grant to succeed, got %d: %s", w.Code, w.Body)
+	}
+	if w := do(ops, http.MethodPost, "/roles/user/books", `{"abilities":["fly"]}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected unknown abilities to be rejected, got %d", w.Code)
+	}
+	if !store.Can(context.Background(), "user", "projects/7", Update, func() bool { return true }) {
+		t.Fatal("expected the granted ability to be applied")
+	}
+	if w := do(ops, http.MethodPost, "/roles/user/reports", `{"abilities":["256"]}`); w.Code != http.StatusNoContent {
+		t.Fatalf("expected custom abilities to be granted by number, got %d: %s", w.Code, w.Body)
+	}
+	if w := do(ops, http.MethodDelete, "/roles/user/reports?ability=256", ""); w.Code != http.StatusNoContent || store.Can(context.Background(), "user", "reports", Ability(256), func() bool { return true }) {
+		t.Fatalf("expected custom abilities to be revoked by number, got %d", w.Code)
+	}
+
+	if w := do(ops, http.MethodDelete, "/roles/user/projects/7?ability=update", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("expected the revoke to succeed, got %d", w.Code)
+	}
+	if store.Can(context.Background(), "user", "projects/7", Update, func() bool { return true }) {
+		t.Fatal("expected the revoked ability to be removed")
+	}
+
+	w = do(user, http.MethodGet, "/roles/user", "")
+	var role DiskRole
+	if err := json.NewDecoder(w.Body).Decode(&role); err != nil || len(role["projects/7"].Abilities) != 1 {
+		t.Fatalf("unexpected role %+v, %v", role, err)
+	}
+
+	w = do(user, http.MethodGet, "/effective?role=user&role=ops", "")
+	if err := json.NewDecoder(w.Body).Decode(&role); err != nil || len(role[AdminPermission].Abilities) == 0 || len(role["users"].Abilities) == 0 {
+		t.Fatalf("unexpected effective role %+v, %v", role, err)
+	}
+
+	if w := do(user, http.MethodGet, "/roles/nobody", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("expected unknown roles to be 404, got %d", w.Code)
+	}
+
+	defer func(e Effect) { Unlisted = e }(Unlisted)
+	Unlisted = Allow
+	g := &Guard{Store: store, DefaultRole: NewRole().Allow("users", Read).Build()}
+	w = httptest.NewRecorder()
+	g.AdminHandler(store).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/roles", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected the admin permission to need an explicit grant, got %d", w.Code)
+	}
+}
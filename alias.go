@@ -0,0 +1,136 @@
+package can
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// AliasesKey is the top level key of a role file mapping old permission
+// names to the ones roles grant them under, the config form of Aliases:
+//
+//	aliases:
+//	  members: users
+//
+// OpenFile and Decode skip the key, load it with OpenAliases and set
+// Aliases to the result. The key is reserved and can't be used as a
+// role name.
+const AliasesKey = "aliases"
+
+// ErrInvalidAlias is returned for aliases that would never apply, such as
+// an alias of an alias.
+var ErrInvalidAlias = errors.New("can: invalid alias")
+
+// Aliases maps permissions to the permission roles grant them under, such
+// as accounts to customers after the accounts routes were renamed. When a
+// role has no entry for a permission, Can retries with its alias, so
+// routes can be renamed before the policy of every environment is. An
+// alias also applies to the leading levels of a hierarchical
+// permission and the leading segments of a route permission:
+// accounts/7 is looked up as customers/7 and accounts_7 as customers_7.
+// Aliases are resolved once, an alias of an alias is not followed, and
+// only when the role has no entry of its own: a role granting accounts
+// isn't given the customers grants. Like HierarchySeparator, set it
+// before checks are made, for example from a role file with OpenAliases.
+var Aliases map[string]string
+
+// OnAlias is called every time Can or CanField only finds a permission
+// through Aliases, may be nil. Use it to log a warning and find the
+// clients still using the old name. Checks answered by a Cache are not
+// reported.
+var OnAlias func(alias, permission string)
+
+// unalias returns the alias target of permission, for the longest
+// aliased prefix of its levels or segments.
+func unalias(permission string) (string, bool) {
+	if len(Aliases) == 0 {
+		return "", false
+	}
+	if target, ok := Aliases[permission]; ok {
+		return target, true
+	}
+
+	for _, sep := range []string{HierarchySeparator, Separator} {
+		if sep == "" {
+			continue
+		}
+		for i := strings.LastIndex(permission, sep); i > 0; i = strings.LastIndex(permission[:i], sep) {
+			if target, ok := Aliases[permission[:i]]; ok {
+				return target + permission[i:], true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// lookupAlias looks the alias target of permission up in role, reporting
+// the use of the alias to trace and OnAlias.
+func lookupAlias(role Role, permission string, trace *Explanation) (Permission, string, bool) {
+	target, ok := unalias(permission)
+	if !ok {
+		return Permission{}, "", false
+	}
+
+	p, key, ok := lookupKey(role, target)
+	if !ok {
+		return Permission{}, "", false
+	}
+
+	if trace != nil {
+		trace.step("permission %q is an alias of %q", permission, target)
+	}
+	if OnAlias != nil {
+		OnAlias(permission, target)
+	}
+
+	return p, key, true
+}
+
+// OpenAliases reads the aliases of a yaml role file. The file's roles and
+// includes are ignored.
+// filename - yaml encoded file for parsing
+//
+// returns - the aliases, empty if the file has none, and an error
+func OpenAliases(filename string) (map[string]string, error) {
+	f, err := os.OpenFile(filename, os.O_RDONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	a, err := DecodeAliases(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	return a, nil
+}
+
+// DecodeAliases reads the aliases of yaml encoded roles from r. Aliases
+// of themselves and aliases of other aliases, which are never followed,
+// are reported as ErrInvalidAlias.
+func DecodeAliases(r io.Reader) (map[string]string, error) {
+	a := make(map[string]string)
+	if err := decodeKey(r, AliasesKey, &a); err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	for _, alias := range sortedKeys(a) {
+		target := a[alias]
+		switch _, chained := a[target]; {
+		case target == "" || target == alias:
+			errs = append(errs, fmt.Errorf("%w: %q is an alias of %q", ErrInvalidAlias, alias, target))
+		case chained:
+			errs = append(errs, fmt.Errorf("%w: %q is an alias of the alias %q, aliases are not chained", ErrInvalidAlias, alias, target))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return a, nil
+}
@@ -0,0 +1,125 @@
+package can
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAliases(t *testing.T) {
+	defer func(a map[string]string, fn func(string, string)) { Aliases, OnAlias = a, fn }(Aliases, OnAlias)
+
+	role := NewRole().
+		Allow("customers", Read).
+		Allow("customers/*/invoices", All).
+		Allow("accounts_legacy", Delete).
+		Build()
+
+	var warned []string
+	Aliases = map[string]string{"accounts": "customers", "accounts_legacy": "customers", "clients": "accounts"}
+	OnAlias = func(alias, permission string) { warned = append(warned, alias+"->"+permission) }
+
+	ctx := context.Background()
+	yes := func() bool { return true }
+
+	tests := []struct {
+		permission string
+		ability    Ability
+		want       bool
+	}{
+		{"accounts", Read, true},
+		{"accounts", Delete, false},
+		{"accounts/7/invoices", Delete, true},
+		{"accounts_legacy", Delete, true}, // the role's own entry wins
+		{"accounts_legacy", Read, false},
+		{"clients", Read, false}, // aliases are not chained
+	}
+
+	for _, tt := range tests {
+		if got := Can(ctx, role, tt.permission, tt.ability, yes); got != tt.want {
+			t.Fatalf("Can(%q, %s) = %v, want %v", tt.permission, tt.ability, got, tt.want)
+		}
+	}
+
+	want := []string{"accounts->customers", "accounts->customers", "accounts/7/invoices->customers/7/invoices"}
+	if strings.Join(warned, " ") != strings.Join(want, " ") {
+		t.Fatalf("expected warnings %v, got %v", want, warned)
+	}
+
+	e := Explain(ctx, role, "accounts", Read, yes)
+	if !e.Allowed || e.Permission != "customers" || !strings.Contains(e.String(), `"accounts" is an alias of "customers"`) {
+		t.Fatalf("expected the alias in the explanation, got %+v", e)
+	}
+
+	if !CanField(ctx, role, "accounts", Read, "name") {
+		t.Fatal("expected CanField to follow aliases")
+	}
+
+	// the alias target doesn't widen a grant the role has under the old name
+	Aliases = map[string]string{"members": "users"}
+	role = NewRole().Allow("members", Read).Allow("users", All).Build()
+	req := httptest.NewRequest(http.MethodDelete, "/members", nil)
+	if Can(ctx, role, PermissionFromPath(req), BuildFromMethod(req.Method), yes) {
+		t.Fatal("expected the role's own members entry to decide")
+	}
+	if !Can(ctx, role, PermissionFromPath(req), Read, yes) {
+		t.Fatal("expected the role's own members entry to allow reads")
+	}
+}
+
+func TestOpenAliases(t *testing.T) {
+	defer func(a map[string]string) { Aliases = a }(Aliases)
+
+	r, err := OpenFile("testdata/aliases.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := r[AliasesKey]; ok || len(r) != 1 {
+		t.Fatalf("expected aliases to be skipped, got %v", r)
+	}
+
+	if Aliases, err = OpenAliases("testdata/aliases.yml"); err != nil {
+		t.Fatal(err)
+	}
+	if Aliases["members"] != "users" {
+		t.Fatalf("expected the aliases of the file, got %v", Aliases)
+	}
+
+	for path, want := range map[string]bool{
+		"/members":         true,
+		"/v1/members":      true,
+		"/accounts/legacy": true,
+		"/accounts":        false,
+	} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if got := Can(context.Background(), r["user"], PermissionFromPath(req), Read, func() bool { return true }); got != want {
+			t.Fatalf("expected %s to be %v through its alias, got %v", path, want, got)
+		}
+	}
+	if got := PermissionFromPath(httptest.NewRequest(http.MethodGet, "/members/42", nil)); got != "members_42" {
+		t.Fatalf("expected the permission of the request to keep its name, got %q", got)
+	}
+	for permission, want := range map[string]string{
+		"members_42":         "users_42",
+		"accounts_legacy_42": "customers_42",
+		"members/7":          "users/7",
+	} {
+		if got, _ := unalias(permission); got != want {
+			t.Fatalf("expected %s to be an alias of %q, got %q", permission, want, got)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/members", nil)
+	if !(&Guard{DefaultRole: r["user"]}).Check(httptest.NewRecorder(), req, PermissionFromPath(req), Read) {
+		t.Fatal("expected the old route to keep working")
+	}
+
+	for _, doc := range []string{"aliases:\n  a: a\n", "aliases:\n  a: b\n  b: c\n"} {
+		if _, err := DecodeAliases(strings.NewReader(doc)); !errors.Is(err, ErrInvalidAlias) {
+			t.Fatalf("expected %q to be invalid, got %v", doc, err)
+		}
+	}
+}
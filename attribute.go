@@ -0,0 +1,119 @@
+package can
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"reflect"
+)
+
+// AttributeExtractor adds attributes of a request, such as its tenant
+// header or the owner ID in its path, to the attributes of its checks
+// (see ExtractAttributes). Conditions read them with AttributesFrom.
+type AttributeExtractor interface {
+	Extract(r *http.Request, a Attributes)
+}
+
+// AttributeExtractorFunc is an adapter to allow the use of ordinary
+// functions as AttributeExtractors.
+type AttributeExtractorFunc func(r *http.Request, a Attributes)
+
+// Extract implements the AttributeExtractor interface.
+func (f AttributeExtractorFunc) Extract(r *http.Request, a Attributes) {
+	f(r, a)
+}
+
+// ExtractAttributes runs the extractors on every request and stores the
+// attributes in the request context, ready for a Guard, its conditions
+// and compare functions. Attributes already in the request context take
+// precedence, extractors run in order and a later one overwrites the
+// attributes of an earlier one.
+//
+// extractors - the extractors to run
+//
+// returns - a middleware
+func ExtractAttributes(extractors ...AttributeExtractor) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			existing := AttributesFrom(r.Context())
+			a := make(Attributes, len(existing)+len(extractors))
+			for _, e := range extractors {
+				e.Extract(r, a)
+			}
+			for k, v := range existing {
+				a[k] = v
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithAttributes(r.Context(), a)))
+		})
+	}
+}
+
+// HeaderAttribute extracts a request header, e.g. the tenant of
+// X-Tenant-ID. Requests without the header don't get the attribute.
+func HeaderAttribute(name, header string) AttributeExtractor {
+	return AttributeExtractorFunc(func(r *http.Request, a Attributes) {
+		if v := r.Header.Get(header); v != "" {
+			a[name] = v
+		}
+	})
+}
+
+// PathValueAttribute extracts the value of a ServeMux wildcard, e.g. the
+// owner ID of /users/{id}/exports. Path values are only set once a
+// request is routed, run it in the middleware of a handler of the mux.
+func PathValueAttribute(name, wildcard string) AttributeExtractor {
+	return AttributeExtractorFunc(func(r *http.Request, a Attributes) {
+		if v := r.PathValue(wildcard); v != "" {
+			a[name] = v
+		}
+	})
+}
+
+// RemoteIPAttribute extracts the IP address of the client from the
+// remote address of the request. Behind a proxy, run it after a
+// middleware setting RemoteAddr from a trusted forwarding header.
+func RemoteIPAttribute(name string) AttributeExtractor {
+	return AttributeExtractorFunc(func(r *http.Request, a Attributes) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		if ip := net.ParseIP(host); ip != nil {
+			a[name] = ip.String()
+		}
+	})
+}
+
+// UserAgentAttribute extracts the User-Agent of the request.
+func UserAgentAttribute(name string) AttributeExtractor {
+	return HeaderAttribute(name, "User-Agent")
+}
+
+// CompareAttribute returns a compare function that is satisfied when the
+// attribute name in ctx equals value, for example an owner ID extracted
+// from the path and the ID of the user:
+//
+//	can.Can(ctx, role, "exports", can.Read, can.CompareAttribute(ctx, "owner", user.ID))
+func CompareAttribute(ctx context.Context, name string, value any) func() bool {
+	return func() bool {
+		v, ok := AttributesFrom(ctx)[name]
+		return ok && reflect.DeepEqual(v, value)
+	}
+}
+
+// SubjectAttribute returns a Comparator allowing checks whose attribute
+// name is the ID of the subject in ctx (see WithSubject), the condition
+// of owner only permissions:
+//
+//	can.RegisterComparator("owner", can.SubjectAttribute("owner"))
+func SubjectAttribute(name string) Comparator {
+	return func(ctx context.Context) bool {
+		sub, ok := SubjectFrom(ctx)
+		if !ok || sub.ID == "" {
+			return false
+		}
+
+		return CompareAttribute(ctx, name, sub.ID)()
+	}
+}
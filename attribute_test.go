@@ -0,0 +1,60 @@
+package can
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestExtractAttributes(t *testing.T) {
+	var got Attributes
+	mux := http.NewServeMux()
+	extract := ExtractAttributes(
+		HeaderAttribute("tenant", "X-Tenant-ID"),
+		PathValueAttribute("owner", "id"),
+		RemoteIPAttribute("ip"),
+		UserAgentAttribute("agent"),
+	)
+	mux.Handle("/users/{id}/exports", extract(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = AttributesFrom(r.Context())
+	})))
+
+	req := httptest.NewRequest(http.MethodGet, "/users/42/exports", nil)
+	req.RemoteAddr = "[::1]:4242"
+	req.Header.Set("X-Tenant-ID", "acme")
+	req.Header.Set("User-Agent", "curl")
+	req = req.WithContext(WithAttributes(req.Context(), Attributes{"agent": "set upstream"}))
+	mux.ServeHTTP(httptest.NewRecorder(), req)
+
+	want := Attributes{"tenant": "acme", "owner": "42", "ip": "::1", "agent": "set upstream"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+}
+
+func TestSubjectAttribute(t *testing.T) {
+	defer RegisterComparator("owner", nil)
+	RegisterComparator("owner", SubjectAttribute("owner"))
+
+	role := Role{"exports": {Abilities: map[Ability]struct{}{All: {}}, Conditions: []string{"owner"}}}
+	ctx := WithAttributes(WithSubject(context.Background(), Subject{ID: "42"}), Attributes{"owner": "42"})
+	if !Can(ctx, role, "exports", Read, nil) {
+		t.Fatal("expected the owner to be allowed")
+	}
+	if Can(WithSubject(ctx, Subject{ID: "7"}), role, "exports", Read, nil) {
+		t.Fatal("expected another subject to be denied")
+	}
+	if Can(WithAttributes(ctx, nil), role, "exports", Read, nil) {
+		t.Fatal("expected a check without the attribute to be denied")
+	}
+
+	if !CompareAttribute(ctx, "owner", "42")() || CompareAttribute(ctx, "owner", 42)() {
+		t.Fatal("expected CompareAttribute to compare the attribute")
+	}
+}
@@ -0,0 +1,81 @@
+package can
+
+import "context"
+
+// Authorizer is implemented by anything that can decide whether a role
+// may use an ability on a permission. The Can function is the default,
+// local implementation (see Local).
+type Authorizer interface {
+	Authorize(ctx context.Context, role Role, permission string, ability Ability, compare func() bool) bool
+}
+
+// AuthorizerFunc is an adapter to allow the use of ordinary functions as
+// an Authorizer.
+type AuthorizerFunc func(ctx context.Context, role Role, permission string, ability Ability, compare func() bool) bool
+
+// Authorize calls f(ctx, role, permission, ability, compare).
+func (f AuthorizerFunc) Authorize(ctx context.Context, role Role, permission string, ability Ability, compare func() bool) bool {
+	return f(ctx, role, permission, ability, compare)
+}
+
+// Local is an Authorizer that evaluates roles in process with Can.
+var Local Authorizer = local{}
+
+// local is the Authorizer of Local.
+type local struct{}
+
+// Authorize implements the Authorizer interface.
+func (local) Authorize(ctx context.Context, role Role, permission string, ability Ability, compare func() bool) bool {
+	return Can(ctx, role, permission, ability, compare)
+}
+
+// AuthorizeOutcome implements the OutcomeAuthorizer interface.
+func (local) AuthorizeOutcome(ctx context.Context, role Role, permission string, ability Ability, compare func() bool) Outcome {
+	return evaluate(ctx, role, permission, ability, compare, nil)
+}
+
+// Authorize implements the Authorizer interface, so a CanFn, including
+// a Chain, can be used wherever an Authorizer is expected.
+func (fn CanFn) Authorize(ctx context.Context, role Role, permission string, ability Ability, compare func() bool) bool {
+	return fn(ctx, &role, compare, permission, ability)
+}
+
+// DefaultCan is the CanFn that evaluates roles locally with Can.
+func DefaultCan(ctx context.Context, role *Role, compare func() bool, permission string, ability Ability) bool {
+	if role == nil {
+		return false
+	}
+
+	return Can(ctx, *role, permission, ability, compare)
+}
+
+// Chain combines several CanFn into a pipeline, for example local RBAC
+// followed by a remote ABAC check. The functions are called in order and
+// every one must allow for the chain to allow. The chain short-circuits:
+// it stops at the first function that denies, so later and usually more
+// expensive functions are only called when the earlier ones passed. Once
+// ctx is done, the functions after the first aren't called and a chain
+// the earlier ones allowed is decided with OnUndecided. An empty chain
+// denies.
+//
+// fns - the functions to call in order
+//
+// returns - a CanFn running the pipeline
+func Chain(fns ...CanFn) CanFn {
+	return func(ctx context.Context, role *Role, compare func() bool, permission string, ability Ability) bool {
+		if len(fns) == 0 {
+			return false
+		}
+
+		for i, fn := range fns {
+			if i > 0 && ctx.Err() != nil {
+				return undecided()
+			}
+			if !fn(ctx, role, compare, permission, ability) {
+				return false
+			}
+		}
+
+		return true
+	}
+}
@@ -0,0 +1,49 @@
+package can
+
+import (
+	"context"
+	"testing"
+)
+
+func TestChain(t *testing.T) {
+	r, err := OpenFile("testdata/rbac.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var remoteCalls int
+	remote := func(ctx context.Context, role *Role, compare func() bool, permission string, ability Ability) bool {
+		remoteCalls++
+		return permission != "users"
+	}
+
+	chain := Chain(DefaultCan, remote)
+	ctx := context.Background()
+
+	if chain.Authorize(ctx, r["user"], "users", Create, nil) {
+		t.Fatal("expected local denial")
+	}
+	if remoteCalls != 0 {
+		t.Fatal("expected chain to short-circuit on local denial")
+	}
+
+	if chain.Authorize(ctx, r["admin"], "users", Create, nil) {
+		t.Fatal("expected remote denial")
+	}
+	if !chain.Authorize(ctx, r["admin"], "books", Create, nil) {
+		t.Fatal("expected chain to allow")
+	}
+	if remoteCalls != 2 {
+		t.Fatalf("expected 2 remote calls, got %d", remoteCalls)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if chain.Authorize(canceled, r["admin"], "books", Create, nil) {
+		t.Fatal("expected canceled ctx to deny")
+	}
+
+	if Chain().Authorize(ctx, r["admin"], "books", Create, nil) {
+		t.Fatal("expected empty chain to deny")
+	}
+}
@@ -0,0 +1,130 @@
+package can
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Backoff slows down subjects that are repeatedly denied the same
+// permission, which makes enumerating permissions expensive without a
+// separate WAF rule. Once a subject collects Threshold denials within
+// Window, every further denial is answered with a Retry-After hint that
+// doubles from Delay up to Max, and is optionally delayed as well.
+type Backoff struct {
+	// Key identifies the subject of a request. Defaults to the remote address.
+	Key func(r *http.Request) string
+	// Threshold is the number of denials before backoff starts. Defaults to 3.
+	Threshold int
+	// Window is how long denials are remembered. Defaults to 1m.
+	Window time.Duration
+	// Delay is the first backoff delay. Defaults to 1s.
+	Delay time.Duration
+	// Max caps the backoff delay. Defaults to 1m.
+	Max time.Duration
+	// Sleep delays the denial response by the backoff delay, in addition
+	// to the Retry-After header.
+	Sleep bool
+
+	mu        sync.Mutex
+	streaks   map[string]*denyStreak
+	lastSweep time.Time
+}
+
+type denyStreak struct {
+	count int
+	last  time.Time
+}
+
+// deny records a denial of permission for r and returns the delay to
+// apply, or zero if the subject is below the threshold.
+func (b *Backoff) deny(r *http.Request, permission string, now time.Time) time.Duration {
+	key := b.key(r) + "\x00" + permission
+	window := orDuration(b.Window, time.Minute)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.streaks == nil {
+		b.streaks = make(map[string]*denyStreak)
+	}
+	b.sweep(now, window)
+
+	s, ok := b.streaks[key]
+	if !ok || now.Sub(s.last) > window {
+		s = &denyStreak{}
+		b.streaks[key] = s
+	}
+	s.count++
+	s.last = now
+
+	threshold := b.Threshold
+	if threshold <= 0 {
+		threshold = 3
+	}
+	if s.count <= threshold {
+		return 0
+	}
+
+	delay, max := orDuration(b.Delay, time.Second), orDuration(b.Max, time.Minute)
+	for i := threshold + 1; i < s.count && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+
+	return delay
+}
+
+// apply sets the Retry-After header and sleeps if configured.
+func (b *Backoff) apply(w http.ResponseWriter, r *http.Request, delay time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int((delay+time.Second-1)/time.Second)))
+	if !b.Sleep {
+		return
+	}
+
+	select {
+	case <-After(delay):
+	case <-r.Context().Done():
+	}
+}
+
+// sweep drops expired streaks at most once per window.
+func (b *Backoff) sweep(now time.Time, window time.Duration) {
+	if now.Sub(b.lastSweep) < window {
+		return
+	}
+	b.lastSweep = now
+
+	for k, s := range b.streaks {
+		if now.Sub(s.last) > window {
+			delete(b.streaks, k)
+		}
+	}
+}
+
+// key returns the subject key of r.
+func (b *Backoff) key(r *http.Request) string {
+	if b.Key != nil {
+		return b.Key(r)
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// orDuration returns d, or def if d isn't positive.
+func orDuration(d, def time.Duration) time.Duration {
+	if d <= 0 {
+		return def
+	}
+
+	return d
+}
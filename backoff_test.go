@@ -0,0 +1,39 @@
+package can
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	r, err := OpenFile("testdata/rbac.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := &Guard{Backoff: &Backoff{Threshold: 2, Window: 50 * time.Millisecond, Delay: time.Second, Max: 3 * time.Second}}
+	check := func(ability Ability) string {
+		req := httptest.NewRequest(http.MethodGet, "/users", nil)
+		req = req.WithContext(WithRole(req.Context(), r["user"]))
+		w := httptest.NewRecorder()
+		g.Check(w, req, "users", ability)
+		return w.Header().Get("Retry-After")
+	}
+
+	for i, want := range []string{"", "", "1", "2", "3", "3"} {
+		if got := check(Delete); got != want {
+			t.Fatalf("denial %d: expected Retry-After %q, got %q", i+1, want, got)
+		}
+	}
+
+	if got := check(Read); got != "" {
+		t.Fatalf("expected allowed requests not to back off, got %q", got)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if got := check(Delete); got != "" {
+		t.Fatalf("expected the streak to expire after the window, got %q", got)
+	}
+}
@@ -0,0 +1,104 @@
+package can
+
+import "context"
+
+// BatchCheck is a check of a batch, see CanBatch.
+type BatchCheck struct {
+	Permission string
+	Ability    Ability
+	// Compare is the compare function of the check, may be nil.
+	Compare func() bool
+}
+
+// BatchAuthorizer is implemented by authorizers that can decide many
+// checks at once cheaper than one by one, e.g. in a single round trip
+// to a remote service. AuthorizeBatch must return a decision for every
+// check, in order.
+type BatchAuthorizer interface {
+	AuthorizeBatch(ctx context.Context, role Role, checks []BatchCheck) []bool
+}
+
+// CanBatch decides many checks of a role in one call, for list endpoints
+// authorizing hundreds of items, e.g. which rows the user may edit. The
+// checks are evaluated locally like Can, but quotas are only read: a
+// batch tells what the role may do, use Can for the checks that go ahead.
+//
+// ctx - a standard ctx, see Can
+//
+// role - the role to check
+//
+// checks - the checks to decide
+//
+// returns - a decision for every check, in order
+func CanBatch(ctx context.Context, role Role, checks []BatchCheck) []Decision {
+	return AuthorizeBatch(ctx, peeking{}, role, checks)
+}
+
+// peeking is Local, only reading the quotas of its checks.
+type peeking struct{}
+
+// Authorize implements the Authorizer interface.
+func (p peeking) Authorize(ctx context.Context, role Role, permission string, ability Ability, compare func() bool) bool {
+	return p.AuthorizeOutcome(ctx, role, permission, ability, compare).Allow()
+}
+
+// AuthorizeOutcome implements the OutcomeAuthorizer interface.
+func (peeking) AuthorizeOutcome(ctx context.Context, role Role, permission string, ability Ability, compare func() bool) Outcome {
+	_, o := evaluatePermission(ctx, role, permission, ability, compare, nil, true)
+	return o
+}
+
+// AuthorizeBatch decides many checks of a role with a, in a single call
+// when a is a BatchAuthorizer and check by check otherwise. Like Decide,
+// denied checks are never Undecided.
+//
+// returns - a decision for every check, in order
+func AuthorizeBatch(ctx context.Context, a Authorizer, role Role, checks []BatchCheck) []Decision {
+	decisions := make([]Decision, len(checks))
+	if len(checks) == 0 {
+		return decisions
+	}
+
+	outcomes := make([]Outcome, len(checks))
+	if b, ok := a.(BatchAuthorizer); ok {
+		for i, allowed := range b.AuthorizeBatch(ctx, role, checks) {
+			if i < len(outcomes) {
+				outcomes[i] = decided(ctx, allowed)
+			}
+		}
+	} else {
+		for i, c := range checks {
+			outcomes[i] = Decide(ctx, a, role, c.Permission, c.Ability, c.Compare)
+		}
+	}
+
+	var (
+		now       = Now()
+		sub, _    = SubjectFrom(ctx)
+		tenant, _ = TenantFrom(ctx)
+	)
+	for i, c := range checks {
+		decisions[i] = Decision{
+			Time:       now,
+			Subject:    sub.ID,
+			Tenant:     tenant,
+			Permission: c.Permission,
+			Ability:    c.Ability,
+			Synthetic:  sub.Synthetic(),
+			Allowed:    outcomes[i].Allow(),
+			Undecided:  outcomes[i] == Undecided,
+		}
+	}
+
+	return decisions
+}
+
+// authorizeEach decides checks one by one with a.
+func authorizeEach(ctx context.Context, a Authorizer, role Role, checks []BatchCheck) []bool {
+	allowed := make([]bool, len(checks))
+	for i, c := range checks {
+		allowed[i] = a.Authorize(ctx, role, c.Permission, c.Ability, c.Compare)
+	}
+
+	return allowed
+}
@@ -0,0 +1,112 @@
+package can
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCanBatch(t *testing.T) {
+	r, err := OpenFile("testdata/rbac.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := WithSubject(context.Background(), Subject{ID: "u1"})
+	checks := []BatchCheck{
+		{Permission: "users", Ability: Read, Compare: Compare(1, 1)},
+		{Permission: "users", Ability: Create, Compare: Compare(1, 1)},
+		{Permission: "users", Ability: Update, Compare: Compare(1, 1)},
+	}
+
+	decisions := CanBatch(ctx, r["user"], checks)
+	if len(decisions) != len(checks) {
+		t.Fatalf("expected %d decisions, got %d", len(checks), len(decisions))
+	}
+	for i, want := range []bool{true, false, true} {
+		d := decisions[i]
+		if d.Allowed != want || d.Permission != checks[i].Permission || d.Ability != checks[i].Ability || d.Subject != "u1" {
+			t.Fatalf("unexpected decision %d: %+v", i, d)
+		}
+	}
+
+	defer func(e Effect) { OnUndecided = e }(OnUndecided)
+	OnUndecided = Allow
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	for i, d := range CanBatch(canceled, r["user"], checks) {
+		if d.Allowed != decisions[i].Allowed || d.Undecided {
+			t.Fatalf("expected a canceled batch to be decided by the role, got %+v", d)
+		}
+	}
+
+	if len(CanBatch(ctx, r["user"], nil)) != 0 {
+		t.Fatal("expected no decisions")
+	}
+}
+
+func TestWebhookBatch(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		var reqs []WebhookRequest
+		if err := json.NewDecoder(r.Body).Decode(&reqs); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		resp := make([]WebhookResponse, len(reqs))
+		for i, req := range reqs {
+			resp[i].Allow = req.Ability == Read
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer srv.Close()
+
+	wh := &Webhook{BatchURL: srv.URL}
+	checks := []BatchCheck{{Permission: "users", Ability: Read}, {Permission: "users", Ability: Delete}, {Permission: "books", Ability: Read}}
+	decisions := AuthorizeBatch(context.Background(), wh, nil, checks)
+	for i, want := range []bool{true, false, true} {
+		if decisions[i].Allowed != want {
+			t.Fatalf("unexpected decision %d: %+v", i, decisions[i])
+		}
+	}
+	if requests != 1 {
+		t.Fatalf("expected a single request, got %d", requests)
+	}
+
+	wh.BatchURL = srv.URL + "/missing"
+	wh.FailOpen = true
+	srv.Config.Handler = http.NotFoundHandler()
+	for _, d := range AuthorizeBatch(context.Background(), wh, nil, checks) {
+		if !d.Allowed {
+			t.Fatal("expected fail open on error")
+		}
+	}
+}
+
+func TestCanBatchQuota(t *testing.T) {
+	defer func(c QuotaCounter) { DefaultQuotaCounter = c }(DefaultQuotaCounter)
+	DefaultQuotaCounter = &MemoryCounter{}
+
+	role := Role{"exports": {
+		Abilities: map[Ability]struct{}{All: {}},
+		Quotas:    map[Ability]Quota{Read: {1, time.Hour}},
+	}}
+	ctx := WithSubject(context.Background(), Subject{ID: "u1"})
+	checks := []BatchCheck{{Permission: "exports", Ability: Read}, {Permission: "exports", Ability: Read}}
+	for _, d := range CanBatch(ctx, role, checks) {
+		if !d.Allowed {
+			t.Fatalf("expected the batch to be within the quota, got %+v", d)
+		}
+	}
+	if !Can(ctx, role, "exports", Read, nil) {
+		t.Fatal("expected the batch not to use the quota")
+	}
+	if d := CanBatch(ctx, role, checks[:1]); d[0].Allowed {
+		t.Fatalf("expected the batch to read the used up quota, got %+v", d[0])
+	}
+}
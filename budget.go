@@ -0,0 +1,92 @@
+package can
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrBudgetExceeded is returned when loaded roles exceed DefaultBudget.
+var ErrBudgetExceeded = errors.New("can: policy budget exceeded")
+
+// Budget limits the size and complexity of a policy, protecting latency
+// sensitive services from accidentally deploying pathological role
+// files. Zero fields are unlimited.
+type Budget struct {
+	// MaxRoles limits the number of roles.
+	MaxRoles int
+	// MaxPermissions limits the number of permissions of a single role,
+	// including derived route permissions.
+	MaxPermissions int
+	// MaxWildcards limits the number of wildcard permissions across all
+	// roles. Wildcards make lookups scan the role (see HierarchySeparator).
+	MaxWildcards int
+	// MaxDepth limits the number of hierarchy levels of a permission.
+	MaxDepth int
+	// MaxConditions limits the number of conditions (selectors, named
+	// comparators and networks) of a single permission.
+	MaxConditions int
+}
+
+// DefaultBudget is enforced every time roles are loaded by OpenFile,
+// OpenFiles, Decode and Config. The zero value doesn't limit anything.
+var DefaultBudget Budget
+
+// Check returns an error describing every limit r exceeds.
+//
+// r - the roles to check
+//
+// returns - nil, or the errors wrapping ErrBudgetExceeded joined with
+// errors.Join
+func (b Budget) Check(r Roles) error {
+	var errs []error
+	if b.MaxRoles > 0 && len(r) > b.MaxRoles {
+		errs = append(errs, fmt.Errorf("%w: %d roles, at most %d allowed", ErrBudgetExceeded, len(r), b.MaxRoles))
+	}
+
+	wildcards := 0
+	for _, name := range sortedKeys(r) {
+		role := r[name]
+		if b.MaxPermissions > 0 && len(role) > b.MaxPermissions {
+			errs = append(errs, fmt.Errorf("%w: role %q has %d permissions, at most %d allowed", ErrBudgetExceeded, name, len(role), b.MaxPermissions))
+		}
+
+		for _, key := range sortedKeys(role) {
+			p := role[key]
+			if HierarchySeparator != "" {
+				levels := strings.Split(key, HierarchySeparator)
+				if b.MaxDepth > 0 && len(levels) > b.MaxDepth {
+					errs = append(errs, fmt.Errorf("%w: role %q permission %q is %d levels deep, at most %d allowed", ErrBudgetExceeded, name, key, len(levels), b.MaxDepth))
+				}
+				for _, level := range levels {
+					if level == Wildcard {
+						wildcards++
+						break
+					}
+				}
+			}
+
+			if conditions := len(p.Selectors) + len(p.Conditions) + len(p.Networks); b.MaxConditions > 0 && conditions > b.MaxConditions {
+				errs = append(errs, fmt.Errorf("%w: role %q permission %q has %d conditions, at most %d allowed", ErrBudgetExceeded, name, key, conditions, b.MaxConditions))
+			}
+		}
+	}
+
+	if b.MaxWildcards > 0 && wildcards > b.MaxWildcards {
+		errs = append(errs, fmt.Errorf("%w: %d wildcard permissions, at most %d allowed", ErrBudgetExceeded, wildcards, b.MaxWildcards))
+	}
+
+	return errors.Join(errs...)
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys[M ~map[string]V, V any](m M) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
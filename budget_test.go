@@ -0,0 +1,49 @@
+package can
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBudget(t *testing.T) {
+	r, err := ConfigErr(DiskRoles{
+		"member": DiskRole{
+			"projects/*/tasks":   DiskPermission{Abilities: []string{"read"}},
+			"projects/*/members": DiskPermission{Abilities: []string{"read"}},
+			"deployments":        DiskPermission{Abilities: []string{"all"}, Environments: []string{"dev"}, Labels: map[string][]string{"region": {"eu"}}},
+		},
+		"guest": DiskRole{
+			"docs": DiskPermission{Abilities: []string{"read"}, Routes: []string{"search"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		budget Budget
+		ok     bool
+	}{
+		{"unlimited", Budget{}, true},
+		{"roles", Budget{MaxRoles: 1}, false},
+		{"permissions", Budget{MaxPermissions: 2}, false},
+		{"wildcards", Budget{MaxWildcards: 1}, false},
+		{"depth", Budget{MaxDepth: 2}, false},
+		{"conditions", Budget{MaxConditions: 1}, false},
+		{"within", Budget{MaxRoles: 2, MaxPermissions: 3, MaxWildcards: 2, MaxDepth: 3, MaxConditions: 2}, true},
+	}
+
+	for _, tt := range tests {
+		err := tt.budget.Check(r)
+		if tt.ok != (err == nil) || (err != nil && !errors.Is(err, ErrBudgetExceeded)) {
+			t.Errorf("%s: unexpected result %v", tt.name, err)
+		}
+	}
+
+	defer func(b Budget) { DefaultBudget = b }(DefaultBudget)
+	DefaultBudget = Budget{MaxRoles: 1}
+	if _, err := OpenFile("testdata/rbac.yml"); !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected the default budget to be enforced at load, got %v", err)
+	}
+}
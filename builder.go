@@ -0,0 +1,93 @@
+package can
+
+// RoleBuilder defines a role in Go code, as an alternative to role
+// files:
+//
+//	admin := can.NewRole().
+//		Allow("users", can.Read, can.Update).
+//		Deny("billing", can.All).
+//		Build()
+type RoleBuilder struct {
+	role Role
+}
+
+// NewRole returns a builder for an empty role.
+func NewRole() *RoleBuilder {
+	return &RoleBuilder{role: make(Role)}
+}
+
+// Allow grants abilities on a permission, in addition to the abilities
+// already granted on it.
+//
+// permission - the permission key, see PermissionKey
+//
+// abilities - the abilities to grant
+//
+// returns - the builder
+func (b *RoleBuilder) Allow(permission string, abilities ...Ability) *RoleBuilder {
+	p := b.role[permission]
+	if p.Abilities == nil {
+		p.Abilities = make(map[Ability]struct{}, len(abilities))
+	}
+	for _, a := range abilities {
+		p.Abilities[a] = struct{}{}
+	}
+	b.role[permission] = p
+
+	return b
+}
+
+// Deny refuses abilities on a permission even if they are allowed, here
+// or in a role the built role is merged with (see Merge).
+func (b *RoleBuilder) Deny(permission string, abilities ...Ability) *RoleBuilder {
+	p := b.role[permission]
+	if p.Abilities == nil {
+		p.Abilities = make(map[Ability]struct{})
+	}
+	if p.Denied == nil {
+		p.Denied = make(map[Ability]struct{}, len(abilities))
+	}
+	for _, a := range abilities {
+		p.Denied[a] = struct{}{}
+	}
+	b.role[permission] = p
+
+	return b
+}
+
+// Fields limits an allowed ability on a permission to the given fields
+// (see CanField).
+func (b *RoleBuilder) Fields(permission string, ability Ability, fields ...string) *RoleBuilder {
+	b.Allow(permission, ability)
+
+	p := b.role[permission]
+	if p.Fields == nil {
+		p.Fields = make(map[Ability][]string)
+	}
+	p.Fields[ability] = append(p.Fields[ability], fields...)
+	b.role[permission] = p
+
+	return b
+}
+
+// Where limits a permission to checks whose label key has one of values
+// (see WithLabels).
+func (b *RoleBuilder) Where(permission, key string, values ...string) *RoleBuilder {
+	p := b.role[permission]
+	if p.Abilities == nil {
+		p.Abilities = make(map[Ability]struct{})
+	}
+	if p.Selectors == nil {
+		p.Selectors = make(map[string][]string)
+	}
+	p.Selectors[key] = append(p.Selectors[key], values...)
+	b.role[permission] = p
+
+	return b
+}
+
+// Build returns the role. The builder can be reused, later changes
+// don't affect roles that were already built.
+func (b *RoleBuilder) Build() Role {
+	return b.role.Clone()
+}
@@ -0,0 +1,44 @@
+package can
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRoleBuilder(t *testing.T) {
+	b := NewRole().
+		Allow("users", Read, Update).
+		Deny("billing", All).
+		Fields("profiles", Update, "display_name").
+		Allow("reports", Read).
+		Where("reports", EnvironmentLabel, "staging")
+	role := b.Build()
+
+	ctx := context.Background()
+	yes := func() bool { return true }
+	if !Can(ctx, role, "users", Update, yes) || Can(ctx, role, "users", Delete, yes) {
+		t.Fatal("expected only the allowed abilities")
+	}
+	if Can(ctx, role, "billing", Read, yes) {
+		t.Fatal("expected billing to be denied")
+	}
+	if !CanField(ctx, role, "profiles", Update, "display_name") || CanField(ctx, role, "profiles", Update, "email") {
+		t.Fatal("expected update to be limited to display_name")
+	}
+	if Can(ctx, role, "reports", Read, yes) || !Can(WithLabels(ctx, Labels{EnvironmentLabel: "staging"}), role, "reports", Read, yes) {
+		t.Fatal("expected reports to be limited to staging")
+	}
+
+	b.Allow("users", Delete)
+	if Can(ctx, role, "users", Delete, yes) {
+		t.Fatal("expected built roles not to change with the builder")
+	}
+
+	merged, err := Merge(Roles{"a": role}, Roles{"a": NewRole().Allow("billing", Read).Build()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Can(ctx, merged["a"], "billing", Read, yes) {
+		t.Fatal("expected deny to win when merged")
+	}
+}
@@ -0,0 +1,285 @@
+package can
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheStats reports the hit rate of a Cache.
+type CacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Entries   int
+}
+
+// HitRate returns the ratio of hits to lookups, or 0 if nothing was looked up.
+func (s CacheStats) HitRate() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+
+	return float64(s.Hits) / float64(total)
+}
+
+// Cache is an Authorizer that remembers the decisions of another
+// Authorizer for a limited time. It is useful in front of remote or
+// database backed authorizers where identical checks are expensive.
+// Decisions are keyed by the role's contents, the subject, tenant, labels
+// and attributes in ctx, the permission, the ability and the result of the
+// compare function, and the remote address of the request for
+// permissions with networks or conditions. Authorizers deciding on other
+// parts of the request, such as its path, must not be cached. Checks of
+// permissions with quotas are never cached, every one of them uses the
+// quota, and neither are undecided checks.
+type Cache struct {
+	authorizer Authorizer
+	ttl        time.Duration
+	max        int
+
+	mu      sync.Mutex
+	entries map[cacheKey]*list.Element
+	lru     *list.List
+	stats   CacheStats
+}
+
+type cacheKey struct {
+	role    uint64
+	context uint64
+	// subject and tenant are the subject and tenant in ctx, which remote
+	// authorizers decide on
+	subject    string
+	tenant     string
+	permission string
+	ability    Ability
+	compare    int8
+	// comparators is the DefaultComparators generation, so decisions
+	// are re-evaluated once a comparator is replaced
+	comparators uint64
+	// remote is the remote address of the request, for permissions
+	// restricted to networks or with conditions
+	remote string
+}
+
+type cacheEntry struct {
+	key     cacheKey
+	allowed bool
+	expires time.Time
+}
+
+// Cached wraps an authorizer with a decision cache.
+//
+// a - the authorizer making the decisions on a miss
+//
+// ttl - how long a decision is kept
+//
+// maxEntries - the maximum number of decisions kept, the least recently
+// used decision is evicted first. Zero or less means unbounded
+//
+// returns - a Cache
+func Cached(a Authorizer, ttl time.Duration, maxEntries int) *Cache {
+	return &Cache{
+		authorizer: a,
+		ttl:        ttl,
+		max:        maxEntries,
+		entries:    make(map[cacheKey]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+// Authorize implements the Authorizer interface.
+func (c *Cache) Authorize(ctx context.Context, role Role, permission string, ability Ability, compare func() bool) bool {
+	return c.AuthorizeOutcome(ctx, role, permission, ability, compare).Allow()
+}
+
+// AuthorizeOutcome implements the OutcomeAuthorizer interface, deciding
+// misses with Decide.
+func (c *Cache) AuthorizeOutcome(ctx context.Context, role Role, permission string, ability Ability, compare func() bool) Outcome {
+	p, listed := lookup(role, permission)
+	if listed && len(p.Quotas) > 0 {
+		return Decide(ctx, c.authorizer, role, permission, ability, compare)
+	}
+
+	key := cacheKey{role: storedRoleHash(role), context: contextHash(ctx), permission: permission, ability: ability, compare: -1, comparators: DefaultComparators.Generation()}
+	if sub, ok := SubjectFrom(ctx); ok {
+		key.subject = sub.ID + "\x00" + strings.Join(sub.Roles, "\x00")
+	}
+	key.tenant, _ = TenantFrom(ctx)
+	if listed && (len(p.Networks) > 0 || len(p.Conditions) > 0) {
+		req, _ := RequestFrom(ctx)
+		if addr, ok := parseRemoteAddr(req.RemoteAddr); ok {
+			key.remote = addr.String()
+		}
+	}
+	if compare != nil {
+		result := compare()
+		key.compare = 0
+		if result {
+			key.compare = 1
+		}
+		compare = func() bool { return result }
+	}
+
+	now := Now()
+	if allowed, ok := c.get(key, now); ok {
+		return outcome(allowed)
+	}
+
+	o := Decide(ctx, c.authorizer, role, permission, ability, compare)
+	// undecided checks follow OnUndecided and must not answer later checks
+	if o != Undecided {
+		c.put(key, o == Allowed, now)
+	}
+	return o
+}
+
+// Purge removes every cached decision. Call it when the roles or the
+// policy behind the wrapped authorizer change.
+func (c *Cache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[cacheKey]*list.Element)
+	c.lru.Init()
+}
+
+// Stats returns the cache statistics.
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := c.stats
+	s.Entries = c.lru.Len()
+	return s
+}
+
+// get returns a fresh cached decision.
+func (c *Cache) get(key cacheKey, now time.Time) (bool, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		c.stats.Misses++
+		return false, false
+	}
+
+	e := el.Value.(*cacheEntry)
+	if !now.Before(e.expires) {
+		c.lru.Remove(el)
+		delete(c.entries, key)
+		c.stats.Misses++
+		return false, false
+	}
+
+	c.lru.MoveToFront(el)
+	c.stats.Hits++
+	return e.allowed, true
+}
+
+// put caches a decision, evicting the least recently used one if full.
+func (c *Cache) put(key cacheKey, allowed bool, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		e := el.Value.(*cacheEntry)
+		e.allowed, e.expires = allowed, now.Add(c.ttl)
+		c.lru.MoveToFront(el)
+		return
+	}
+
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, allowed: allowed, expires: now.Add(c.ttl)})
+	if c.max > 0 && c.lru.Len() > c.max {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+		c.stats.Evictions++
+	}
+}
+
+// roleHash returns a hash of the role's contents that is independent of
+// map iteration order.
+func roleHash(role Role) uint64 {
+	if role == nil {
+		return 0
+	}
+
+	keys := make([]string, 0, len(role))
+	for k := range role {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	// encoding/json writes maps in sorted key order and abilities, custom
+	// ones included, by their text, so every field of the permission
+	// contributes to the hash deterministically.
+	h := fnv.New64a()
+	enc := json.NewEncoder(h)
+	for _, k := range keys {
+		fmt.Fprintf(h, "%s\x00", k)
+		enc.Encode(role[k])
+	}
+
+	return h.Sum64()
+}
+
+// roleHashes holds the hashes of the roles and snapshots of Stores, keyed
+// by the address of the role's map, so cached checks don't hash them
+// again. Entries keep their role, its address can't be reused while it
+// is hashed here.
+var roleHashes sync.Map
+
+type hashedRole struct {
+	role Role
+	hash uint64
+}
+
+// rememberHash hashes a role of a Store once it is loaded or snapshotted.
+func rememberHash(role Role) {
+	if role != nil {
+		roleHashes.Store(reflect.ValueOf(role).Pointer(), hashedRole{role: role, hash: roleHash(role)})
+	}
+}
+
+// forgetHash forgets the hash of a role its Store no longer serves.
+func forgetHash(role Role) {
+	if role != nil {
+		roleHashes.Delete(reflect.ValueOf(role).Pointer())
+	}
+}
+
+// storedRoleHash returns the hash of a role, remembered if it is a role
+// of a Store.
+func storedRoleHash(role Role) uint64 {
+	if role == nil {
+		return 0
+	}
+	if v, ok := roleHashes.Load(reflect.ValueOf(role).Pointer()); ok {
+		return v.(hashedRole).hash
+	}
+
+	return roleHash(role)
+}
+
+// contextHash returns a hash of the labels and attributes stored in ctx,
+// which can change the outcome of a check.
+func contextHash(ctx context.Context) uint64 {
+	labels, attributes := LabelsFrom(ctx), AttributesFrom(ctx)
+	if len(labels) == 0 && len(attributes) == 0 {
+		return 0
+	}
+
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%v\x00%v", map[string]string(labels), map[string]any(attributes))
+	return h.Sum64()
+}
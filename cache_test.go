@@ -0,0 +1,162 @@
+package can
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCached(t *testing.T) {
+	r, err := OpenFile("testdata/rbac.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls := 0
+	counting := AuthorizerFunc(func(ctx context.Context, role Role, permission string, ability Ability, compare func() bool) bool {
+		calls++
+		return Can(ctx, role, permission, ability, compare)
+	})
+
+	c := Cached(counting, time.Minute, 2)
+	ctx := context.Background()
+
+	if !c.Authorize(ctx, r["user"], "users", Read, Compare(1, 1)) {
+		t.Fatal("expected allow")
+	}
+	if !c.Authorize(ctx, r["user"], "users", Read, Compare(2, 2)) {
+		t.Fatal("expected cached allow")
+	}
+	if c.Authorize(ctx, r["user"], "users", Read, Compare(1, 2)) {
+		t.Fatal("expected compare to be part of the key")
+	}
+	if calls != 2 {
+		t.Fatalf("expected 2 calls to the authorizer, got %d", calls)
+	}
+
+	c.Authorize(ctx, r["admin"], "users", Read, nil)
+	s := c.Stats()
+	if s.Hits != 1 || s.Misses != 3 || s.Evictions != 1 || s.Entries != 2 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+	if s.HitRate() != 0.25 {
+		t.Fatalf("unexpected hit rate %v", s.HitRate())
+	}
+
+	c.Purge()
+	c.Authorize(ctx, r["admin"], "users", Read, nil)
+	if calls != 4 {
+		t.Fatalf("expected purge to drop decisions, got %d calls", calls)
+	}
+}
+
+func TestCachedExpiry(t *testing.T) {
+	calls := 0
+	a := AuthorizerFunc(func(ctx context.Context, role Role, permission string, ability Ability, compare func() bool) bool {
+		calls++
+		return true
+	})
+
+	c := Cached(a, time.Millisecond, 0)
+	c.Authorize(context.Background(), nil, "users", Read, nil)
+	time.Sleep(2 * time.Millisecond)
+	c.Authorize(context.Background(), nil, "users", Read, nil)
+
+	if calls != 2 {
+		t.Fatalf("expected expired decision to be re-evaluated, got %d calls", calls)
+	}
+}
+
+func TestCachedUndecided(t *testing.T) {
+	defer func(o Effect) { OnUndecided = o }(OnUndecided)
+	OnUndecided = Allow
+
+	defer RegisterComparator("remote", nil)
+	RegisterComparator("remote", func(ctx context.Context) bool { return false })
+	role := Role{"users": {Abilities: map[Ability]struct{}{Read: {}}, Conditions: []string{"remote"}}}
+	c := Cached(Local, time.Minute, 0)
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if c.Authorize(canceled, role, "users", Delete, nil) {
+		t.Fatal("expected a canceled check the role doesn't grant to be denied")
+	}
+	if !c.Authorize(canceled, role, "users", Read, nil) {
+		t.Fatal("expected the cut off comparator to follow OnUndecided")
+	}
+	if c.Authorize(context.Background(), role, "users", Read, nil) {
+		t.Fatal("expected the live check to be denied, not the cached undecided allow")
+	}
+	if s := c.Stats(); s.Entries != 2 {
+		t.Fatalf("expected only the decided checks to be cached, got %+v", s)
+	}
+}
+
+func TestCachedRequest(t *testing.T) {
+	pdp := AuthorizerFunc(func(ctx context.Context, role Role, permission string, ability Ability, compare func() bool) bool {
+		sub, _ := SubjectFrom(ctx)
+		tenant, _ := TenantFrom(ctx)
+		return sub.ID == "u1" && tenant != "t2"
+	})
+	c := Cached(pdp, time.Minute, 0)
+	role := Role{"users": {Abilities: map[Ability]struct{}{All: {}}}}
+
+	u1 := WithSubject(context.Background(), Subject{ID: "u1"})
+	if !c.Authorize(u1, role, "users", Read, nil) {
+		t.Fatal("expected the PDP to allow u1")
+	}
+	if c.Authorize(WithSubject(context.Background(), Subject{ID: "u2"}), role, "users", Read, nil) {
+		t.Fatal("expected the subject to be part of the key")
+	}
+	if c.Authorize(WithTenant(u1, "t2"), role, "users", Read, nil) {
+		t.Fatal("expected the tenant to be part of the key")
+	}
+
+	defer RegisterComparator("office", nil)
+	RegisterComparator("office", func(ctx context.Context) bool {
+		req, _ := RequestFrom(ctx)
+		return req.RemoteAddr == "10.0.0.1:1234"
+	})
+	conditioned := Role{"users": {Abilities: map[Ability]struct{}{All: {}}, Conditions: []string{"office"}}}
+	from := func(addr string) context.Context {
+		r := httptest.NewRequest(http.MethodGet, "/users", nil)
+		r.RemoteAddr = addr
+		return WithRequest(context.Background(), r)
+	}
+	c = Cached(Local, time.Minute, 0)
+	if !c.Authorize(from("10.0.0.1:1234"), conditioned, "users", Read, nil) {
+		t.Fatal("expected the office address to be allowed")
+	}
+	if c.Authorize(from("10.0.0.2:1234"), conditioned, "users", Read, nil) {
+		t.Fatal("expected the remote address to be part of the key of conditions")
+	}
+}
+
+func TestStoredRoleHash(t *testing.T) {
+	r, err := OpenFile("testdata/rbac.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewStore(r)
+	role, _ := s.Role("user")
+	pointer := reflect.ValueOf(role).Pointer()
+
+	if v, ok := roleHashes.Load(pointer); !ok || v.(hashedRole).hash != roleHash(role) {
+		t.Fatal("expected the roles of the store to be hashed once loaded")
+	}
+	merged, _ := s.SubjectRole(Subject{ID: "u1", Roles: []string{"admin", "user"}})
+	if _, ok := roleHashes.Load(reflect.ValueOf(merged).Pointer()); !ok {
+		t.Fatal("expected the snapshot to be hashed")
+	}
+
+	s.Set(Roles{"user": role.Clone()})
+	if _, ok := roleHashes.Load(reflect.ValueOf(merged).Pointer()); ok {
+		t.Fatal("expected the hash of a replaced snapshot to be forgotten")
+	}
+	if _, ok := roleHashes.Load(pointer); ok {
+		t.Fatal("expected the hash of a replaced role to be forgotten")
+	}
+}
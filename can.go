@@ -6,14 +6,18 @@
 package can
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
-	"os"
+	"net/netip"
+	"sort"
+	"strconv"
 	"strings"
 
-	"github.com/go-chi/chi/v5"
-	"golang.org/x/exp/constraints"
+	"golang.org/x/text/unicode/norm"
 	"gopkg.in/yaml.v3"
 )
 
@@ -45,6 +49,44 @@ func (a Ability) String() string {
 	return "none"
 }
 
+// MarshalText implements the encoding.TextMarshaler interface, so
+// abilities are encoded by name in JSON and as map keys. Custom
+// abilities without a name are encoded as their number.
+func (a Ability) MarshalText() ([]byte, error) {
+	return []byte(a.text()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+// Unknown names decode to None.
+func (a *Ability) UnmarshalText(text []byte) error {
+	*a = parseAbility(string(text))
+	return nil
+}
+
+// text returns the name of a, or the number of a custom ability.
+func (a Ability) text() string {
+	if name := a.String(); name != "none" || a == None {
+		return name
+	}
+
+	return strconv.FormatInt(int64(a), 10)
+}
+
+// parseAbility is the inverse of text: it parses an ability name like
+// StringToAbility and the number of a custom ability.
+func parseAbility(s string) Ability {
+	if a := StringToAbility(s); a != None {
+		return a
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if a := Ability(n); err == nil && a.text() == s {
+		return a
+	}
+
+	return None
+}
+
 // StringToAbility converts a string to an ability type
 //
 // s is a string to convert
@@ -89,12 +131,87 @@ const (
 	None
 )
 
+// Effect is the outcome a policy applies to a check.
+type Effect int
+
+const (
+	// Deny refuses access.
+	Deny Effect = iota
+	// Allow grants access.
+	Allow
+)
+
+// String implements the Stringer interface.
+func (e Effect) String() string {
+	if e == Allow {
+		return "allow"
+	}
+
+	return "deny"
+}
+
+// Unlisted is the global policy for permissions a role has no entry
+// for. It defaults to Deny (deny-by-default). Setting it to Allow lets
+// every role use any ability on permissions it doesn't list, which can
+// ease rolling out authorization to an existing application. Roles that
+// are missing entirely and rejected paths are always denied, and so are
+// the built in AdminPermission and ImpersonatePermission, which must be
+// granted explicitly. It is enforced by Can, CanField and therefore
+// every Guard using Local.
+var Unlisted = Deny
+
+// unlistedAllowed reports whether Unlisted allows a permission roles
+// don't list.
+func unlistedAllowed(permission string) bool {
+	if Unlisted != Allow || permission == AdminPermission || permission == ImpersonatePermission {
+		return false
+	}
+
+	return HierarchySeparator == "" || !strings.HasPrefix(permission, ImpersonatePermission+HierarchySeparator)
+}
+
 // Permission provides typed structure for general permissions or
 // access to a given resource. This struct is easily embedded in
 // other types to extend the permissions (see examples).
 type Permission struct {
 	Abilities map[Ability]struct{} `json:"abilities" db:"abilities" yaml:"abilities"`
 	Resource  string               `json:"resource" db:"resource" yaml:"resource"`
+	// Fields optionally restricts an ability to a set of fields of the
+	// resource (see CanField). Abilities without an entry allow every field.
+	Fields map[Ability][]string `json:"fields,omitempty" db:"fields" yaml:"fields,omitempty"`
+	// Selectors makes the grant conditional on the labels of a check (see
+	// WithLabels). Every selector key must have one of its values.
+	Selectors map[string][]string `json:"selectors,omitempty" db:"selectors" yaml:"selectors,omitempty"`
+	// Denied lists abilities that are explicitly refused. A denial always
+	// wins over an allowed ability, denying All refuses every ability.
+	Denied map[Ability]struct{} `json:"denied,omitempty" db:"denied" yaml:"denied,omitempty"`
+	// Conditions names comparators that must all allow a check (see
+	// Comparators), evaluated after the abilities.
+	Conditions []string `json:"conditions,omitempty" db:"conditions" yaml:"conditions,omitempty"`
+	// Audited lists abilities whose checks must be audited with their full
+	// context. A Guard denies them when its Audit fails (see Guard.Audit).
+	Audited map[Ability]struct{} `json:"audited,omitempty" db:"audited" yaml:"audited,omitempty"`
+	// Quotas limits how many times a subject may use an ability in a
+	// window, counted by DefaultQuotaCounter. A quota on All applies to
+	// every ability.
+	Quotas map[Ability]Quota `json:"quotas,omitempty" db:"quotas" yaml:"quotas,omitempty"`
+	// Networks restricts the grant to checks from remote addresses in
+	// one of the networks (see InNetwork).
+	Networks []netip.Prefix `json:"cidr,omitempty" db:"cidr" yaml:"cidr,omitempty"`
+}
+
+// Denies reports whether the permission explicitly refuses ability.
+func (p Permission) Denies(ability Ability) bool {
+	_, ok := p.Denied[ability]
+	_, okAll := p.Denied[All]
+	return ok || okAll
+}
+
+// RequiresAudit reports whether checks of ability must be audited.
+func (p Permission) RequiresAudit(ability Ability) bool {
+	_, ok := p.Audited[ability]
+	_, okAll := p.Audited[All]
+	return ok || okAll
 }
 
 // Role provides typed structure for general roles that
@@ -102,12 +219,101 @@ type Permission struct {
 // other types to extend the role (see examples).
 type Role map[string]Permission
 
+// Clone returns a deep copy of the role.
+func (r Role) Clone() Role {
+	if r == nil {
+		return nil
+	}
+
+	c := make(Role, len(r))
+	for k, p := range r {
+		c[k] = p.Clone()
+	}
+
+	return c
+}
+
+// Clone returns a deep copy of the permission.
+func (p Permission) Clone() Permission {
+	p.Abilities = cloneAbilities(p.Abilities)
+	if p.Denied != nil {
+		p.Denied = cloneAbilities(p.Denied)
+	}
+	if p.Audited != nil {
+		p.Audited = cloneAbilities(p.Audited)
+	}
+	if p.Conditions != nil {
+		p.Conditions = append([]string(nil), p.Conditions...)
+	}
+	if p.Networks != nil {
+		p.Networks = append([]netip.Prefix(nil), p.Networks...)
+	}
+	if p.Quotas != nil {
+		quotas := make(map[Ability]Quota, len(p.Quotas))
+		for a, q := range p.Quotas {
+			quotas[a] = q
+		}
+		p.Quotas = quotas
+	}
+	if p.Fields != nil {
+		fields := make(map[Ability][]string, len(p.Fields))
+		for a, f := range p.Fields {
+			fields[a] = append([]string(nil), f...)
+		}
+		p.Fields = fields
+	}
+	if p.Selectors != nil {
+		selectors := make(map[string][]string, len(p.Selectors))
+		for k, v := range p.Selectors {
+			selectors[k] = append([]string(nil), v...)
+		}
+		p.Selectors = selectors
+	}
+
+	return p
+}
+
+// cloneAbilities returns a copy of a set of abilities.
+func cloneAbilities(abilities map[Ability]struct{}) map[Ability]struct{} {
+	c := make(map[Ability]struct{}, len(abilities))
+	for a := range abilities {
+		c[a] = struct{}{}
+	}
+
+	return c
+}
+
 type Roles map[string]Role
 
 type DiskPermission struct {
 	Abilities []string `json:"abilities" db:"abilities" yaml:"abilities"`
-	Routes    []string `json:"routes" db:"routes" yaml:"routes"`
-	Resource  string   `json:"resource" db:"resource" yaml:"resource"`
+	// Routes grants the permission on routes below the resource too, such
+	// as search or the template {id}/invoices/{iid}/lines (see
+	// PermissionKey).
+	Routes   []string            `json:"routes" db:"routes" yaml:"routes,omitempty"`
+	Resource string              `json:"resource" db:"resource" yaml:"resource,omitempty"`
+	Fields   map[string][]string `json:"fields,omitempty" db:"fields" yaml:"fields,omitempty"`
+	// Environments limits the grant to the given environments, shorthand
+	// for an environment label selector.
+	Environments []string `json:"environments,omitempty" db:"environments" yaml:"environments,omitempty"`
+	// Labels limits the grant to checks whose labels match, every key
+	// must have one of the listed values.
+	Labels map[string][]string `json:"labels,omitempty" db:"labels" yaml:"labels,omitempty"`
+	// Deny lists abilities that are refused even if they are granted,
+	// for example by another role file (see Merge).
+	Deny []string `json:"deny,omitempty" db:"deny" yaml:"deny,omitempty"`
+	// Conditions names comparators that must all allow a check, see
+	// RegisterComparator. Checks with an unregistered comparator are denied.
+	Conditions []string `json:"conditions,omitempty" db:"conditions" yaml:"conditions,omitempty"`
+	// Audit lists abilities that must always be audited with their full
+	// context, checks fail closed when the audit sink is unavailable.
+	Audit []string `json:"audit,omitempty" db:"audit" yaml:"audit,omitempty"`
+	// Quotas limits how many times a subject may use an ability, keyed
+	// by ability name, e.g. create: 5/day (see ParseQuota).
+	Quotas map[string]string `json:"quotas,omitempty" db:"quotas" yaml:"quotas,omitempty"`
+	// CIDR limits the grant to requests from the given networks, CIDR
+	// blocks such as 10.0.0.0/8 or single IP addresses.
+	CIDR []string `json:"cidr,omitempty" db:"cidr" yaml:"cidr,omitempty"`
 }
 
 // diskRole is the private struct that represents how
@@ -119,45 +325,216 @@ type DiskRoles map[string]DiskRole
 
 // UnmarshalYAML implement the yaml Unmarshaler interface
 func (r Roles) UnmarshalYAML(value *yaml.Node) error {
+	positions := checkNode(value)
+
 	var diskYaml DiskRoles
 	if err := value.Decode(&diskYaml); err != nil {
-		return err
+		return withPositions(positions, err)
 	}
 
-	buildRole(diskYaml, &r)
-	return nil
+	return withPositions(positions, buildRole(diskYaml, &r))
 }
 
-// buildRole converts config representations of roles into in Roles structs
-func buildRole(diskYaml DiskRoles, r *Roles) {
-	for k, v := range diskYaml {
+// ErrPermissionCollision is returned when two entries of a role produce
+// the same permission key, for example resource users with route admin
+// and a resource named users_admin.
+var ErrPermissionCollision = errors.New("can: permission collision")
+
+// Separator joins a resource and its route segments into a permission
+// key (see PermissionKey). It defaults to "_", so resource books with
+// route search becomes books_search. Resource names may contain the
+// separator, collisions are detected when roles are loaded; pick a
+// separator that never appears in resource names to rule them out. It
+// must be set before any roles are loaded.
+var Separator = "_"
+
+// PermissionKey builds the permission key of a resource and an optional
+// route. Routes may span several segments separated by "/" and be route
+// templates such as {id}/invoices/{iid}/lines: url param segments are
+// dropped, so the key of resource accounts with that route is
+// accounts_invoices_lines, the permission PermissionFromPath, the canchi
+// PermissionFromPath and PermissionFromPattern build for the route
+// accounts/{id}/invoices/{iid}/lines.
+//
+// resource - the resource name
+//
+// route - the route segments below the resource
+//
+// returns - the permission key
+func PermissionKey(resource string, route ...string) string {
+	parts := []string{norm.NFC.String(resource)}
+	for _, r := range route {
+		for _, segment := range strings.Split(r, "/") {
+			if segment != "" && !isRouteParam(segment) {
+				parts = append(parts, norm.NFC.String(segment))
+			}
+		}
+	}
+
+	return strings.Join(parts, Separator)
+}
+
+// isRouteParam reports whether segment is a url param of a route
+// template, as in chi's {id} and {id:[0-9]+} or the ServeMux {id...}.
+func isRouteParam(segment string) bool {
+	return strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}")
+}
+
+// ErrUnknownAbility is returned when a role file names an ability that
+// doesn't exist.
+var ErrUnknownAbility = errors.New("can: unknown ability")
+
+// buildRole converts config representations of roles into in Roles
+// structs. Every problem is reported, joined with errors.Join, so policy
+// authors can fix them in one pass.
+func buildRole(diskYaml DiskRoles, r *Roles) error {
+	var errs []error
+
+	// sort the roles and resources so problems are reported deterministically
+	names := make([]string, 0, len(diskYaml))
+	for k := range diskYaml {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	for _, k := range names {
+		v := diskYaml[k]
 		newRole := make(Role)
-		for j, p := range v {
+		origins := make(map[string]string)
+
+		resources := make([]string, 0, len(v))
+		for j := range v {
+			resources = append(resources, j)
+		}
+		sort.Strings(resources)
+
+		for _, j := range resources {
+			origins[PermissionKey(j)] = fmt.Sprintf("resource %q", j)
+		}
+
+		for _, j := range resources {
+			p := v[j]
+			unknown := func(where string, names []string) {
+				if len(names) > 0 {
+					errs = append(errs, fmt.Errorf("%w: role %q resource %q %s: %s", ErrUnknownAbility, k, j, where, strings.Join(names, ", ")))
+				}
+			}
+
+			abilities, bad := buildAbility(p.Abilities)
+			unknown("abilities", bad)
+			fields, bad := buildFields(p.Fields)
+			unknown("fields", bad)
 			per := Permission{
-				Abilities: buildAbility(p.Abilities),
+				Abilities: abilities,
 				Resource:  p.Resource,
+				Fields:    fields,
+				Selectors: buildSelectors(p.Environments, p.Labels),
+			}
+			if len(p.Conditions) > 0 {
+				per.Conditions = append([]string(nil), p.Conditions...)
+			}
+			if len(p.Deny) > 0 {
+				per.Denied, bad = buildAbility(p.Deny)
+				unknown("deny", bad)
+			}
+			if len(p.Audit) > 0 {
+				per.Audited, bad = buildAbility(p.Audit)
+				unknown("audit", bad)
+			}
+			if len(p.CIDR) > 0 {
+				var errNetwork error
+				if per.Networks, errNetwork = buildNetworks(p.CIDR); errNetwork != nil {
+					errs = append(errs, fmt.Errorf("role %q resource %q cidr: %w", k, j, errNetwork))
+				}
+			}
+			if len(p.Quotas) > 0 {
+				var errQuota error
+				per.Quotas, bad, errQuota = buildQuotas(p.Quotas)
+				unknown("quotas", bad)
+				if errQuota != nil {
+					errs = append(errs, fmt.Errorf("role %q resource %q quotas: %w", k, j, errQuota))
+				}
 			}
 			for _, route := range p.Routes {
-				newRole[fmt.Sprintf("%s_%s", j, route)] = per
+				key := PermissionKey(j, route)
+				origin := fmt.Sprintf("route %q of resource %q", route, j)
+				if other, ok := origins[key]; ok {
+					errs = append(errs, fmt.Errorf("%w: role %q: %s and %s both map to %q", ErrPermissionCollision, k, origin, other, key))
+					continue
+				}
+				origins[key] = origin
+				newRole[key] = per
 			}
-			newRole[j] = per
+			newRole[PermissionKey(j)] = per
 		}
 		(*r)[k] = newRole
 	}
+
+	if err := DefaultBudget.Check(*r); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
 }
 
-// buildAbility converts config representations of abilities into in Ability structs
-func buildAbility(abilities []string) map[Ability]struct{} {
+// buildAbility converts config representations of abilities into in
+// Ability structs, also returning the names that aren't abilities.
+func buildAbility(abilities []string) (map[Ability]struct{}, []string) {
 	a := make(map[Ability]struct{})
+	var unknown []string
 	for _, ability := range abilities {
-		a[Ability(StringToAbility(ability))] = struct{}{}
+		parsed := parseAbility(ability)
+		if parsed == None {
+			unknown = append(unknown, ability)
+			continue
+		}
+		a[parsed] = struct{}{}
+	}
+
+	return a, unknown
+}
+
+// buildFields converts config representations of field restrictions
+// keyed by ability name, also returning the keys that aren't abilities.
+func buildFields(fields map[string][]string) (map[Ability][]string, []string) {
+	if fields == nil {
+		return nil, nil
+	}
+
+	f := make(map[Ability][]string, len(fields))
+	var unknown []string
+	for ability, names := range fields {
+		parsed := parseAbility(ability)
+		if parsed == None {
+			unknown = append(unknown, ability)
+			continue
+		}
+		f[parsed] = names
+	}
+	sort.Strings(unknown)
+
+	return f, unknown
+}
+
+// buildSelectors converts config representations of environments and labels into selectors
+func buildSelectors(environments []string, labels map[string][]string) map[string][]string {
+	if len(environments) == 0 && len(labels) == 0 {
+		return nil
 	}
 
-	return a
+	s := make(map[string][]string, len(labels)+1)
+	for k, v := range labels {
+		s[k] = v
+	}
+	if len(environments) > 0 {
+		s[EnvironmentLabel] = append(s[EnvironmentLabel], environments...)
+	}
+
+	return s
 }
 
 type Comparable interface {
-	constraints.Ordered | bool
+	cmp.Ordered | bool
 }
 
 // Compare is a helper function to easily satisfies the compare function in the main Can function
@@ -167,21 +544,48 @@ func Compare[T Comparable](i, j T) func() bool {
 }
 
 // OpenFile takes a yaml file and returns a map of Roles
+// The file may include other role files with a top level include key
+// (see IncludeKey), their roles are merged with the file's own roles.
+// ${VAR} placeholders are replaced with environment variables (see
+// LookupEnv) before the file is parsed.
 // filename - yaml encoded file for parsing
 //
 // returns - a map of Roles and an error
 func OpenFile(filename string) (Roles, error) {
-	f, err := os.OpenFile(filename, os.O_RDONLY, 0600)
+	r, _, err := openFile(filename, nil)
+	return r, err
+}
+
+// Decode reads yaml encoded roles from r and returns a map of Roles.
+// Useful when roles are not stored on disk, such as in a KV store.
+// ${VAR} placeholders are expanded as in OpenFile.
+// r - a reader of yaml encoded roles
+//
+// returns - a map of Roles and an error
+func Decode(r io.Reader) (Roles, error) {
+	var doc yaml.Node
+	if err := yaml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+	if err := expandNode(&doc); err != nil {
+		return nil, err
+	}
+
+	includes, err := splitIncludes(&doc)
 	if err != nil {
 		return nil, err
 	}
+	if len(includes) > 0 {
+		return nil, fmt.Errorf("can: %s is only supported in files opened with OpenFile", IncludeKey)
+	}
+	splitReserved(&doc)
 
-	r := make(Roles)
-	if err := yaml.NewDecoder(f).Decode(&r); err != nil {
+	roles := make(Roles)
+	if err := doc.Decode(&roles); err != nil {
 		return nil, err
 	}
 
-	return r, nil
+	return roles, nil
 }
 
 // Config takes a per parsed config file and return a map of Roles.
@@ -190,12 +594,124 @@ func OpenFile(filename string) (Roles, error) {
 // c - a set of disk roles
 //
 // returns - a map of Roles
+//
+// Deprecated: Config ignores invalid abilities, cidrs, quotas and
+// colliding permissions, use ConfigErr to find them.
 func Config(c DiskRoles) Roles {
 	r := make(Roles)
 	buildRole(c, &r)
 	return r
 }
 
+// ConfigErr is Config, reporting every problem of the roles.
+// c - a set of disk roles
+//
+// returns - a map of Roles and an error if the roles are invalid
+func ConfigErr(c DiskRoles) (Roles, error) {
+	r := make(Roles)
+	if err := buildRole(c, &r); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Disk converts roles back into their config representation, the
+// inverse of Config. Routes are not restored: every permission key,
+// including derived route keys, becomes its own resource, which loads
+// back into the same roles.
+func (r Roles) Disk() DiskRoles {
+	d := make(DiskRoles, len(r))
+	for name, role := range r {
+		dr := make(DiskRole, len(role))
+		for key, p := range role {
+			var fields map[string][]string
+			if p.Fields != nil {
+				fields = make(map[string][]string, len(p.Fields))
+				for a, f := range p.Fields {
+					fields[a.text()] = f
+				}
+			}
+			dr[key] = DiskPermission{
+				Abilities:  abilityNames(p.Abilities),
+				Resource:   p.Resource,
+				Fields:     fields,
+				Labels:     p.Selectors,
+				Deny:       abilityNames(p.Denied),
+				Audit:      abilityNames(p.Audited),
+				Conditions: p.Conditions,
+				Quotas:     quotaNames(p.Quotas),
+				CIDR:       networkNames(p.Networks),
+			}
+		}
+		d[name] = dr
+	}
+
+	return d
+}
+
+// buildQuotas converts config representations of quotas, also returning
+// the names that aren't abilities and the quotas that can't be parsed.
+func buildQuotas(quotas map[string]string) (map[Ability]Quota, []string, error) {
+	q := make(map[Ability]Quota, len(quotas))
+	var unknown []string
+	var errs []error
+	for _, name := range sortedKeys(quotas) {
+		ability := parseAbility(name)
+		if ability == None {
+			unknown = append(unknown, name)
+			continue
+		}
+		parsed, err := ParseQuota(quotas[name])
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		q[ability] = parsed
+	}
+
+	return q, unknown, errors.Join(errs...)
+}
+
+// quotaNames returns the quotas keyed by ability name, or nil if there are none.
+func quotaNames(quotas map[Ability]Quota) map[string]string {
+	if len(quotas) == 0 {
+		return nil
+	}
+
+	names := make(map[string]string, len(quotas))
+	for a, q := range quotas {
+		names[a.text()] = q.String()
+	}
+
+	return names
+}
+
+// abilityNames returns the sorted names of a set of abilities, or nil if it is empty.
+func abilityNames(abilities map[Ability]struct{}) []string {
+	if len(abilities) == 0 {
+		return nil
+	}
+
+	sorted := make([]Ability, 0, len(abilities))
+	for a := range abilities {
+		sorted = append(sorted, a)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	names := make([]string, len(sorted))
+	for i, a := range sorted {
+		names[i] = a.text()
+	}
+
+	return names
+}
+
+// MarshalYAML implement the yaml Marshaler interface
+func (r Roles) MarshalYAML() (interface{}, error) {
+	return r.Disk(), nil
+}
+
 // Can is the heart and soul of the can package. It can take a custom compare function to do various authorization checking
 //
 // ctx - a standard ctx to pass to authorization. Useful for passing additional request specific data and canceling the can
@@ -212,37 +728,103 @@ func Config(c DiskRoles) Roles {
 //
 // returns a true or false if the role or permission is allowed.
 func Can(ctx context.Context, role Role, permission string, ability Ability, compare func() bool) bool {
-	if role == nil {
-		return false
-	}
+	return evaluate(ctx, role, permission, ability, compare, nil).Allow()
+}
 
-	perm, ok := role[permission]
+// evaluate implements Can, recording its steps in trace if not nil.
+// Explanations only read quotas.
+func evaluate(ctx context.Context, role Role, permission string, ability Ability, compare func() bool, trace *Explanation) Outcome {
+	_, o := evaluatePermission(ctx, role, permission, ability, compare, trace, trace != nil)
+	return o
+}
+
+// evaluatePermission is evaluate, also returning the permission of role
+// that decided the check. When peek is true, quotas are read without
+// being used.
+func evaluatePermission(ctx context.Context, role Role, permission string, ability Ability, compare func() bool, trace *Explanation, peek bool) (Permission, Outcome) {
+	if role == nil || permission == "" {
+		trace.step("no role or empty permission")
+		return Permission{}, Denied
+	}
+	perm, key, ok := lookupKey(role, permission)
 	if !ok {
-		return false
+		perm, key, ok = lookupAlias(role, permission, trace)
+	}
+	if !ok {
+		if trace != nil {
+			trace.step("permission %q not listed, unlisted permissions are %s", permission, Unlisted)
+		}
+		return Permission{}, outcome(unlistedAllowed(permission))
+	}
+	if trace != nil {
+		trace.Permission = key
+	}
+	if trace != nil {
+		trace.step("matched permission %q", key)
+	}
+
+	if !perm.Selected(LabelsFrom(ctx)) {
+		if trace != nil {
+			trace.step("labels %v don't match selectors %v", LabelsFrom(ctx), perm.Selectors)
+		}
+		return perm, Denied
+	}
+	if !inNetwork(ctx, perm, trace) {
+		return perm, Denied
+	}
+	if perm.Denies(ability) {
+		if trace != nil {
+			trace.step("%s is denied", ability)
+		}
+		return perm, Denied
 	}
 
 	_, ok = perm.Abilities[ability]
 	_, okAll := perm.Abilities[All]
 	_, okSkip := perm.Abilities[Skip]
 	if !ok && !okAll && !okSkip {
-		return false
+		if trace != nil {
+			trace.step("%s is not granted", ability)
+		}
+		return perm, Denied
+	}
+
+	switch conditionsMet(ctx, perm, trace) {
+	case Denied:
+		if trace != nil {
+			trace.step("conditions %v not met", perm.Conditions)
+		}
+		return perm, Denied
+	case Undecided:
+		return perm, Undecided
 	}
 
 	if okAll || okSkip {
-		return true
+		trace.step("all abilities are granted")
+		return perm, outcome(withinQuota(ctx, perm, key, ability, peek, trace))
 	}
 
 	switch ability {
 	case All, Skip:
-		return true
+		if trace != nil {
+			trace.step("%s is granted", ability)
+		}
+		return perm, outcome(withinQuota(ctx, perm, key, ability, peek, trace))
 	case Read, Create, Update, Delete:
 		if compare == nil {
-			return false
+			if trace != nil {
+				trace.step("%s is granted but there is no compare function", ability)
+			}
+			return perm, Denied
+		}
+		result := compare()
+		if trace != nil {
+			trace.step("%s is granted, compare returned %v", ability, result)
 		}
-		return compare()
+		return perm, outcome(result && withinQuota(ctx, perm, key, ability, peek, trace))
 	}
 
-	return false
+	return perm, Denied
 }
 
 // BuildFromMethod uses standard Rest conventions to build a
@@ -270,34 +852,41 @@ func BuildFromMethod(method string) Ability {
 }
 
 // PermissionFromPath uses the request path to build a permission
-// that can be used to check authorization in the Can function.
-// Uses the chi router context to build the permission.
+// that can be used to check authorization in the Can function. Every
+// path segment ends up in the permission, so /users/42 becomes users_42.
+// Use PermissionFromPattern with an http.ServeMux or PermissionFromPath
+// of the canchi package with chi to drop url params.
+//
+// Segments are percent-decoded and normalized to unicode NFC before the
+// key is built. Paths that can't be decoded safely, that contain control
+// characters or dot segments, or whose segments decode to a "/" are
+// rejected so encoded paths can't be used to reach another permission.
+// Use NormalizePath to learn why a path is rejected or to change how
+// the permission is built.
 //
 // r - a standard http request
 //
-// returns - a string representation of a permission
+// returns - a string representation of a permission, or "" if the path
+// is rejected. Can never allows the empty permission
 func PermissionFromPath(r *http.Request) string {
-	p := r.URL.Path
-
-	if p == "/" {
-		return "index"
-	}
-
-	if p[:3] == "/v1" {
-		p = p[3:]
-	}
-
-	c := chi.RouteContext(r.Context())
-	for _, v := range c.URLParams.Values {
-		if v == "" {
-			continue
-		}
-		p = strings.ReplaceAll(p, v, "")
-	}
+	return PermissionFromParams(r)
+}
 
-	if p[len(p)-1:] == "/" {
-		p = p[:len(p)-1]
+// PermissionFromParams is PermissionFromPath for routers with url
+// params: path segments matching one of params are dropped, so /users/42/books
+// becomes users_books when params holds 42. Params may be percent-encoded.
+//
+// r - a standard http request
+//
+// params - the url param values of the route that matched r
+//
+// returns - a string representation of a permission, or "" if the path
+// is rejected
+func PermissionFromParams(r *http.Request, params ...string) string {
+	permission, err := NormalizePath(r.URL.EscapedPath(), PathOptions{Params: params})
+	if err != nil {
+		return ""
 	}
 
-	return strings.ReplaceAll(p[1:], "/", "_")
+	return permission
 }
@@ -1,8 +1,11 @@
 package can
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"os"
+	"strings"
 	"testing"
 
 	"gopkg.in/yaml.v3"
@@ -55,6 +58,21 @@ func TestCan(t *testing.T) {
 	}
 }
 
+func TestCanAllocs(t *testing.T) {
+	role := NewRole().Allow("users", Read).Deny("users", Delete).Build()
+	ctx := context.Background()
+	compare := func() bool { return true }
+
+	allocs := testing.AllocsPerRun(100, func() {
+		Can(ctx, role, "users", Read, compare)
+		Can(ctx, role, "users", Delete, compare)
+		Can(ctx, role, "users", Update, compare)
+	})
+	if allocs != 0 {
+		t.Fatalf("expected checks without an explanation not to allocate, got %v allocs", allocs)
+	}
+}
+
 func TestOpenFile(t *testing.T) {
 	r, err := OpenFile("testdata/rbac.yml")
 	if err != nil {
@@ -107,3 +125,151 @@ func TestConfig(t *testing.T) {
 		t.Fatal("fail")
 	}
 }
+
+func TestPermissionCollision(t *testing.T) {
+	_, err := ConfigErr(DiskRoles{
+		"admin": DiskRole{
+			"users":       DiskPermission{Abilities: []string{"read"}, Routes: []string{"admin"}},
+			"users_admin": DiskPermission{Abilities: []string{"all"}},
+		},
+	})
+	if !errors.Is(err, ErrPermissionCollision) {
+		t.Fatalf("expected collision, got %v", err)
+	}
+
+	_, err = ConfigErr(DiskRoles{
+		"admin": DiskRole{
+			"users": DiskPermission{Abilities: []string{"read"}, Routes: []string{"admin", "/admin/"}},
+		},
+	})
+	if !errors.Is(err, ErrPermissionCollision) {
+		t.Fatalf("expected duplicate route collision, got %v", err)
+	}
+}
+
+func TestPermissionKey(t *testing.T) {
+	if k := PermissionKey("books", "search/advanced"); k != "books_search_advanced" {
+		t.Fatalf("unexpected key %q", k)
+	}
+	if k := PermissionKey("accounts", "{id}/invoices/{iid:[0-9]+}/lines/"); k != "accounts_invoices_lines" {
+		t.Fatalf("expected url params to be dropped, got %q", k)
+	}
+	if _, err := ConfigErr(DiskRoles{"user": DiskRole{"accounts": DiskPermission{Abilities: []string{"read"}, Routes: []string{"{id}"}}}}); !errors.Is(err, ErrPermissionCollision) {
+		t.Fatalf("expected a route of params only to collide with its resource, got %v", err)
+	}
+
+	defer func(s string) { Separator = s }(Separator)
+	Separator = ":"
+
+	r, err := ConfigErr(DiskRoles{
+		"admin": DiskRole{
+			"users":       DiskPermission{Abilities: []string{"read"}, Routes: []string{"admin"}},
+			"users_admin": DiskPermission{Abilities: []string{"all"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := r["admin"]["users:admin"]; !ok {
+		t.Fatal("expected route key with custom separator")
+	}
+}
+
+func TestRolesDisk(t *testing.T) {
+	r, err := OpenFile("testdata/rbac.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := yaml.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Decode(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rolesRevision(loaded) != rolesRevision(r) {
+		t.Fatalf("expected roles to round trip, got\n%s", b)
+	}
+}
+
+func TestUnlisted(t *testing.T) {
+	r, err := OpenFile("testdata/rbac.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if Can(ctx, r["user"], "reports", Read, Compare(1, 1)) {
+		t.Fatal("expected unlisted permission to be denied by default")
+	}
+
+	defer func(e Effect) { Unlisted = e }(Unlisted)
+	Unlisted = Allow
+
+	if !Can(ctx, r["user"], "reports", Read, nil) {
+		t.Fatal("expected unlisted permission to be allowed")
+	}
+	if Can(ctx, r["user"], "users", Create, nil) {
+		t.Fatal("expected listed permission to keep its abilities")
+	}
+	if Can(ctx, nil, "reports", Read, nil) || Can(ctx, r["user"], "", Read, nil) {
+		t.Fatal("expected missing roles and rejected paths to be denied")
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	_, err := OpenFiles("testdata/invalid/*.yml")
+	if err == nil {
+		t.Fatal("expected the invalid files to fail")
+	}
+	if !errors.Is(err, ErrUnknownAbility) || !errors.Is(err, ErrPermissionCollision) {
+		t.Fatalf("expected every kind of problem, got %v", err)
+	}
+
+	for _, want := range []string{"write", "remove", "edit", "modify", "books_admin", "a.yml", "b.yml"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected the error to mention %q, got:\n%v", want, err)
+		}
+	}
+}
+
+func TestCustomAbilityText(t *testing.T) {
+	const approve, publish = Ability(10), Ability(11)
+	for _, tt := range []struct {
+		ability Ability
+		text    string
+	}{{Read, "read"}, {None, "none"}, {approve, "10"}, {Ability(-2), "-2"}} {
+		b, err := tt.ability.MarshalText()
+		if err != nil || string(b) != tt.text {
+			t.Fatalf("expected %d to be encoded as %q, got %q %v", tt.ability, tt.text, b, err)
+		}
+		var a Ability
+		if err := a.UnmarshalText(b); err != nil || a != tt.ability {
+			t.Fatalf("expected %q to decode to %d, got %d %v", b, tt.ability, a, err)
+		}
+	}
+	for _, text := range []string{"4", "6", "010", "fly"} {
+		var a Ability
+		if a.UnmarshalText([]byte(text)); a != None {
+			t.Fatalf("expected %q to decode to none, got %d", text, a)
+		}
+	}
+
+	roles := Roles{"editor": Role{"posts": Permission{Abilities: map[Ability]struct{}{approve: {}}}}}
+	back, err := ConfigErr(roles.Disk())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := back["editor"]["posts"].Abilities[approve]; !ok {
+		t.Fatalf("expected the custom ability to survive a disk round trip, got %v", back["editor"]["posts"])
+	}
+
+	other := Role{"posts": Permission{Abilities: map[Ability]struct{}{publish: {}}}}
+	if roleHash(roles["editor"]) == roleHash(other) {
+		t.Fatal("expected roles with different custom abilities to hash differently")
+	}
+}
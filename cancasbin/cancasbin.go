@@ -0,0 +1,207 @@
+// Package cancasbin converts between can roles and Casbin CSV policies,
+// for teams migrating from or to Casbin. It covers the RBAC subset both
+// support: allow and deny rules of roles on resources, and the roles of
+// users or groups. It doesn't depend on Casbin itself.
+package cancasbin
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/acmacalister/can"
+)
+
+// ErrUnsupported is returned by Export for permissions Casbin's RBAC
+// model can't express, such as field restrictions, label selectors,
+// conditions, audit requirements, networks, quotas and hierarchical or
+// wildcard keys.
+var ErrUnsupported = errors.New("cancasbin: unsupported permission")
+
+// Model is a Casbin model matching the policies written by Export: role
+// based access with deny rules overriding allow rules and the all and
+// skip actions granting every action. Objects match exactly, unlike
+// the hierarchical keys of can.
+const Model = `[request_definition]
+r = sub, obj, act
+
+[policy_definition]
+p = sub, obj, act, eft
+
+[role_definition]
+g = _, _
+
+[policy_effect]
+e = some(where (p.eft == allow)) && !some(where (p.eft == deny))
+
+[matchers]
+m = g(r.sub, p.sub) && r.obj == p.obj && (r.act == p.act || p.act == "all" || (p.act == "skip" && p.eft == "allow"))
+`
+
+const (
+	allow = "allow"
+	deny  = "deny"
+)
+
+// Export writes roles as Casbin policy lines, one per role, permission,
+// ability and effect, sorted:
+//
+//	p, admin, users, all, allow
+//	p, user, billing, delete, deny
+//
+// w - the writer receiving the CSV policy
+//
+// r - the roles to export
+//
+// returns - ErrUnsupported if a permission can't be expressed, in which
+// case nothing is written
+func Export(w io.Writer, r can.Roles) error {
+	var lines []string
+	for name, role := range r {
+		for permission, p := range role {
+			if len(p.Fields) > 0 || len(p.Selectors) > 0 || len(p.Conditions) > 0 || len(p.Audited) > 0 || len(p.Networks) > 0 || len(p.Quotas) > 0 {
+				return fmt.Errorf("%w: role %q permission %q has fields, selectors, conditions, audit requirements, networks or quotas", ErrUnsupported, name, permission)
+			}
+			if strings.ContainsAny(name+permission, ",\r\n\"") {
+				return fmt.Errorf("%w: role %q permission %q can't be written as csv", ErrUnsupported, name, permission)
+			}
+			if strings.Contains(permission, can.Wildcard) || (can.HierarchySeparator != "" && strings.Contains(permission, can.HierarchySeparator)) {
+				return fmt.Errorf("%w: role %q permission %q is a hierarchical or wildcard key, Model matches objects exactly", ErrUnsupported, name, permission)
+			}
+			for a := range p.Abilities {
+				lines = append(lines, policyLine(name, permission, a, allow))
+			}
+			for a := range p.Denied {
+				lines = append(lines, policyLine(name, permission, a, deny))
+			}
+		}
+	}
+	sort.Strings(lines)
+
+	for _, line := range lines {
+		if _, err := io.WriteString(w, line+"\n"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ExportGroups writes group assignments as Casbin grouping lines, such
+// as g, alice, admin.
+func ExportGroups(w io.Writer, g can.Groups) error {
+	var lines []string
+	for member, roles := range g {
+		for _, role := range roles {
+			lines = append(lines, strings.Join([]string{"g", member, role}, ", "))
+		}
+	}
+	sort.Strings(lines)
+
+	for _, line := range lines {
+		if _, err := io.WriteString(w, line+"\n"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Import reads a Casbin CSV policy. p lines with subject, object,
+// action and an optional allow or deny effect become permissions of
+// the subject's role; g lines become group assignments (see
+// can.Groups). Actions are ability names or the numbers of custom
+// abilities, as Export writes them, and * is read as all. Blank lines
+// and lines starting with # are skipped.
+//
+// rd - the CSV policy
+//
+// returns - the roles, the groups and an error for lines can't express
+func Import(rd io.Reader) (can.Roles, can.Groups, error) {
+	cr := csv.NewReader(rd)
+	cr.TrimLeadingSpace = true
+	cr.FieldsPerRecord = -1
+	cr.Comment = '#'
+
+	roles, groups := make(can.Roles), make(can.Groups)
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		line, _ := cr.FieldPos(0)
+
+		switch record[0] {
+		case "p":
+			if len(record) != 4 && len(record) != 5 {
+				return nil, nil, fmt.Errorf("cancasbin: line %d: expected p, sub, obj, act[, eft]", line)
+			}
+			if err := addPolicy(roles, record[1:]); err != nil {
+				return nil, nil, fmt.Errorf("cancasbin: line %d: %w", line, err)
+			}
+		case "g":
+			if len(record) != 3 {
+				return nil, nil, fmt.Errorf("cancasbin: line %d: expected g, member, role", line)
+			}
+			groups[record[1]] = append(groups[record[1]], record[2])
+		default:
+			return nil, nil, fmt.Errorf("cancasbin: line %d: unsupported policy type %q", line, record[0])
+		}
+	}
+
+	return roles, groups, nil
+}
+
+// addPolicy adds a p line without its type to roles.
+func addPolicy(roles can.Roles, record []string) error {
+	sub, obj, act := record[0], record[1], record[2]
+	eft := allow
+	if len(record) == 4 {
+		eft = record[3]
+	}
+
+	ability := can.All
+	if act != "*" {
+		ability.UnmarshalText([]byte(act))
+		if ability == can.None && act != "none" {
+			return fmt.Errorf("unsupported action %q", act)
+		}
+	}
+
+	role := roles[sub]
+	if role == nil {
+		role = make(can.Role)
+		roles[sub] = role
+	}
+	p := role[obj]
+	if p.Abilities == nil {
+		p.Abilities = make(map[can.Ability]struct{})
+	}
+
+	switch eft {
+	case allow:
+		p.Abilities[ability] = struct{}{}
+	case deny:
+		if p.Denied == nil {
+			p.Denied = make(map[can.Ability]struct{})
+		}
+		p.Denied[ability] = struct{}{}
+	default:
+		return fmt.Errorf("unsupported effect %q", eft)
+	}
+	role[obj] = p
+
+	return nil
+}
+
+// policyLine formats a p line.
+func policyLine(role, permission string, a can.Ability, eft string) string {
+	act, _ := a.MarshalText()
+	return strings.Join([]string{"p", role, permission, string(act), eft}, ", ")
+}
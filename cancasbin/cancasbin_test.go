@@ -0,0 +1,102 @@
+package cancasbin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/acmacalister/can"
+)
+
+const policy = `# exported from casbin
+p, admin, users, *
+p, admin, billing, read, allow
+p, user, users, read
+p, user, users, delete, deny
+
+g, alice, admin
+g, bob, user
+`
+
+func TestImport(t *testing.T) {
+	roles, groups, err := Import(strings.NewReader(policy))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	yes := func() bool { return true }
+	if !can.Can(ctx, roles["admin"], "users", can.Delete, yes) || !can.Can(ctx, roles["admin"], "billing", can.Read, yes) {
+		t.Fatalf("unexpected admin role %v", roles["admin"])
+	}
+	if !can.Can(ctx, roles["user"], "users", can.Read, yes) || can.Can(ctx, roles["user"], "users", can.Delete, yes) {
+		t.Fatalf("unexpected user role %v", roles["user"])
+	}
+	if !reflect.DeepEqual(groups, can.Groups{"alice": {"admin"}, "bob": {"user"}}) {
+		t.Fatalf("unexpected groups %v", groups)
+	}
+
+	for _, bad := range []string{"p, admin, users", "p, admin, users, write", "p, admin, users, read, maybe", "g, alice", "x, y"} {
+		if _, _, err := Import(strings.NewReader(bad)); err == nil {
+			t.Errorf("expected %q to fail", bad)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	r := can.Roles{
+		"admin":   can.NewRole().Allow("users", can.All).Allow("billing", can.Read).Build(),
+		"support": can.NewRole().Allow("books", can.Read, can.Update).Deny("books", can.Delete).Build(),
+		"custom":  can.NewRole().Allow("reports", can.Ability(256)).Allow("health", can.Skip).Build(),
+	}
+
+	var first bytes.Buffer
+	if err := Export(&first, r); err != nil {
+		t.Fatal(err)
+	}
+
+	imported, _, err := Import(bytes.NewReader(first.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var second bytes.Buffer
+	if err := Export(&second, imported); err != nil {
+		t.Fatal(err)
+	}
+	if first.String() != second.String() {
+		t.Fatalf("expected the policy to round trip:\n%s\n%s", first.String(), second.String())
+	}
+
+	g := can.Groups{"alice": {"admin", "user"}}
+	var buf bytes.Buffer
+	if err := ExportGroups(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+	if _, groups, err := Import(&buf); err != nil || !reflect.DeepEqual(groups, g) {
+		t.Fatalf("expected the groups to round trip, got %v %v", groups, err)
+	}
+
+	for _, p := range []can.Permission{
+		can.NewRole().Allow("reports", can.Read).Where("reports", "region", "eu").Build()["reports"],
+		{Abilities: map[can.Ability]struct{}{can.Read: {}}, Networks: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}},
+		{Abilities: map[can.Ability]struct{}{can.Create: {}}, Quotas: map[can.Ability]can.Quota{can.Create: {Limit: 5, Per: time.Hour}}},
+	} {
+		limited := can.Roles{"user": can.Role{"reports": p}}
+		if err := Export(&bytes.Buffer{}, limited); !errors.Is(err, ErrUnsupported) {
+			t.Fatalf("expected ErrUnsupported for %+v, got %v", p, err)
+		}
+	}
+
+	for _, key := range []string{"users/42", "users/*", "*"} {
+		keyed := can.Roles{"user": can.NewRole().Allow(key, can.Read).Build()}
+		if err := Export(&bytes.Buffer{}, keyed); !errors.Is(err, ErrUnsupported) {
+			t.Fatalf("expected ErrUnsupported for %q, got %v", key, err)
+		}
+	}
+}
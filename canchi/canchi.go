@@ -0,0 +1,43 @@
+// Package canchi integrates can with the chi router. It is kept out of
+// the can package so services that don't use chi don't depend on it.
+package canchi
+
+import (
+	"net/http"
+
+	"github.com/acmacalister/can"
+	"github.com/go-chi/chi/v5"
+)
+
+// PermissionFromPath builds a permission from the request path, dropping
+// the path segments held by chi url params, so /users/42/books routed by
+// /users/{id}/books becomes users_books. Use it as the Permission of a
+// can.Guard registered after routing, e.g. with chi's With or Group:
+//
+//	guard := &can.Guard{Permission: canchi.PermissionFromPath}
+//	r.With(guard.Middleware).Get("/users/{id}", getUser)
+//
+// r - a standard http request routed by chi
+//
+// returns - a string representation of a permission, or "" if the path
+// is rejected (see can.PermissionFromPath)
+func PermissionFromPath(r *http.Request) string {
+	c := chi.RouteContext(r.Context())
+	if c == nil {
+		return can.PermissionFromPath(r)
+	}
+
+	return can.PermissionFromParams(r, c.URLParams.Values...)
+}
+
+// URLParamAttribute extracts the value of a chi url param, e.g. the owner
+// ID of /users/{id}/exports, for can.ExtractAttributes. Url params are
+// only set once a request is routed, register the middleware with chi's
+// With or Group.
+func URLParamAttribute(name, param string) can.AttributeExtractor {
+	return can.AttributeExtractorFunc(func(r *http.Request, a can.Attributes) {
+		if v := chi.URLParam(r, param); v != "" {
+			a[name] = v
+		}
+	})
+}
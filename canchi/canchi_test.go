@@ -0,0 +1,85 @@
+package canchi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/acmacalister/can"
+	"github.com/go-chi/chi/v5"
+)
+
+func TestPermissionFromPath(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/v1/users/42/books", nil)
+	if got := PermissionFromPath(req); got != "users_42_books" {
+		t.Fatalf("expected every segment without a chi context, got %q", got)
+	}
+
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "42")
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	if got := PermissionFromPath(req); got != "users_books" {
+		t.Fatalf("expected url params to be dropped, got %q", got)
+	}
+}
+
+func TestGuard(t *testing.T) {
+	r, err := can.OpenFile("../testdata/rbac.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := &can.Guard{DefaultRole: r["user"], Permission: PermissionFromPath}
+
+	router := chi.NewRouter()
+	// the guard runs after routing, so chi has resolved the url params
+	guarded := router.With(g.Middleware)
+	guarded.Get("/users/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	guarded.Post("/users", func(w http.ResponseWriter, r *http.Request) {})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/42", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected user to read users, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/users", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected the anonymous user to be denied create, got %d", w.Code)
+	}
+}
+
+func TestNestedRoute(t *testing.T) {
+	r, err := can.ConfigErr(can.DiskRoles{
+		"user": can.DiskRole{"accounts": can.DiskPermission{Abilities: []string{"all"}, Routes: []string{"{id}/invoices/{iid}/lines"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var permission string
+	router := chi.NewRouter()
+	router.Get("/accounts/{id}/invoices/{iid}/lines", func(w http.ResponseWriter, r *http.Request) {
+		permission = PermissionFromPath(r)
+	})
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/accounts/1/invoices/2/lines", nil))
+
+	if _, ok := r["user"][permission]; !ok || permission != "accounts_invoices_lines" {
+		t.Fatalf("expected the route key to match the path, got %q", permission)
+	}
+}
+
+func TestURLParamAttribute(t *testing.T) {
+	var got can.Attributes
+	r := chi.NewRouter()
+	r.With(can.ExtractAttributes(URLParamAttribute("owner", "id"))).Get("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		got = can.AttributesFrom(r.Context())
+	})
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/42", nil))
+	if got["owner"] != "42" {
+		t.Fatalf("expected the url param, got %v", got)
+	}
+}
@@ -0,0 +1,111 @@
+package canchi
+
+import (
+	"context"
+	"net/http"
+	"sort"
+
+	"github.com/acmacalister/can"
+	"github.com/go-chi/chi/v5"
+)
+
+// MountGuarded guards every route of r whose permission one of roles
+// configures, such as /users/{id}/books for the route {id}/books of the
+// resource users, so no route of the role file is served without a
+// check. Routes whose permission no role configures are served as is,
+// use the guard's Middleware with chi's With to guard them too.
+//
+// Like any chi middleware, call it before registering the routes of r.
+// It matches the route of every request ahead of chi to derive the
+// permission from its pattern, the url params of the route are set
+// when the guard's Compare runs.
+//
+// r - the router
+//
+// roles - the roles whose permissions are guarded
+//
+// guard - the guard making the checks, its Permission is ignored
+func MountGuarded(r chi.Router, roles can.Roles, guard *can.Guard) {
+	configured := permissions(roles)
+
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			pattern, rctx, ok := match(r, req)
+			if !ok {
+				next.ServeHTTP(w, req)
+				return
+			}
+			permission := patternPermission(req, pattern)
+			if _, ok := configured[permission]; !ok {
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			routed := req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			if !guard.Check(w, routed, permission, can.BuildFromMethod(req.Method)) {
+				return
+			}
+
+			next.ServeHTTP(w, req)
+		})
+	})
+}
+
+// Unrouted returns the sorted permissions roles configure that no route
+// of r maps to, such as a resource whose routes were renamed. Call it
+// once the routes are registered, in a test or when the service starts.
+func Unrouted(r chi.Routes, roles can.Roles) ([]string, error) {
+	unrouted := permissions(roles)
+	err := chi.Walk(r, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		delete(unrouted, patternPermission(&http.Request{}, route))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(unrouted))
+	for name := range unrouted {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// permissions returns the permission keys of roles.
+func permissions(roles can.Roles) map[string]struct{} {
+	keys := make(map[string]struct{})
+	for _, role := range roles {
+		for key := range role {
+			keys[key] = struct{}{}
+		}
+	}
+
+	return keys
+}
+
+// match finds the route of req in r, returning its pattern and a route
+// context holding its url params.
+func match(r chi.Routes, req *http.Request) (string, *chi.Context, bool) {
+	path := req.URL.RawPath
+	if path == "" {
+		path = req.URL.Path
+	}
+
+	rctx := chi.NewRouteContext()
+	if !r.Match(rctx, req.Method, path) {
+		return "", nil, false
+	}
+
+	return rctx.RoutePattern(), rctx, true
+}
+
+// patternPermission builds the permission of a chi route pattern, url
+// params dropped (see can.PermissionFromPattern).
+func patternPermission(req *http.Request, pattern string) string {
+	shallow := *req
+	shallow.Pattern = pattern
+
+	return can.PermissionFromPattern(&shallow)
+}
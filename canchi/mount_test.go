@@ -0,0 +1,62 @@
+package canchi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/acmacalister/can"
+	"github.com/go-chi/chi/v5"
+)
+
+func TestMountGuarded(t *testing.T) {
+	roles, err := can.ConfigErr(can.DiskRoles{
+		"user": can.DiskRole{
+			"users":    can.DiskPermission{Abilities: []string{"read"}, Routes: []string{"{id}/books"}},
+			"accounts": can.DiskPermission{Abilities: []string{"read"}, Routes: []string{"{id}/invoices"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var owner string
+	guard := &can.Guard{DefaultRole: roles["user"], Compare: func(r *http.Request) func() bool {
+		owner = chi.URLParam(r, "id")
+		return func() bool { return true }
+	}}
+	router := chi.NewRouter()
+	MountGuarded(router, roles, guard)
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	router.Get("/users/{id}/books", ok)
+	router.Delete("/users/{id}/books", ok)
+	router.Get("/health", ok)
+
+	for _, tt := range []struct {
+		method, path string
+		want         int
+	}{
+		{http.MethodGet, "/users/42/books", http.StatusOK},
+		{http.MethodDelete, "/users/42/books", http.StatusUnauthorized},
+		{http.MethodGet, "/health", http.StatusOK},
+		{http.MethodGet, "/nope", http.StatusNotFound},
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		if w.Code != tt.want {
+			t.Fatalf("expected %s %s to return %d, got %d", tt.method, tt.path, tt.want, w.Code)
+		}
+	}
+	if owner != "42" {
+		t.Fatalf("expected the url params to be set for Compare, got %q", owner)
+	}
+
+	unrouted, err := Unrouted(router, roles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"accounts", "accounts_invoices", "users"}; !reflect.DeepEqual(unrouted, want) {
+		t.Fatalf("expected %v without a route, got %v", want, unrouted)
+	}
+}
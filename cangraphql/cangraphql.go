@@ -0,0 +1,251 @@
+// Package cangraphql authorizes GraphQL resolvers with can. It provides
+// the handler of a @can directive and a field middleware calling
+// can.Can per resolver. The handlers have the shape gqlgen expects but
+// don't import it, so the can module doesn't depend on a GraphQL server;
+// with gqlgen, wrap them in a one line closure:
+//
+//	guard := &cangraphql.Guard{Field: func(ctx context.Context) (cangraphql.Field, bool) {
+//		fc, oc := graphql.GetFieldContext(ctx), graphql.GetOperationContext(ctx)
+//		if fc == nil || oc == nil {
+//			return cangraphql.Field{}, false
+//		}
+//		root := fc.Object == "Query" || fc.Object == "Mutation" || fc.Object == "Subscription"
+//		return cangraphql.Field{Operation: string(oc.Operation.Operation), Name: fc.Field.Name, Root: root}, true
+//	}}
+//
+//	cfg.Directives.Can = func(ctx context.Context, obj any, next graphql.Resolver, permission string, ability *string) (any, error) {
+//		return guard.Directive(ctx, obj, next, permission, ability)
+//	}
+//	srv.AroundFields(func(ctx context.Context, next graphql.Resolver) (any, error) {
+//		return guard.Middleware(ctx, next)
+//	})
+package cangraphql
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"unicode"
+
+	"github.com/acmacalister/can"
+)
+
+// ErrForbidden is returned by resolvers the role may not call.
+var ErrForbidden = errors.New("cangraphql: forbidden")
+
+// Operation types.
+const (
+	Query        = "query"
+	Mutation     = "mutation"
+	Subscription = "subscription"
+)
+
+// Resolver resolves a field, it has the type of gqlgen's graphql.Resolver.
+type Resolver = func(ctx context.Context) (any, error)
+
+// Field describes the field being resolved.
+type Field struct {
+	// Operation is the type of the operation, Query, Mutation or Subscription.
+	Operation string
+	// Name is the name of the field, e.g. createUser.
+	Name string
+	// Root is set for the fields of the root types, the entry points of
+	// an operation.
+	Root bool
+}
+
+// Guard authorizes GraphQL resolvers. The zero value authorizes
+// directives with the role stored in the ctx (see can.WithRole), but
+// needs Field for Middleware.
+type Guard struct {
+	// Authorizer makes the decisions. Defaults to can.Local.
+	Authorizer can.Authorizer
+	// Role returns the role of the request. Defaults to can.RoleFrom.
+	Role func(ctx context.Context) (can.Role, bool)
+	// Field describes the field being resolved, for Middleware and for
+	// directives without an ability. When nil, or when it returns false,
+	// Middleware denies and directives default to can.Read.
+	Field func(ctx context.Context) (Field, bool)
+	// Permission derives the permission of a root field in Middleware.
+	// Defaults to PermissionFromField.
+	Permission func(field string) string
+	// Compare builds the compare function for a resolver and the object
+	// it resolves a field of. When nil, compare is always satisfied.
+	Compare func(ctx context.Context, obj any) func() bool
+}
+
+// Directive is the handler of a directive such as
+//
+//	directive @can(permission: String!, ability: String) on FIELD_DEFINITION
+//
+// It resolves the field if the role holds ability on permission. When
+// ability is nil, it is derived from the field (see AbilityFor).
+//
+// ctx - the resolver ctx
+//
+// obj - the object the field belongs to, passed to Compare
+//
+// next - the field's resolver
+//
+// permission - defines the permission to check
+//
+// ability - the name of the ability to check, may be nil
+//
+// returns - the result of next, or ErrForbidden
+func (g *Guard) Directive(ctx context.Context, obj any, next Resolver, permission string, ability *string) (any, error) {
+	a := can.Read
+	switch {
+	case ability != nil:
+		a = can.StringToAbility(*ability)
+	case g.Field != nil:
+		if f, ok := g.Field(ctx); ok {
+			a = AbilityFor(f.Operation, f.Name)
+		}
+	}
+	if a == can.None {
+		return nil, ErrForbidden
+	}
+
+	if !g.can(ctx, obj, permission, a) {
+		return nil, ErrForbidden
+	}
+
+	return next(ctx)
+}
+
+// Middleware is a field middleware authorizing every root field by
+// convention: the permission is derived from the field name with
+// Permission and the ability with AbilityFor. Nested fields are
+// resolved without a check, guard them with the directive.
+//
+// ctx - the resolver ctx
+//
+// next - the field's resolver
+//
+// returns - the result of next, or ErrForbidden
+func (g *Guard) Middleware(ctx context.Context, next Resolver) (any, error) {
+	if g.Field == nil {
+		return nil, ErrForbidden
+	}
+
+	f, ok := g.Field(ctx)
+	if !ok {
+		return nil, ErrForbidden
+	}
+	if !f.Root {
+		return next(ctx)
+	}
+
+	permission := PermissionFromField(f.Name)
+	if g.Permission != nil {
+		permission = g.Permission(f.Name)
+	}
+	if !g.can(ctx, nil, permission, AbilityFor(f.Operation, f.Name)) {
+		return nil, ErrForbidden
+	}
+
+	return next(ctx)
+}
+
+// can checks ability on permission for the role of ctx.
+func (g *Guard) can(ctx context.Context, obj any, permission string, ability can.Ability) bool {
+	roleFrom := g.Role
+	if roleFrom == nil {
+		roleFrom = can.RoleFrom
+	}
+	role, ok := roleFrom(ctx)
+	if !ok {
+		return false
+	}
+
+	compare := func() bool { return true }
+	if g.Compare != nil {
+		compare = g.Compare(ctx, obj)
+	}
+
+	authorizer := g.Authorizer
+	if authorizer == nil {
+		authorizer = can.Local
+	}
+
+	return authorizer.Authorize(ctx, role, permission, ability, compare)
+}
+
+// verbs maps the verbs mutation names conventionally start with to
+// their ability.
+var verbs = []struct {
+	prefix  string
+	ability can.Ability
+}{
+	{"create", can.Create},
+	{"add", can.Create},
+	{"new", can.Create},
+	{"update", can.Update},
+	{"set", can.Update},
+	{"edit", can.Update},
+	{"delete", can.Delete},
+	{"remove", can.Delete},
+}
+
+// AbilityFor returns the conventional ability of a field. Queries and
+// subscriptions read. Mutations create, update or delete depending on
+// the verb their name starts with, as in createUser, updateUser or
+// deleteUser; other mutations update.
+//
+// operation - the operation type, e.g. Mutation
+//
+// field - the name of the field, e.g. createUser
+//
+// returns - the ability
+func AbilityFor(operation, field string) can.Ability {
+	if operation != Mutation {
+		return can.Read
+	}
+
+	for _, v := range verbs {
+		if hasVerb(field, v.prefix) {
+			return v.ability
+		}
+	}
+
+	return can.Update
+}
+
+// PermissionFromField derives a permission from a field name: a leading
+// verb (see AbilityFor) is dropped and the rest is converted to snake
+// case, so createUserProfile and userProfile both become user_profile.
+//
+// field - the name of the field
+//
+// returns - the permission
+func PermissionFromField(field string) string {
+	for _, v := range verbs {
+		if hasVerb(field, v.prefix) {
+			field = field[len(v.prefix):]
+			break
+		}
+	}
+
+	var b strings.Builder
+	for i, r := range field {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// hasVerb reports whether field starts with the word verb, as in
+// createUser but not created.
+func hasVerb(field, verb string) bool {
+	if !strings.HasPrefix(field, verb) || len(field) == len(verb) {
+		return false
+	}
+
+	return unicode.IsUpper(rune(field[len(verb)]))
+}
@@ -0,0 +1,90 @@
+package cangraphql
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/acmacalister/can"
+)
+
+func TestAbilityFor(t *testing.T) {
+	tests := []struct {
+		operation, field string
+		want             can.Ability
+	}{
+		{Query, "users", can.Read},
+		{Subscription, "userCreated", can.Read},
+		{Mutation, "createUser", can.Create},
+		{Mutation, "addComment", can.Create},
+		{Mutation, "deleteUser", can.Delete},
+		{Mutation, "updateUser", can.Update},
+		{Mutation, "created", can.Update},
+		{Mutation, "publish", can.Update},
+	}
+
+	for _, tt := range tests {
+		if got := AbilityFor(tt.operation, tt.field); got != tt.want {
+			t.Fatalf("AbilityFor(%s, %s) = %s, want %s", tt.operation, tt.field, got, tt.want)
+		}
+	}
+
+	for field, want := range map[string]string{"users": "users", "createUserProfile": "user_profile", "settings": "settings"} {
+		if got := PermissionFromField(field); got != want {
+			t.Fatalf("PermissionFromField(%s) = %s, want %s", field, got, want)
+		}
+	}
+}
+
+func TestGuard(t *testing.T) {
+	role := can.NewRole().Allow("users", can.Read).Allow("posts", can.Create).Build()
+	ctx := can.WithRole(context.Background(), role)
+
+	var field Field
+	g := &Guard{Field: func(ctx context.Context) (Field, bool) { return field, true }}
+
+	resolved := 0
+	next := func(ctx context.Context) (any, error) {
+		resolved++
+		return "ok", nil
+	}
+
+	read, create := "read", "create"
+	if _, err := g.Directive(ctx, nil, next, "users", &read); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.Directive(ctx, nil, next, "users", &create); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected create to be forbidden, got %v", err)
+	}
+	field = Field{Operation: Mutation, Name: "createPost"}
+	if _, err := g.Directive(ctx, nil, next, "posts", nil); err != nil {
+		t.Fatalf("expected the ability to be derived from the field, got %v", err)
+	}
+
+	tests := []struct {
+		field Field
+		err   error
+	}{
+		{Field{Operation: Query, Name: "users", Root: true}, nil},
+		{Field{Operation: Mutation, Name: "deleteUsers", Root: true}, ErrForbidden},
+		{Field{Operation: Mutation, Name: "createPosts", Root: true}, nil},
+		{Field{Operation: Query, Name: "secret"}, nil}, // nested fields are not checked
+	}
+	for _, tt := range tests {
+		field = tt.field
+		if _, err := g.Middleware(ctx, next); !errors.Is(err, tt.err) {
+			t.Fatalf("%+v: expected %v, got %v", tt.field, tt.err, err)
+		}
+	}
+
+	if resolved != 5 {
+		t.Fatalf("expected 5 resolved fields, got %d", resolved)
+	}
+
+	if _, err := (&Guard{}).Middleware(ctx, next); !errors.Is(err, ErrForbidden) {
+		t.Fatal("expected Middleware without Field to deny")
+	}
+	if _, err := g.Directive(context.Background(), nil, next, "users", &read); !errors.Is(err, ErrForbidden) {
+		t.Fatal("expected requests without a role to be forbidden")
+	}
+}
@@ -0,0 +1,165 @@
+// Package canhcl reads can role files written in HCL, in the style of
+// Terraform, one role block per role and one permission block per
+// resource:
+//
+//	role "user" {
+//	  permission "users" {
+//	    abilities = ["read", "update"]
+//	    routes    = ["{id}/books"]
+//	    fields = {
+//	      update = ["display_name", "avatar"]
+//	    }
+//	  }
+//	}
+//
+// The arguments of a permission are the fields of can.DiskPermission,
+// the roles are built with can.Config. Only literal values are
+// supported, there are no variables or functions.
+package canhcl
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/acmacalister/can"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// file is the structure of a role file.
+type file struct {
+	Roles []role `hcl:"role,block"`
+}
+
+type role struct {
+	Name        string       `hcl:"name,label"`
+	Permissions []permission `hcl:"permission,block"`
+}
+
+type permission struct {
+	Name         string              `hcl:"name,label"`
+	Abilities    []string            `hcl:"abilities,optional"`
+	Routes       []string            `hcl:"routes,optional"`
+	Resource     string              `hcl:"resource,optional"`
+	Fields       map[string][]string `hcl:"fields,optional"`
+	Environments []string            `hcl:"environments,optional"`
+	Labels       map[string][]string `hcl:"labels,optional"`
+	Deny         []string            `hcl:"deny,optional"`
+	Conditions   []string            `hcl:"conditions,optional"`
+	Audit        []string            `hcl:"audit,optional"`
+	Quotas       map[string]string   `hcl:"quotas,optional"`
+	CIDR         []string            `hcl:"cidr,optional"`
+	// Remain holds unknown arguments, rejected unless can.Strict is false
+	Remain hcl.Body `hcl:",remain"`
+}
+
+// Decode reads HCL encoded roles from r. Problems are reported with
+// their position, and unknown arguments with can.ErrUnknownField unless
+// can.Strict is false.
+// r - a reader of HCL encoded roles
+//
+// filename - the name of the file in errors, may be empty
+//
+// returns - a map of Roles and an error
+func Decode(r io.Reader, filename string) (can.Roles, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	f, diags := hclsyntax.ParseConfig(src, filename, hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	var doc file
+	if diags := gohcl.DecodeBody(f.Body, nil, &doc); diags.HasErrors() {
+		return nil, diags
+	}
+
+	var errs []error
+	disk := make(can.DiskRoles, len(doc.Roles))
+	for _, r := range doc.Roles {
+		if _, ok := disk[r.Name]; ok {
+			errs = append(errs, fmt.Errorf("canhcl: role %q is defined twice", r.Name))
+			continue
+		}
+
+		role := make(can.DiskRole, len(r.Permissions))
+		for _, p := range r.Permissions {
+			if _, ok := role[p.Name]; ok {
+				errs = append(errs, fmt.Errorf("canhcl: role %q permission %q is defined twice", r.Name, p.Name))
+				continue
+			}
+			errs = append(errs, unknown(p.Remain)...)
+
+			role[p.Name] = can.DiskPermission{
+				Abilities:    p.Abilities,
+				Routes:       p.Routes,
+				Resource:     p.Resource,
+				Fields:       p.Fields,
+				Environments: p.Environments,
+				Labels:       p.Labels,
+				Deny:         p.Deny,
+				Conditions:   p.Conditions,
+				Audit:        p.Audit,
+				Quotas:       p.Quotas,
+				CIDR:         p.CIDR,
+			}
+		}
+		disk[r.Name] = role
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	roles, err := can.ConfigErr(disk)
+	if err != nil && filename != "" {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	return roles, err
+}
+
+// unknown reports the arguments and blocks of body, which gohcl didn't
+// decode, unless can.Strict is false.
+func unknown(body hcl.Body) []error {
+	if !can.Strict || body == nil {
+		return nil
+	}
+
+	attrs, diags := body.JustAttributes()
+	names := make([]*hcl.Attribute, 0, len(attrs))
+	for _, a := range attrs {
+		names = append(names, a)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i].NameRange.Start.Byte < names[j].NameRange.Start.Byte })
+
+	var errs []error
+	for _, a := range names {
+		errs = append(errs, fmt.Errorf("%s: %w %q", a.NameRange, can.ErrUnknownField, a.Name))
+	}
+	if diags.HasErrors() {
+		errs = append(errs, diags)
+	}
+
+	return errs
+}
+
+// OpenFile reads an HCL role file.
+// filename - HCL encoded file for parsing
+//
+// returns - a map of Roles and an error
+func OpenFile(filename string) (can.Roles, error) {
+	f, err := os.OpenFile(filename, os.O_RDONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Decode(f, filename)
+}
@@ -0,0 +1,69 @@
+package canhcl
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/acmacalister/can"
+)
+
+const roles = `
+role "user" {
+  permission "users" {
+    abilities = ["read", "update"]
+    routes    = ["{id}/books"]
+    fields = {
+      update = ["display_name", "avatar"]
+    }
+  }
+}
+
+role "admin" {
+  permission "users" {
+    abilities = ["all"]
+    deny      = ["delete"]
+  }
+}
+`
+
+func TestDecode(t *testing.T) {
+	r, err := Decode(strings.NewReader(roles), "rbac.hcl")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if !can.Can(ctx, r["user"], "users_books", can.Read, can.Compare(1, 1)) {
+		t.Fatal("expected the route to be granted")
+	}
+	if can.CanField(ctx, r["user"], "users", can.Update, "email") {
+		t.Fatal("expected the field restriction")
+	}
+	if can.Can(ctx, r["admin"], "users", can.Delete, nil) || !can.Can(ctx, r["admin"], "users", can.Update, nil) {
+		t.Fatal("expected the denial")
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	_, err := Decode(strings.NewReader("role \"user\" {\n  permission \"users\" {\n    abilites = [\"read\"]\n  }\n}\n"), "rbac.hcl")
+	if !errors.Is(err, can.ErrUnknownField) || !strings.Contains(err.Error(), "rbac.hcl:3,5-13") {
+		t.Fatalf("expected the unknown field with its position, got %v", err)
+	}
+
+	_, err = Decode(strings.NewReader("role \"user\" {\n  permission \"users\" {\n    abilities = [\"raed\"]\n  }\n}\n"), "rbac.hcl")
+	if !errors.Is(err, can.ErrUnknownAbility) || !strings.HasPrefix(err.Error(), "rbac.hcl: ") {
+		t.Fatalf("expected the unknown ability, got %v", err)
+	}
+
+	if _, err := Decode(strings.NewReader("role \"user\" {\n"), "rbac.hcl"); err == nil {
+		t.Fatal("expected a syntax error")
+	}
+
+	defer func() { can.Strict = true }()
+	can.Strict = false
+	if _, err := Decode(strings.NewReader("role \"user\" {\n  permission \"users\" {\n    future = true\n  }\n}\n"), ""); err != nil {
+		t.Fatalf("expected unknown fields to be ignored, got %v", err)
+	}
+}
@@ -0,0 +1,365 @@
+// Package caniam reads a subset of AWS IAM JSON policy documents into
+// can roles, so policies can be written in a familiar format with the
+// tools that support it:
+//
+//	{
+//	  "Version": "2012-10-17",
+//	  "Statement": [
+//	    {"Effect": "Allow", "Action": ["projects:read", "projects:update"], "Resource": "*"},
+//	    {"Effect": "Deny", "Action": "projects:delete", "Resource": "42/*"}
+//	  ]
+//	}
+//
+// Actions take the form resource:ability, like can scopes (see
+// can.ParsePermission), or * for every ability on every resource.
+// Resources are paths below the action's resource, joined with
+// can.HierarchySeparator, where * matches a single level and a trailing
+// * everything below. Other resources match only themselves, not the
+// levels below them as in can. Every matching statement applies and deny
+// statements win over allow statements, as in IAM. NotAction,
+// NotResource, Principal and Condition are rejected rather than
+// ignored, so a policy can't grant more than it says.
+package caniam
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/acmacalister/can"
+)
+
+// ErrUnsupported is returned for policy elements can't express.
+var ErrUnsupported = errors.New("caniam: unsupported policy element")
+
+// Document is an IAM policy document.
+type Document struct {
+	Version   string      `json:"Version"`
+	Statement []Statement `json:"Statement"`
+}
+
+// Statement is a statement of an IAM policy document.
+type Statement struct {
+	Sid      string `json:"Sid,omitempty"`
+	Effect   string `json:"Effect"`
+	Action   Values `json:"Action"`
+	Resource Values `json:"Resource"`
+
+	NotAction   Values          `json:"NotAction,omitempty"`
+	NotResource Values          `json:"NotResource,omitempty"`
+	Principal   json.RawMessage `json:"Principal,omitempty"`
+	Condition   json.RawMessage `json:"Condition,omitempty"`
+}
+
+// Values is a policy element holding a string or a list of strings.
+type Values []string
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (v *Values) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*v = Values{s}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(b, &list); err != nil {
+		return fmt.Errorf("caniam: expected a string or a list of strings: %w", err)
+	}
+	*v = list
+
+	return nil
+}
+
+// Decode reads a policy document from r and converts it into a role.
+func Decode(r io.Reader) (can.Role, error) {
+	var d Document
+	if err := json.NewDecoder(r).Decode(&d); err != nil {
+		return nil, err
+	}
+
+	return d.Role()
+}
+
+// OpenFiles reads one policy document per role, keyed by role name.
+//
+// files - the policy document files keyed by the name of their role
+//
+// returns - the roles and the problems of every file joined with errors.Join
+func OpenFiles(files map[string]string) (can.Roles, error) {
+	roles := make(can.Roles, len(files))
+	var errs []error
+	for name, filename := range files {
+		f, err := os.Open(filename)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		role, err := Decode(f)
+		f.Close()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", filename, err))
+			continue
+		}
+		roles[name] = role
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return roles, nil
+}
+
+// Role converts the document into a role. Every problem is reported,
+// joined with errors.Join.
+func (d Document) Role() (can.Role, error) {
+	b, exact := can.NewRole(), can.NewRole()
+	var errs []error
+	for i, s := range d.Statement {
+		if err := s.apply(b, exact); err != nil {
+			errs = append(errs, fmt.Errorf("caniam: statement %s: %w", s.name(i), err))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	role := b.Build()
+	normalize(role, exact.Build())
+
+	return role, nil
+}
+
+// apply adds the statement to b, or to exact for resources that don't
+// match the levels below them.
+func (s Statement) apply(b, exact *can.RoleBuilder) error {
+	switch {
+	case len(s.NotAction) > 0:
+		return fmt.Errorf("%w: NotAction", ErrUnsupported)
+	case len(s.NotResource) > 0:
+		return fmt.Errorf("%w: NotResource", ErrUnsupported)
+	case len(s.Principal) > 0:
+		return fmt.Errorf("%w: Principal", ErrUnsupported)
+	case len(s.Condition) > 0:
+		return fmt.Errorf("%w: Condition", ErrUnsupported)
+	case len(s.Action) == 0:
+		return errors.New("missing Action")
+	}
+
+	var grant, grantExact func(permission string, abilities ...can.Ability) *can.RoleBuilder
+	switch s.Effect {
+	case "Allow":
+		grant, grantExact = b.Allow, exact.Allow
+	case "Deny":
+		grant, grantExact = b.Deny, exact.Deny
+	default:
+		return fmt.Errorf("unsupported Effect %q", s.Effect)
+	}
+
+	resources := s.Resource
+	if len(resources) == 0 {
+		resources = Values{"*"}
+	}
+
+	for _, action := range s.Action {
+		resource, ability, err := parseAction(action)
+		if err != nil {
+			return err
+		}
+		for _, r := range resources {
+			permission, below, err := permission(resource, r)
+			if err != nil {
+				return err
+			}
+			if below {
+				grant(permission, ability)
+			} else {
+				grantExact(permission, ability)
+			}
+		}
+	}
+
+	return nil
+}
+
+// name identifies the statement in errors.
+func (s Statement) name(i int) string {
+	if s.Sid != "" {
+		return fmt.Sprintf("%q", s.Sid)
+	}
+
+	return fmt.Sprint(i)
+}
+
+// parseAction parses an action into a resource and an ability.
+func parseAction(action string) (string, can.Ability, error) {
+	if action == "*" {
+		return can.Wildcard, can.All, nil
+	}
+
+	resource, ability, ok := strings.Cut(action, ":")
+	if ability == "*" {
+		ability = "all"
+	}
+	a := can.StringToAbility(ability)
+	if !ok || resource == "" || strings.Contains(resource, "*") || a == can.None || a == can.Skip {
+		return "", can.None, fmt.Errorf("%w: Action %q", ErrUnsupported, action)
+	}
+
+	return resource, a, nil
+}
+
+// permission joins the resource of an action with a Resource path. It
+// reports whether the path matches the levels below it, as * and paths
+// ending in /* do.
+func permission(resource, path string) (string, bool, error) {
+	below := path == "*" || strings.HasSuffix(path, "/*")
+	if path == "*" {
+		path = ""
+	}
+	path = strings.TrimSuffix(path, "/*")
+	if path == "" {
+		return resource, true, nil
+	}
+	if can.HierarchySeparator == "" {
+		return "", false, fmt.Errorf("%w: Resource %q without can.HierarchySeparator", ErrUnsupported, path)
+	}
+
+	levels := strings.Split(path, "/")
+	for _, level := range levels {
+		if level == "" || strings.ContainsAny(level, ":?") || (strings.Contains(level, "*") && level != can.Wildcard) {
+			return "", false, fmt.Errorf("%w: Resource %q", ErrUnsupported, path)
+		}
+	}
+
+	return resource + can.HierarchySeparator + strings.Join(levels, can.HierarchySeparator), below, nil
+}
+
+// normalize makes every permission of role carry the abilities and
+// denials of all the permissions that apply to it. Can uses the single
+// most specific permission of a hierarchy, while IAM combines every
+// matching statement, so without it an allow on projects/42 would
+// override a deny on projects. Where two wildcard permissions overlap,
+// an entry for their intersection is added.
+//
+// The permissions of exact only apply to themselves. They are added to
+// role along with an entry for the level below them, such as
+// projects/42/*, so Can doesn't find them for projects/42/secrets.
+func normalize(role, exact can.Role) {
+	if can.HierarchySeparator == "" {
+		return
+	}
+
+	type grant struct {
+		levels []string
+		perm   can.Permission
+		exact  bool
+	}
+	var grants []grant
+	keys := make(map[string][]string, len(role)+2*len(exact))
+	for k, p := range role {
+		levels := strings.Split(k, can.HierarchySeparator)
+		grants = append(grants, grant{levels: levels, perm: p})
+		keys[k] = levels
+	}
+	for k, p := range exact {
+		levels := strings.Split(k, can.HierarchySeparator)
+		grants = append(grants, grant{levels: levels, perm: p, exact: true})
+		keys[k] = levels
+		keys[k+can.HierarchySeparator+can.Wildcard] = append(levels[:len(levels):len(levels)], can.Wildcard)
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for _, a := range sortedKeys(keys) {
+			for _, b := range sortedKeys(keys) {
+				levels, ok := intersect(keys[a], keys[b])
+				if !ok {
+					continue
+				}
+				if k := strings.Join(levels, can.HierarchySeparator); keys[k] == nil {
+					keys[k] = levels
+					changed = true
+				}
+			}
+		}
+	}
+
+	merged := make(can.Role, len(keys))
+	for k, levels := range keys {
+		p := can.Permission{Abilities: make(map[can.Ability]struct{})}
+		for _, g := range grants {
+			if !covers(g.levels, levels) || (g.exact && len(g.levels) != len(levels)) {
+				continue
+			}
+			for a := range g.perm.Abilities {
+				p.Abilities[a] = struct{}{}
+			}
+			for a := range g.perm.Denied {
+				if p.Denied == nil {
+					p.Denied = make(map[can.Ability]struct{})
+				}
+				p.Denied[a] = struct{}{}
+			}
+		}
+		merged[k] = p
+	}
+
+	for k := range role {
+		delete(role, k)
+	}
+	for k, p := range merged {
+		role[k] = p
+	}
+}
+
+// intersect returns the levels of the most general permission both a
+// and b apply to, or false if there is none.
+func intersect(a, b []string) ([]string, bool) {
+	if len(a) < len(b) {
+		a, b = b, a
+	}
+
+	levels := make([]string, len(a))
+	for i := range a {
+		switch {
+		case i >= len(b) || a[i] == b[i] || b[i] == can.Wildcard:
+			levels[i] = a[i]
+		case a[i] == can.Wildcard:
+			levels[i] = b[i]
+		default:
+			return nil, false
+		}
+	}
+
+	return levels, true
+}
+
+// covers reports whether the parent permission applies to child.
+func covers(parent, child []string) bool {
+	if len(parent) > len(child) {
+		return false
+	}
+	for i := range parent {
+		if parent[i] != child[i] && parent[i] != can.Wildcard {
+			return false
+		}
+	}
+
+	return true
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,142 @@
+package caniam
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/acmacalister/can"
+)
+
+const policy = `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {"Sid": "Projects", "Effect": "Allow", "Action": ["projects:read", "projects:update"], "Resource": "*"},
+    {"Effect": "Allow", "Action": "projects:*", "Resource": ["42/*", "*/tasks"]},
+    {"Effect": "Deny", "Action": "projects:delete", "Resource": "42/secrets"},
+    {"Effect": "Deny", "Action": "billing:*"},
+    {"Effect": "Allow", "Action": "billing:read", "Resource": "invoices"}
+  ]
+}`
+
+func TestDecode(t *testing.T) {
+	role, err := Decode(strings.NewReader(policy))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	yes := func() bool { return true }
+	tests := []struct {
+		permission string
+		ability    can.Ability
+		want       bool
+	}{
+		{"projects", can.Read, true},
+		{"projects/7", can.Update, true},
+		{"projects", can.Delete, false},
+		{"projects/42", can.Delete, true},
+		{"projects/42/issues", can.Create, true},
+		{"projects/7/tasks", can.Delete, true},
+		{"projects/42/secrets", can.Delete, false},
+		{"projects/42/secrets", can.Read, true},
+		{"billing", can.Read, false},
+		{"billing/invoices", can.Read, false},
+	}
+	for _, tt := range tests {
+		if got := can.Can(ctx, role, tt.permission, tt.ability, yes); got != tt.want {
+			t.Errorf("%s %s: expected %v, got %v", tt.permission, tt.ability, tt.want, got)
+		}
+	}
+}
+
+func TestDecodeUnsupported(t *testing.T) {
+	for _, stmt := range []string{
+		`{"Effect": "Allow", "NotAction": "projects:read"}`,
+		`{"Effect": "Allow", "Action": "projects:read", "Condition": {"Bool": {"aws:SecureTransport": "true"}}}`,
+		`{"Effect": "Allow", "Action": "projects:Get*"}`,
+		`{"Effect": "Allow", "Action": "projects:read", "Resource": "arn:aws:s3:::bucket"}`,
+		`{"Effect": "Allow", "Action": "projects:read", "Resource": "4*"}`,
+	} {
+		_, err := Decode(strings.NewReader(`{"Statement": [` + stmt + `]}`))
+		if !errors.Is(err, ErrUnsupported) {
+			t.Errorf("%s: expected ErrUnsupported, got %v", stmt, err)
+		}
+	}
+
+	_, err := Decode(strings.NewReader(`{"Statement": [{"Effect": "Maybe", "Action": "a:read"}, {"Effect": "Allow"}]}`))
+	if err == nil || !strings.Contains(err.Error(), "statement 0") || !strings.Contains(err.Error(), "statement 1") {
+		t.Fatalf("expected every statement to be reported, got %v", err)
+	}
+}
+
+func TestDecodeOverlap(t *testing.T) {
+	role, err := Decode(strings.NewReader(`{"Statement": [
+		{"Effect": "Deny", "Action": "projects:delete", "Resource": "42/*"},
+		{"Effect": "Allow", "Action": "projects:*", "Resource": "*/tasks"}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	yes := func() bool { return true }
+	if can.Can(context.Background(), role, "projects/42/tasks", can.Delete, yes) {
+		t.Fatal("expected the deny to apply where the statements overlap")
+	}
+	if !can.Can(context.Background(), role, "projects/7/tasks", can.Delete, yes) {
+		t.Fatal("expected the allow to apply elsewhere")
+	}
+}
+
+func TestDecodeExact(t *testing.T) {
+	role, err := Decode(strings.NewReader(`{"Statement": [
+		{"Effect": "Allow", "Action": "projects:read", "Resource": "42"},
+		{"Effect": "Allow", "Action": "projects:update", "Resource": "7/*"},
+		{"Effect": "Allow", "Action": "projects:delete", "Resource": "7"},
+		{"Effect": "Deny", "Action": "projects:update", "Resource": "7/tasks"}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	yes := func() bool { return true }
+	tests := []struct {
+		permission string
+		ability    can.Ability
+		want       bool
+	}{
+		{"projects/42", can.Read, true},
+		{"projects/42/secrets", can.Read, false},
+		{"projects/42/secrets/keys", can.Read, false},
+		{"projects/7", can.Delete, true},
+		{"projects/7/issues", can.Delete, false},
+		{"projects/7/issues", can.Update, true},
+		{"projects/7/tasks", can.Update, false},
+		{"projects/7/tasks/1", can.Update, true},
+	}
+	for _, tt := range tests {
+		if got := can.Can(ctx, role, tt.permission, tt.ability, yes); got != tt.want {
+			t.Errorf("%s %s: expected %v, got %v", tt.permission, tt.ability, tt.want, got)
+		}
+	}
+}
+
+func TestDecodeEverything(t *testing.T) {
+	role, err := Decode(strings.NewReader(`{"Statement": [
+		{"Effect": "Allow", "Action": "*", "Resource": "*"},
+		{"Effect": "Deny", "Action": "billing:*"}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	yes := func() bool { return true }
+	if !can.Can(context.Background(), role, "users/7", can.Delete, yes) {
+		t.Fatal("expected * to allow everything")
+	}
+	if can.Can(context.Background(), role, "billing/invoices", can.Read, yes) {
+		t.Fatal("expected billing to be denied")
+	}
+}
@@ -0,0 +1,151 @@
+// Package canopa delegates can decisions to Open Policy Agent, for
+// organizations standardizing their policies on OPA while keeping can's
+// API and middleware at call sites. It talks to OPA over its REST API,
+// so OPA runs as a sidecar or service rather than embedded, which keeps
+// the dependencies of the can module small.
+package canopa
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/acmacalister/can"
+)
+
+// ErrUndefined is reported when the policy decision is undefined, for
+// example because the rule path doesn't exist. Such checks are denied.
+var ErrUndefined = errors.New("canopa: undefined decision")
+
+// Input is the input document of a decision. It holds the checked role,
+// permission and ability along with the subject, tenant, attributes,
+// labels and request stored in the check's ctx (see
+// can.NewWebhookRequest). A policy allowing users to read their own
+// records:
+//
+//	package can
+//
+//	default allow := false
+//
+//	allow if {
+//		input.permission == "users"
+//		input.ability == "read"
+//		input.subject.id == input.attributes.owner
+//	}
+type Input = can.WebhookRequest
+
+// OPA is a can.Authorizer querying the OPA Data API.
+type OPA struct {
+	// URL is the base URL of the OPA server, e.g. http://127.0.0.1:8181
+	URL string
+	// Path is the path of the rule deciding, e.g. can/allow. The rule
+	// may be a boolean or an object with a boolean allow field.
+	Path string
+	// Header is added to every request, useful for authentication.
+	Header http.Header
+	// Timeout bounds every request in addition to the ctx deadline. Zero means no timeout.
+	Timeout time.Duration
+	// Fallback decides when OPA can't be reached or returns an invalid or
+	// undefined decision, may be nil, in which case such checks are denied.
+	// Checks whose ctx is done are decided by can.OnUndecided instead.
+	Fallback can.Authorizer
+	// OnError is called with every error from OPA, may be nil.
+	OnError func(error)
+	// Client is the http client used for requests. Defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// Authorize implements the can.Authorizer interface.
+func (o *OPA) Authorize(ctx context.Context, role can.Role, permission string, ability can.Ability, compare func() bool) bool {
+	return o.AuthorizeOutcome(ctx, role, permission, ability, compare).Allow()
+}
+
+// AuthorizeOutcome implements the can.OutcomeAuthorizer interface, checks
+// whose query was cut off by ctx are can.Undecided.
+func (o *OPA) AuthorizeOutcome(ctx context.Context, role can.Role, permission string, ability can.Ability, compare func() bool) can.Outcome {
+	allow, err := o.query(ctx, can.NewWebhookRequest(ctx, role, permission, ability, compare))
+	if err != nil {
+		if o.OnError != nil {
+			o.OnError(err)
+		}
+		if ctx.Err() != nil {
+			return can.Undecided
+		}
+		if o.Fallback != nil {
+			return can.Decide(ctx, o.Fallback, role, permission, ability, compare)
+		}
+		return can.Denied
+	}
+	if allow {
+		return can.Allowed
+	}
+
+	return can.Denied
+}
+
+// query asks OPA for the decision on in.
+func (o *OPA) query(ctx context.Context, in Input) (bool, error) {
+	if o.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.Timeout)
+		defer cancel()
+	}
+
+	b, err := json.Marshal(map[string]any{"input": in})
+	if err != nil {
+		return false, err
+	}
+
+	u := strings.TrimSuffix(o.URL, "/") + "/v1/data/" + strings.Trim(o.Path, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(b))
+	if err != nil {
+		return false, err
+	}
+	for k, v := range o.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := o.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("canopa: opa returned %s", resp.Status)
+	}
+
+	var r struct {
+		Result *json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return false, err
+	}
+	if r.Result == nil {
+		return false, fmt.Errorf("%w: %s", ErrUndefined, o.Path)
+	}
+
+	var allow bool
+	if err := json.Unmarshal(*r.Result, &allow); err == nil {
+		return allow, nil
+	}
+
+	var obj struct {
+		Allow *bool `json:"allow"`
+	}
+	if err := json.Unmarshal(*r.Result, &obj); err != nil || obj.Allow == nil {
+		return false, fmt.Errorf("canopa: %s is neither a boolean nor an object with a boolean allow", o.Path)
+	}
+
+	return *obj.Allow, nil
+}
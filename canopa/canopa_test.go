@@ -0,0 +1,61 @@
+package canopa
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/acmacalister/can"
+)
+
+func TestOPA(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Input Input `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		in := body.Input
+		allow := in.Permission == "users" && in.Ability == can.Read && in.Subject != nil && in.Subject.ID == "u1"
+
+		switch r.URL.Path {
+		case "/v1/data/can/allow":
+			json.NewEncoder(w).Encode(map[string]any{"result": allow})
+		case "/v1/data/can":
+			json.NewEncoder(w).Encode(map[string]any{"result": map[string]any{"allow": allow}})
+		case "/v1/data/can/missing":
+			w.Write([]byte(`{}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := can.WithSubject(context.Background(), can.Subject{ID: "u1"})
+	for _, path := range []string{"can/allow", "/can"} {
+		o := &OPA{URL: srv.URL, Path: path}
+		if !o.Authorize(ctx, nil, "users", can.Read, nil) {
+			t.Fatalf("%s: expected opa to allow", path)
+		}
+		if o.Authorize(ctx, nil, "users", can.Delete, nil) {
+			t.Fatalf("%s: expected opa to deny", path)
+		}
+	}
+
+	var errs []error
+	local := can.NewRole().Allow("users", can.All).Build()
+	o := &OPA{URL: srv.URL, Path: "can/missing", OnError: func(err error) { errs = append(errs, err) }}
+	if o.Authorize(ctx, local, "users", can.Read, nil) || len(errs) != 1 || !errors.Is(errs[0], ErrUndefined) {
+		t.Fatalf("expected an undefined decision to deny, got %v", errs)
+	}
+
+	o.Fallback = can.Local
+	if !o.Authorize(ctx, local, "users", can.Read, nil) {
+		t.Fatal("expected the fallback to decide")
+	}
+}
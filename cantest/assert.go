@@ -0,0 +1,63 @@
+package cantest
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/acmacalister/can"
+)
+
+// Role builds a role from scope strings such as users:read or books:*
+// (see can.Role.FromScopes), failing the test if one is invalid:
+//
+//	role := cantest.Role(t, "users:read", "books:*")
+func Role(t testing.TB, scopes ...string) can.Role {
+	t.Helper()
+
+	role, err := can.Role{}.FromScopes(scopes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return role
+}
+
+// Roles decodes roles written in the yaml format of can.OpenFile,
+// failing the test if they are invalid.
+func Roles(t testing.TB, yml string) can.Roles {
+	t.Helper()
+
+	roles, err := can.Decode(strings.NewReader(yml))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return roles
+}
+
+// AssertCan fails the test unless role may use ability on permission.
+// The compare function of the check is satisfied, so ownership is
+// assumed. The failure message explains the decision (see can.Explain).
+func AssertCan(t testing.TB, role can.Role, permission string, ability can.Ability) {
+	t.Helper()
+
+	if e := explain(role, permission, ability); !e.Allowed {
+		t.Errorf("expected %s on %s to be allowed:\n%s", ability, permission, e)
+	}
+}
+
+// AssertCannot fails the test if role may use ability on permission,
+// see AssertCan.
+func AssertCannot(t testing.TB, role can.Role, permission string, ability can.Ability) {
+	t.Helper()
+
+	if e := explain(role, permission, ability); e.Allowed {
+		t.Errorf("expected %s on %s to be denied:\n%s", ability, permission, e)
+	}
+}
+
+// explain explains a check whose compare function is satisfied.
+func explain(role can.Role, permission string, ability can.Ability) can.Explanation {
+	return can.Explain(context.Background(), role, permission, ability, func() bool { return true })
+}
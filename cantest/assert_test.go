@@ -0,0 +1,59 @@
+package cantest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/acmacalister/can"
+)
+
+func TestAssert(t *testing.T) {
+	role := Role(t, "users:read", "books:*")
+	AssertCan(t, role, "users", can.Read)
+	AssertCan(t, role, "books", can.Delete)
+	AssertCannot(t, role, "users", can.Delete)
+
+	roles := Roles(t, "admin:\n  users:\n    abilities: [all]\n")
+	AssertCan(t, roles["admin"], "users", can.Delete)
+
+	// a failing assertion reports the explanation
+	ft := &fakeT{TB: t}
+	AssertCannot(ft, role, "users", can.Read)
+	if !strings.Contains(ft.msg, "to be denied") || !strings.Contains(ft.msg, `matched permission "users"`) {
+		t.Fatalf("expected AssertCannot to fail with an explanation, got %q", ft.msg)
+	}
+}
+
+// fakeT captures the failure of an assertion.
+type fakeT struct {
+	testing.TB
+	msg string
+}
+
+func (f *fakeT) Helper() {}
+
+func (f *fakeT) Errorf(format string, args ...any) {
+	f.msg = fmt.Sprintf(format, args...)
+}
+
+func TestRecorder(t *testing.T) {
+	rec := &Recorder{}
+	g := &can.Guard{Authorizer: rec, DefaultRole: Role(t, "users:read")}
+
+	handler := g.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/users", nil))
+
+	checks := rec.Checks()
+	if len(checks) != 2 || !checks[0].Allowed || checks[1].Allowed || !rec.Checked("users", can.Delete) {
+		t.Fatalf("unexpected checks %+v", checks)
+	}
+
+	rec.Reset()
+	if len(rec.Checks()) != 0 {
+		t.Fatal("expected Reset to forget the checks")
+	}
+}
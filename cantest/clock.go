@@ -0,0 +1,94 @@
+package cantest
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/acmacalister/can"
+)
+
+// Clock is a clock that only moves when told to, for deterministic tests
+// of cache TTLs, backoff, idempotency windows and the other time based
+// behavior of can. It is safe for concurrent use.
+type Clock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []waiter
+}
+
+// waiter is a pending After of a Clock.
+type waiter struct {
+	at time.Time
+	c  chan time.Time
+}
+
+// NewClock returns a Clock stopped at now.
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+// Now returns the time of the clock.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// After returns a channel receiving the time of the clock once it has
+// been advanced by d.
+func (c *Clock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	w := waiter{at: c.now.Add(d), c: make(chan time.Time, 1)}
+	if d <= 0 {
+		w.c <- c.now
+		return w.c
+	}
+	c.waiters = append(c.waiters, w)
+
+	return w.c
+}
+
+// Advance moves the clock forward by d, firing the channels of After
+// that are due.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			pending = append(pending, w)
+			continue
+		}
+		w.c <- c.now
+	}
+	c.waiters = pending
+}
+
+// Waiters returns how many channels of After are yet to fire, so tests
+// can wait for code to start waiting before advancing the clock.
+func (c *Clock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.waiters)
+}
+
+// UseClock makes can read the time from c, and wait on it, until the test and its
+// subtests complete. Tests using it must not run in parallel.
+//
+// t - the test
+//
+// c - the clock
+func UseClock(t testing.TB, c *Clock) {
+	t.Helper()
+
+	now, after := can.Now, can.After
+	can.Now, can.After = c.Now, c.After
+	t.Cleanup(func() { can.Now, can.After = now, after })
+}
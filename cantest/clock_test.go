@@ -0,0 +1,73 @@
+package cantest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/acmacalister/can"
+)
+
+func TestClock(t *testing.T) {
+	clock := NewClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	UseClock(t, clock)
+
+	calls := 0
+	c := can.Cached(can.AuthorizerFunc(func(ctx context.Context, role can.Role, permission string, ability can.Ability, compare func() bool) bool {
+		calls++
+		return true
+	}), time.Minute, 10)
+
+	role := can.NewRole().Allow("users", can.All).Build()
+	for _, d := range []time.Duration{0, 59 * time.Second, time.Second} {
+		clock.Advance(d)
+		c.Authorize(context.Background(), role, "users", can.Read, nil)
+	}
+
+	if calls != 2 {
+		t.Fatalf("expected the decision to expire after exactly a minute, got %d calls", calls)
+	}
+	if !can.Now().Equal(clock.Now()) {
+		t.Fatal("expected can to read the test clock")
+	}
+}
+
+func TestClockAfter(t *testing.T) {
+	clock := NewClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	UseClock(t, clock)
+
+	g := &can.Guard{Backoff: &can.Backoff{Threshold: 1, Delay: time.Minute, Sleep: true}}
+	deny := func() {
+		req := httptest.NewRequest(http.MethodGet, "/users", nil)
+		req = req.WithContext(can.WithRole(req.Context(), can.NewRole().Build()))
+		g.Check(httptest.NewRecorder(), req, "users", can.Read)
+	}
+	deny()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		deny()
+	}()
+
+	for deadline := time.Now().Add(time.Second); clock.Waiters() == 0; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the backoff to wait on the test clock")
+		}
+	}
+	clock.Advance(59 * time.Second)
+	select {
+	case <-done:
+		t.Fatal("expected the backoff to sleep on the test clock")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	clock.Advance(time.Second)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected the backoff to wake when the test clock is advanced")
+	}
+}
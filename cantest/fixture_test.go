@@ -0,0 +1,50 @@
+package cantest
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/acmacalister/can"
+)
+
+func TestFixture(t *testing.T) {
+	a := Fixture(Options{Seed: 42, Resources: 20, Roles: 10})
+	b := Fixture(Options{Seed: 42, Resources: 20, Roles: 10})
+	if !reflect.DeepEqual(a, b) {
+		t.Fatal("expected the same seed to produce the same fixture")
+	}
+
+	if len(a.Roles) != 12 || len(a.Subjects) != 50 {
+		t.Fatalf("unexpected fixture size: %d roles, %d subjects", len(a.Roles), len(a.Subjects))
+	}
+
+	for _, sub := range a.Subjects {
+		for _, role := range sub.Roles {
+			if _, ok := a.Roles[role]; !ok {
+				t.Fatalf("subject %s has unknown role %s", sub.ID, role)
+			}
+		}
+	}
+
+	if !can.Can(context.Background(), a.Roles["admin"], "users", can.Delete, nil) {
+		t.Fatal("expected admin to have all on users")
+	}
+
+	data, err := a.YAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := can.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, a.Roles) {
+		t.Fatal("expected yaml to load back into the same roles")
+	}
+
+	if _, ok := a.Subject("user-0001"); !ok {
+		t.Fatal("expected subject user-0001")
+	}
+}
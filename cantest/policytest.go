@@ -0,0 +1,50 @@
+package cantest
+
+import (
+	"testing"
+
+	"github.com/acmacalister/can"
+)
+
+// RunPolicyTests checks the expectations of a policy test file against a
+// role file, one subtest per expectation, so policy regressions fail
+// go test:
+//
+//	func TestPolicy(t *testing.T) {
+//		cantest.RunPolicyTests(t, "rbac.yml", "rbac_test.yml")
+//	}
+//
+// t - the test
+//
+// rolesFile - the role file, loaded with can.OpenFiles
+//
+// testsFile - the policy test file, see can.PolicyTest
+func RunPolicyTests(t *testing.T, rolesFile, testsFile string) {
+	t.Helper()
+
+	roles, err := can.OpenFiles(rolesFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scenarios, err := can.OpenPolicyTests(testsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, result := range can.Simulate(roles, scenarios).Results {
+		t.Run(result.Scenario.String(), func(t *testing.T) {
+			if !result.Passed() {
+				t.Errorf("got %s:\n%s", decision(result.Allowed), result.Explanation)
+			}
+		})
+	}
+}
+
+// decision describes a decision.
+func decision(allowed bool) string {
+	if allowed {
+		return "allowed"
+	}
+
+	return "denied"
+}
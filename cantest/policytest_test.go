@@ -0,0 +1,7 @@
+package cantest
+
+import "testing"
+
+func TestRunPolicyTests(t *testing.T) {
+	RunPolicyTests(t, "../testdata/rbac.yml", "../testdata/rbac_test.yml")
+}
@@ -0,0 +1,74 @@
+package cantest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/acmacalister/can"
+)
+
+// Check is a check captured by a Recorder.
+type Check struct {
+	Role       can.Role
+	Permission string
+	Ability    can.Ability
+	Allowed    bool
+}
+
+// Recorder is a can.Authorizer capturing every check made through it,
+// for asserting which checks a handler makes:
+//
+//	rec := &cantest.Recorder{}
+//	guard := &can.Guard{Authorizer: rec}
+//	// serve a request through the guard
+//	if !rec.Checked("users", can.Read) { ... }
+//
+// It is safe for concurrent use. The zero value decides with can.Local.
+type Recorder struct {
+	// Authorizer makes the decisions. Defaults to can.Local.
+	Authorizer can.Authorizer
+
+	mu     sync.Mutex
+	checks []Check
+}
+
+// Authorize implements the can.Authorizer interface.
+func (r *Recorder) Authorize(ctx context.Context, role can.Role, permission string, ability can.Ability, compare func() bool) bool {
+	a := r.Authorizer
+	if a == nil {
+		a = can.Local
+	}
+	allowed := a.Authorize(ctx, role, permission, ability, compare)
+
+	r.mu.Lock()
+	r.checks = append(r.checks, Check{Role: role, Permission: permission, Ability: ability, Allowed: allowed})
+	r.mu.Unlock()
+
+	return allowed
+}
+
+// Checks returns the checks captured so far, in order.
+func (r *Recorder) Checks() []Check {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]Check(nil), r.checks...)
+}
+
+// Checked reports whether ability on permission was checked.
+func (r *Recorder) Checked(permission string, ability can.Ability) bool {
+	for _, c := range r.Checks() {
+		if c.Permission == permission && c.Ability == ability {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Reset forgets the captured checks.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	r.checks = nil
+	r.mu.Unlock()
+}
@@ -0,0 +1,89 @@
+// Package cantoml reads can role files written in TOML, one table per
+// role and one sub-table per resource:
+//
+//	[user.users]
+//	abilities = ["read", "update"]
+//	routes = ["{id}/books"]
+//
+//	[user.users.fields]
+//	update = ["display_name", "avatar"]
+//
+// The keys of a resource are those of can.DiskPermission, the roles are
+// built with can.Config. The reserved top level keys templates and
+// groups are skipped, includes, aliases and ${VAR} placeholders are
+// only supported in yaml role files.
+package cantoml
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/acmacalister/can"
+)
+
+// Decode reads TOML encoded roles from r. Unknown keys are reported with
+// can.ErrUnknownField, unless can.Strict is false.
+// r - a reader of TOML encoded roles
+//
+// returns - a map of Roles and an error
+func Decode(r io.Reader) (can.Roles, error) {
+	var doc map[string]toml.Primitive
+	md, err := toml.NewDecoder(r).Decode(&doc)
+	if err != nil {
+		return nil, err
+	}
+
+	disk := make(can.DiskRoles, len(doc))
+	for name, prim := range doc {
+		switch name {
+		case can.IncludeKey, can.AliasesKey:
+			return nil, fmt.Errorf("cantoml: %s is only supported in yaml role files", name)
+		case can.TemplatesKey, can.GroupsKey:
+			continue
+		}
+
+		var role can.DiskRole
+		if err := md.PrimitiveDecode(prim, &role); err != nil {
+			return nil, fmt.Errorf("cantoml: role %q: %w", name, err)
+		}
+		disk[name] = role
+	}
+
+	if can.Strict {
+		var errs []error
+		for _, key := range md.Undecoded() {
+			if len(key) > 0 && (key[0] == can.TemplatesKey || key[0] == can.GroupsKey) {
+				continue
+			}
+			errs = append(errs, fmt.Errorf("%w %q", can.ErrUnknownField, strings.Join(key, ".")))
+		}
+		if len(errs) > 0 {
+			return nil, errors.Join(errs...)
+		}
+	}
+
+	return can.ConfigErr(disk)
+}
+
+// OpenFile reads a TOML role file.
+// filename - TOML encoded file for parsing
+//
+// returns - a map of Roles and an error
+func OpenFile(filename string) (can.Roles, error) {
+	f, err := os.OpenFile(filename, os.O_RDONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r, err := Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	return r, nil
+}
@@ -0,0 +1,72 @@
+package cantoml
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/acmacalister/can"
+)
+
+const roles = `
+[groups]
+"engineering@example.com" = ["user"]
+
+[user.users]
+abilities = ["read", "update"]
+routes = ["{id}/books"]
+
+[user.users.fields]
+update = ["display_name", "avatar"]
+
+[admin.users]
+abilities = ["all"]
+deny = ["delete"]
+`
+
+func TestDecode(t *testing.T) {
+	r, err := Decode(strings.NewReader(roles))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r) != 2 {
+		t.Fatalf("expected the groups to be skipped, got %v", r)
+	}
+
+	ctx := context.Background()
+	if !can.Can(ctx, r["user"], "users_books", can.Read, can.Compare(1, 1)) {
+		t.Fatal("expected the route to be granted")
+	}
+	if can.CanField(ctx, r["user"], "users", can.Update, "email") {
+		t.Fatal("expected the field restriction")
+	}
+	if can.Can(ctx, r["admin"], "users", can.Delete, nil) || !can.Can(ctx, r["admin"], "users", can.Update, nil) {
+		t.Fatal("expected the denial")
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	_, err := Decode(strings.NewReader("[user.users]\nabilites = [\"read\"]\n"))
+	if !errors.Is(err, can.ErrUnknownField) || !strings.Contains(err.Error(), "user.users.abilites") {
+		t.Fatalf("expected the unknown field, got %v", err)
+	}
+
+	_, err = Decode(strings.NewReader("[user.users]\nabilities = [\"raed\"]\n"))
+	if !errors.Is(err, can.ErrUnknownAbility) {
+		t.Fatalf("expected the unknown ability, got %v", err)
+	}
+
+	for _, key := range []string{can.IncludeKey, can.AliasesKey} {
+		doc := "[" + key + "]\nold_users = \"users\"\n\n[user.users]\nabilities = [\"read\"]\n"
+		if _, err := Decode(strings.NewReader(doc)); err == nil || !strings.Contains(err.Error(), key) {
+			t.Fatalf("expected %s to be rejected, got %v", key, err)
+		}
+	}
+
+	defer func() { can.Strict = true }()
+	can.Strict = false
+	if _, err := Decode(strings.NewReader("[user.users]\nabilities = [\"read\"]\nfuture = true\n")); err != nil {
+		t.Fatalf("expected unknown fields to be ignored, got %v", err)
+	}
+}
@@ -0,0 +1,16 @@
+package can
+
+import "time"
+
+// Now returns the current time to every time dependent part of can:
+// decision times, cache TTLs, backoff and idempotency windows and SLO
+// latencies. It defaults to time.Now and can be replaced in tests to
+// make time based behavior deterministic (see cantest.Clock). Replace
+// it before checks are made.
+var Now = time.Now
+
+// After waits for a duration to pass on the clock of Now and then sends
+// the time on the returned channel, for the waits of backoff sleeps and
+// watch retries. It defaults to time.After and is replaced together
+// with Now (see cantest.UseClock).
+var After = time.After
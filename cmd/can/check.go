@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/acmacalister/can"
+	"github.com/acmacalister/can/canhcl"
+	"github.com/acmacalister/can/cantoml"
+)
+
+// errDenied is returned by the check command for denied checks, so
+// scripts can rely on the exit code.
+var errDenied = errors.New("denied")
+
+// runValidate implements the validate command.
+func runValidate(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	fs.SetOutput(stdout)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: can validate <role files...>")
+		fmt.Fprintln(fs.Output(), "       can validate --schema json|cue")
+		fs.PrintDefaults()
+	}
+	schema := fs.String("schema", "", "print the schema of role files, json or cue, instead of validating files")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	switch *schema {
+	case "":
+	case "json":
+		_, err := stdout.Write(can.JSONSchema())
+		return err
+	case "cue":
+		_, err := io.WriteString(stdout, can.CUESchema())
+		return err
+	default:
+		return fmt.Errorf("unsupported schema %q, pick one of json, cue", *schema)
+	}
+
+	roles, err := openRoles(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(roles))
+	for name := range roles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(stdout, "ok: %d roles (%s)\n", len(roles), strings.Join(names, ", "))
+
+	return nil
+}
+
+// runCheck implements the check command.
+func runCheck(args []string, stdout io.Writer) error {
+	e, err := decide("check", args, stdout)
+	if err != nil {
+		return err
+	}
+
+	if !e.Allowed {
+		fmt.Fprintln(stdout, "denied")
+		return errDenied
+	}
+	fmt.Fprintln(stdout, "allowed")
+
+	return nil
+}
+
+// runExplain implements the explain command.
+func runExplain(args []string, stdout io.Writer) error {
+	e, err := decide("explain", args, stdout)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprint(stdout, e.String())
+	return nil
+}
+
+// decide parses the flags shared by check and explain and explains the
+// check they describe.
+func decide(name string, args []string, stdout io.Writer) (can.Explanation, error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stdout)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: can %s --role <role> --permission <permission> --ability <ability> <role files...>\n", name)
+		fs.PrintDefaults()
+	}
+	role := fs.String("role", "", "name of the role to check")
+	permission := fs.String("permission", "", "permission to check, e.g. users")
+	ability := fs.String("ability", "", "ability to check, e.g. read")
+	compare := fs.Bool("compare", true, "result of the ownership compare function")
+	labels := labelFlag{}
+	fs.Var(labels, "label", "label of the check as key=value, may be repeated")
+	if err := fs.Parse(args); err != nil {
+		return can.Explanation{}, err
+	}
+
+	if *role == "" || *permission == "" || *ability == "" {
+		fs.Usage()
+		return can.Explanation{}, errors.New("--role, --permission and --ability are required")
+	}
+	a := can.StringToAbility(*ability)
+	if a == can.None {
+		return can.Explanation{}, fmt.Errorf("unknown ability %q", *ability)
+	}
+
+	roles, err := openRoles(fs.Args())
+	if err != nil {
+		return can.Explanation{}, err
+	}
+	r, ok := roles[*role]
+	if !ok {
+		return can.Explanation{}, fmt.Errorf("unknown role %q", *role)
+	}
+
+	ctx := context.Background()
+	if len(labels) > 0 {
+		ctx = can.WithLabels(ctx, can.Labels(labels))
+	}
+	result := *compare
+
+	return can.Explain(ctx, r, *permission, a, func() bool { return result }), nil
+}
+
+// openRoles loads and merges the role files named by args. Files ending
+// in .toml and .hcl are read with cantoml and canhcl, the others as yaml.
+func openRoles(args []string) (can.Roles, error) {
+	if len(args) == 0 {
+		return nil, errors.New("no role files given")
+	}
+
+	var (
+		yml  []string
+		sets []can.Roles
+		errs []error
+	)
+	for _, arg := range args {
+		var open func(string) (can.Roles, error)
+		switch filepath.Ext(arg) {
+		case ".toml":
+			open = cantoml.OpenFile
+		case ".hcl":
+			open = canhcl.OpenFile
+		default:
+			yml = append(yml, arg)
+			continue
+		}
+
+		files, err := filepath.Glob(arg)
+		if err == nil && len(files) == 0 {
+			err = fmt.Errorf("no role files match %q", arg)
+		}
+		for _, f := range files {
+			r, err := open(f)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			sets = append(sets, r)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(yml) > 0 {
+		r, err := can.OpenFiles(yml...)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		sets = append(sets, r)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	if len(sets) == 1 {
+		return sets[0], nil
+	}
+
+	return can.Merge(sets...)
+}
+
+// labelFlag collects repeated key=value flags.
+type labelFlag map[string]string
+
+func (l labelFlag) String() string {
+	pairs := make([]string, 0, len(l))
+	for k, v := range l {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, ",")
+}
+
+func (l labelFlag) Set(s string) error {
+	k, v, ok := strings.Cut(s, "=")
+	if !ok || k == "" {
+		return fmt.Errorf("invalid label %q, want key=value", s)
+	}
+	l[k] = v
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const rbac = "../../testdata/rbac.yml"
+
+func TestValidate(t *testing.T) {
+	var stdout bytes.Buffer
+	if err := runValidate([]string{rbac}, &stdout); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stdout.String(), "2 roles (admin, user)") {
+		t.Fatalf("unexpected output %q", stdout.String())
+	}
+
+	err := runValidate([]string{"../../testdata/invalid/*.yml"}, &stdout)
+	if err == nil || !strings.Contains(err.Error(), "a.yml") || !strings.Contains(err.Error(), "b.yml") {
+		t.Fatalf("expected the problems of every file, got %v", err)
+	}
+
+	stdout.Reset()
+	if err := runValidate([]string{rbac, "../../testdata/rbac.toml"}, &stdout); err != nil || !strings.Contains(stdout.String(), "3 roles (admin, support, user)") {
+		t.Fatalf("expected yaml and toml roles, got %v %q", err, stdout.String())
+	}
+
+	stdout.Reset()
+	if err := runValidate([]string{"--schema", "json"}, &stdout); err != nil || !strings.Contains(stdout.String(), `"$schema"`) {
+		t.Fatalf("expected the json schema, got %v %q", err, stdout.String())
+	}
+	stdout.Reset()
+	if err := runValidate([]string{"--schema", "cue"}, &stdout); err != nil || !strings.Contains(stdout.String(), "#Roles") {
+		t.Fatalf("expected the cue schema, got %v %q", err, stdout.String())
+	}
+	if err := runValidate([]string{"--schema", "xml"}, &stdout); err == nil {
+		t.Fatal("expected an unsupported schema to fail")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		args []string
+		out  string
+		err  error
+	}{
+		{[]string{"--role", "user", "--permission", "users", "--ability", "read", rbac}, "allowed\n", nil},
+		{[]string{"--role", "user", "--permission", "users", "--ability", "delete", rbac}, "denied\n", errDenied},
+		{[]string{"--role", "user", "--permission", "users", "--ability", "read", "--compare=false", rbac}, "denied\n", errDenied},
+	}
+
+	for _, tt := range tests {
+		var stdout bytes.Buffer
+		if err := runCheck(tt.args, &stdout); !errors.Is(err, tt.err) || stdout.String() != tt.out {
+			t.Fatalf("%v: expected %q and %v, got %q and %v", tt.args, tt.out, tt.err, stdout.String(), err)
+		}
+	}
+
+	for _, args := range [][]string{
+		{"--role", "user", "--permission", "users", rbac},
+		{"--role", "nobody", "--permission", "users", "--ability", "read", rbac},
+		{"--role", "user", "--permission", "users", "--ability", "fly", rbac},
+		{"--role", "user", "--permission", "users", "--ability", "read"},
+	} {
+		if err := runCheck(args, &bytes.Buffer{}); err == nil || errors.Is(err, errDenied) {
+			t.Fatalf("%v: expected a usage error, got %v", args, err)
+		}
+	}
+}
+
+func TestExplain(t *testing.T) {
+	var stdout bytes.Buffer
+	if err := runExplain([]string{"--role", "user", "--permission", "users/42", "--ability", "update", rbac}, &stdout); err != nil {
+		t.Fatal(err)
+	}
+	if out := stdout.String(); !strings.Contains(out, `matched permission "users"`) || !strings.HasSuffix(out, "allowed\n") {
+		t.Fatalf("unexpected explanation %q", out)
+	}
+
+	var stderr bytes.Buffer
+	if code := run([]string{"check", "--role", "user", "--permission", "users", "--ability", "delete", rbac}, &stdout, &stderr); code != 1 {
+		t.Fatalf("expected denied checks to exit with 1, got %d", code)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/acmacalister/can"
+)
+
+// runDiff implements the diff command.
+func runDiff(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	fs.SetOutput(stdout)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: can diff [--format text|json] <old role file> <new role file>")
+		fs.PrintDefaults()
+	}
+	format := fs.String("format", "text", "output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("unsupported format %q, pick one of text, json", *format)
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errors.New("expected an old and a new role file")
+	}
+
+	old, err := openRoles(fs.Args()[:1])
+	if err != nil {
+		return err
+	}
+	new, err := openRoles(fs.Args()[1:])
+	if err != nil {
+		return err
+	}
+
+	changes := can.Diff(old, new)
+	if *format == "json" {
+		if changes == nil {
+			changes = []can.Change{}
+		}
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(changes)
+	}
+
+	for _, c := range changes {
+		fmt.Fprintln(stdout, c)
+	}
+
+	return nil
+}
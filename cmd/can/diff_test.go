@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/acmacalister/can"
+)
+
+func TestDiff(t *testing.T) {
+	b, err := os.ReadFile(rbac)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed := filepath.Join(t.TempDir(), "rbac.yml")
+	if err := os.WriteFile(changed, bytes.Replace(b, []byte("      - update\n"), nil, 1), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout bytes.Buffer
+	if err := runDiff([]string{rbac, changed}, &stdout); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stdout.String(), "- user users ability update\n") {
+		t.Fatalf("unexpected diff %q", stdout.String())
+	}
+
+	stdout.Reset()
+	if err := runDiff([]string{"--format", "json", rbac, changed}, &stdout); err != nil {
+		t.Fatal(err)
+	}
+	var changes []can.Change
+	if err := json.Unmarshal(stdout.Bytes(), &changes); err != nil || len(changes) == 0 {
+		t.Fatalf("expected JSON changes, got %v: %s", err, stdout.String())
+	}
+
+	if err := runDiff([]string{rbac}, &bytes.Buffer{}); err == nil {
+		t.Fatal("expected a single file to fail")
+	}
+}
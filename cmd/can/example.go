@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+//go:embed templates
+var templates embed.FS
+
+var (
+	exampleRouters = []string{"chi", "stdlib"}
+	exampleStores  = []string{"file", "consul", "etcd"}
+)
+
+// exampleConfig is the data passed to the example templates.
+type exampleConfig struct {
+	Router string
+	Store  string
+}
+
+// runExample implements the example command.
+func runExample(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("example", flag.ContinueOnError)
+	fs.SetOutput(stdout)
+	router := fs.String("router", "chi", "router to wire the middleware into: "+strings.Join(exampleRouters, ", "))
+	store := fs.String("store", "file", "where roles are loaded from: "+strings.Join(exampleStores, ", "))
+	out := fs.String("out", "can-example", "directory to write the service to")
+	force := fs.Bool("force", false, "overwrite existing files")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	c := exampleConfig{Router: *router, Store: *store}
+	if !contains(exampleRouters, c.Router) {
+		return fmt.Errorf("unsupported router %q, pick one of %s", c.Router, strings.Join(exampleRouters, ", "))
+	}
+	if !contains(exampleStores, c.Store) {
+		return fmt.Errorf("unsupported store %q, pick one of %s", c.Store, strings.Join(exampleStores, ", "))
+	}
+
+	files, err := renderExample(c)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(*out, 0755); err != nil {
+		return err
+	}
+	for name, content := range files {
+		path := filepath.Join(*out, name)
+		if _, err := os.Stat(path); err == nil && !*force {
+			return fmt.Errorf("%s already exists, use -force to overwrite", path)
+		} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout, "wrote", path)
+	}
+
+	return nil
+}
+
+// renderExample renders every template for c, keyed by file name.
+func renderExample(c exampleConfig) (map[string][]byte, error) {
+	t, err := template.ParseFS(templates, "templates/*.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string][]byte)
+	for _, tt := range t.Templates() {
+		var buf bytes.Buffer
+		if err := tt.Execute(&buf, c); err != nil {
+			return nil, err
+		}
+
+		name := strings.TrimSuffix(tt.Name(), ".tmpl")
+		content := buf.Bytes()
+		if strings.HasSuffix(name, ".go") {
+			if content, err = format.Source(content); err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+		}
+		files[name] = content
+	}
+
+	return files, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
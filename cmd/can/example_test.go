@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExample(t *testing.T) {
+	for _, router := range exampleRouters {
+		for _, store := range exampleStores {
+			t.Run(router+"/"+store, func(t *testing.T) {
+				dir := t.TempDir()
+				var stdout bytes.Buffer
+				if err := runExample([]string{"--router", router, "--store", store, "--out", dir}, &stdout); err != nil {
+					t.Fatal(err)
+				}
+
+				for _, name := range []string{"main.go", "policy.yml", "README.md"} {
+					if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				if _, err := parser.ParseFile(token.NewFileSet(), filepath.Join(dir, "main.go"), nil, 0); err != nil {
+					t.Fatal(err)
+				}
+
+				if err := runExample([]string{"--store", store, "--out", dir}, &stdout); err == nil || !strings.Contains(err.Error(), "already exists") {
+					t.Fatalf("expected existing files to be kept, got %v", err)
+				}
+			})
+		}
+	}
+
+	if err := runExample([]string{"--store", "sql", "--out", t.TempDir()}, &bytes.Buffer{}); err == nil {
+		t.Fatal("expected unsupported store to fail")
+	}
+}
+
+func TestRun(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"nope"}, &stdout, &stderr); code != 2 || !strings.Contains(stderr.String(), "unknown command") {
+		t.Fatalf("unexpected exit code %d: %s", code, stderr.String())
+	}
+}
@@ -0,0 +1,77 @@
+// Command can is a companion tool for the can authorization package.
+//
+// Usage:
+//
+//	can <command> [flags]
+//
+// The commands are:
+//
+//	validate  load role files and report every problem, or print their schema
+//	check     check an ability of a role, exiting with 1 when denied
+//	explain   print how a check is decided
+//	matrix    print the access matrix of role files
+//	diff      list the grants changed between two role files
+//	example   generate a runnable sample service
+//
+// For example:
+//
+//	can check --role user --permission users --ability read rbac.yml
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// command is a can subcommand.
+type command struct {
+	name  string
+	usage string
+	run   func(args []string, stdout io.Writer) error
+}
+
+var commands = []command{
+	{"validate", "load role files and report every problem", runValidate},
+	{"check", "check an ability of a role, exiting with 1 when denied", runCheck},
+	{"explain", "print how a check is decided", runExplain},
+	{"matrix", "print the access matrix of role files", runMatrix},
+	{"diff", "list the grants changed between two role files", runDiff},
+	{"example", "generate a runnable sample service", runExample},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run executes the subcommand named by args[0] and returns the exit code.
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return 2
+	}
+
+	for _, c := range commands {
+		if c.name != args[0] {
+			continue
+		}
+		if err := c.run(args[1:], stdout); err != nil {
+			fmt.Fprintf(stderr, "can %s: %v\n", c.name, err)
+			return 1
+		}
+		return 0
+	}
+
+	fmt.Fprintf(stderr, "can: unknown command %q\n", args[0])
+	usage(stderr)
+	return 2
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: can <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", c.name, c.usage)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/acmacalister/can"
+)
+
+// runMatrix implements the matrix command.
+func runMatrix(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("matrix", flag.ContinueOnError)
+	fs.SetOutput(stdout)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: can matrix [--format markdown|csv|html] <role files...>")
+		fs.PrintDefaults()
+	}
+	format := fs.String("format", "markdown", "output format: markdown, csv or html")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var write func(m can.Matrix, w io.Writer) error
+	switch *format {
+	case "markdown":
+		write = can.Matrix.WriteMarkdown
+	case "csv":
+		write = can.Matrix.WriteCSV
+	case "html":
+		write = can.Matrix.WriteHTML
+	default:
+		return fmt.Errorf("unsupported format %q, pick one of markdown, csv, html", *format)
+	}
+
+	roles, err := openRoles(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	return write(can.AccessMatrix(roles), stdout)
+}
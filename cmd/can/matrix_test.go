@@ -0,0 +1,27 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestMatrix(t *testing.T) {
+	for format, want := range map[string]string{
+		"markdown": "| user | users | owner |  | owner |  |\n",
+		"csv":      "user,users,owner,,owner,\n",
+		"html":     "<td>user</td><td>users</td>",
+	} {
+		var stdout bytes.Buffer
+		if err := runMatrix([]string{"--format", format, rbac}, &stdout); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(stdout.String(), want) {
+			t.Fatalf("%s: expected %q in\n%s", format, want, stdout.String())
+		}
+	}
+
+	if err := runMatrix([]string{"--format", "pdf", rbac}, &bytes.Buffer{}); err == nil {
+		t.Fatal("expected unsupported formats to fail")
+	}
+}
@@ -0,0 +1,118 @@
+package can
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// Comparator is a named condition a permission can require (see
+// DiskPermission.Conditions), such as an IP allowlist check. It decides
+// from the check's context, e.g. its Attributes or Labels.
+type Comparator func(ctx context.Context) bool
+
+// Comparators is a registry of named comparators. Comparators can be
+// registered again at any time, for example when the allowlist they
+// consult is reloaded: checks see either the old or the new
+// implementation, never a mix. Every registration of a name bumps its
+// version, which Explain reports. The zero value is ready to use.
+type Comparators struct {
+	mu  sync.Mutex // serializes writers
+	set atomic.Pointer[comparatorSet]
+}
+
+// comparatorSet is an immutable snapshot of a registry.
+type comparatorSet struct {
+	comparators map[string]versionedComparator
+	// generation counts the registrations of every name
+	generation uint64
+}
+
+type versionedComparator struct {
+	fn      Comparator
+	version uint64
+}
+
+// DefaultComparators is the registry Can and Explain consult.
+var DefaultComparators = &Comparators{}
+
+// RegisterComparator registers fn under name in DefaultComparators.
+func RegisterComparator(name string, fn Comparator) uint64 {
+	return DefaultComparators.Register(name, fn)
+}
+
+// Register adds or replaces the comparator called name.
+//
+// name - the name permissions refer to the comparator by
+//
+// fn - the comparator, nil removes it
+//
+// returns - the new version of name, starting at 1
+func (c *Comparators) Register(name string, fn Comparator) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	current := c.load()
+	next := &comparatorSet{
+		comparators: make(map[string]versionedComparator, len(current.comparators)+1),
+		generation:  current.generation + 1,
+	}
+	for k, v := range current.comparators {
+		next.comparators[k] = v
+	}
+
+	v := versionedComparator{fn: fn, version: current.comparators[name].version + 1}
+	next.comparators[name] = v
+	c.set.Store(next)
+
+	return v.version
+}
+
+// Lookup returns the comparator called name and its version.
+func (c *Comparators) Lookup(name string) (Comparator, uint64, bool) {
+	v, ok := c.load().comparators[name]
+	if !ok || v.fn == nil {
+		return nil, v.version, false
+	}
+
+	return v.fn, v.version, true
+}
+
+// Generation returns a number that changes with every registration,
+// used to invalidate cached decisions.
+func (c *Comparators) Generation() uint64 {
+	return c.load().generation
+}
+
+// load returns the current set of comparators.
+func (c *Comparators) load() *comparatorSet {
+	if s := c.set.Load(); s != nil {
+		return s
+	}
+
+	return &comparatorSet{}
+}
+
+// conditionsMet evaluates the conditions of p. Unknown comparators fail
+// the check, a comparator failing once ctx is done leaves it Undecided.
+// When trace is not nil, every evaluated condition is recorded in it.
+func conditionsMet(ctx context.Context, p Permission, trace *Explanation) Outcome {
+	for _, name := range p.Conditions {
+		fn, version, ok := DefaultComparators.Lookup(name)
+		allowed := ok && fn(ctx)
+		if trace != nil {
+			trace.Comparators = append(trace.Comparators, ComparatorResult{Name: name, Version: version, Registered: ok, Allowed: allowed})
+		}
+		if !allowed {
+			if ok && ctx.Err() != nil {
+				if trace != nil {
+					trace.step("condition %q cut off: %v, undecided checks are %s", name, ctx.Err(), OnUndecided)
+				}
+				return Undecided
+			}
+			return Denied
+		}
+	}
+
+	return Allowed
+}
@@ -0,0 +1,79 @@
+package can
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestComparators(t *testing.T) {
+	defer func(c *Comparators) { DefaultComparators = c }(DefaultComparators)
+	DefaultComparators = &Comparators{}
+
+	r, err := ConfigErr(DiskRoles{"user": DiskRole{"reports": DiskPermission{
+		Abilities:  []string{"read"},
+		Conditions: []string{"office_network"},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := WithAttributes(context.Background(), Attributes{"ip": "10.0.0.1"})
+	yes := func() bool { return true }
+	if Can(ctx, r["user"], "reports", Read, yes) {
+		t.Fatal("expected an unregistered comparator to deny")
+	}
+
+	allowlist := func(ips ...string) Comparator {
+		return func(ctx context.Context) bool {
+			for _, ip := range ips {
+				if AttributesFrom(ctx)["ip"] == ip {
+					return true
+				}
+			}
+			return false
+		}
+	}
+
+	if v := RegisterComparator("office_network", allowlist("10.0.0.1")); v != 1 {
+		t.Fatalf("expected version 1, got %d", v)
+	}
+	cache := Cached(Local, time.Minute, 10)
+	if !Can(ctx, r["user"], "reports", Read, yes) || !cache.Authorize(ctx, r["user"], "reports", Read, yes) {
+		t.Fatal("expected the allowlisted ip to be allowed")
+	}
+
+	if v := RegisterComparator("office_network", allowlist("10.0.0.2")); v != 2 {
+		t.Fatalf("expected version 2, got %d", v)
+	}
+	if Can(ctx, r["user"], "reports", Read, yes) || cache.Authorize(ctx, r["user"], "reports", Read, yes) {
+		t.Fatal("expected the replaced comparator to be used")
+	}
+
+	e := Explain(ctx, r["user"], "reports", Read, yes)
+	if len(e.Comparators) != 1 || e.Comparators[0].Version != 2 || e.Comparators[0].Allowed {
+		t.Fatalf("expected the comparator version to be explained, got %+v", e.Comparators)
+	}
+}
+
+func TestComparatorsConcurrent(t *testing.T) {
+	var c Comparators
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			c.Register("x", func(context.Context) bool { return true })
+		}()
+		go func() {
+			defer wg.Done()
+			c.Lookup("x")
+		}()
+	}
+	wg.Wait()
+
+	if _, v, ok := c.Lookup("x"); !ok || v != 8 {
+		t.Fatalf("expected 8 registrations, got %d", v)
+	}
+}
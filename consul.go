@@ -0,0 +1,123 @@
+package can
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Consul is a KV backed by the Consul HTTP API. It uses blocking queries,
+// so Watch is notified as soon as the key changes.
+type Consul struct {
+	// Address is the base URL of the Consul agent, e.g. http://127.0.0.1:8500
+	Address string
+	// Token is an optional ACL token sent with every request.
+	Token string
+	// Wait is the maximum duration of a blocking query. Defaults to 5m.
+	Wait time.Duration
+	// Client is the http client used for requests. Defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+type consulPair struct {
+	Value       []byte `json:"Value"`
+	ModifyIndex uint64 `json:"ModifyIndex"`
+}
+
+// Get implements the KV interface.
+func (c *Consul) Get(ctx context.Context, key string, index uint64) ([]byte, uint64, error) {
+	q := url.Values{}
+	if index > 0 {
+		wait := c.Wait
+		if wait == 0 {
+			wait = 5 * time.Minute
+		}
+		q.Set("index", strconv.FormatUint(index, 10))
+		q.Set("wait", wait.String())
+	}
+
+	u := fmt.Sprintf("%s/v1/kv/%s?%s", c.Address, consulKey(key), q.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if c.Token != "" {
+		req.Header.Set("X-Consul-Token", c.Token)
+	}
+
+	resp, err := httpClient(c.Client).Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		// the index of the deletion, so the next query waits for the key
+		index, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+		return nil, index, ErrKeyNotFound
+	default:
+		return nil, 0, fmt.Errorf("can: consul returned %s", resp.Status)
+	}
+
+	var pairs []consulPair
+	if err := json.NewDecoder(resp.Body).Decode(&pairs); err != nil {
+		return nil, 0, err
+	}
+	if len(pairs) == 0 {
+		return nil, 0, ErrKeyNotFound
+	}
+
+	return pairs[0].Value, pairs[0].ModifyIndex, nil
+}
+
+// Put implements the KVWriter interface.
+func (c *Consul) Put(ctx context.Context, key string, value []byte) error {
+	u := fmt.Sprintf("%s/v1/kv/%s", c.Address, consulKey(key))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u, bytes.NewReader(value))
+	if err != nil {
+		return err
+	}
+	if c.Token != "" {
+		req.Header.Set("X-Consul-Token", c.Token)
+	}
+
+	resp, err := httpClient(c.Client).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("can: consul returned %s", resp.Status)
+	}
+
+	return nil
+}
+
+// consulKey escapes the segments of key for the path of a request, its
+// slashes keep separating the folders of the key.
+func consulKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// httpClient returns c or http.DefaultClient if c is nil.
+func httpClient(c *http.Client) *http.Client {
+	if c == nil {
+		return http.DefaultClient
+	}
+
+	return c
+}
@@ -0,0 +1,72 @@
+package can
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestConsul(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/kv/can/roles" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("X-Consul-Token") != "secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.Method == http.MethodPut {
+			fmt.Fprint(w, "true")
+			return
+		}
+		index := "7"
+		if r.URL.Query().Get("index") == "7" {
+			index = "8"
+		}
+		fmt.Fprintf(w, `[{"Key":"can/roles","Value":"YWRtaW46IHt9","ModifyIndex":%s}]`, index)
+	}))
+	defer srv.Close()
+
+	c := &Consul{Address: srv.URL, Token: "secret"}
+	v, index, err := c.Get(context.Background(), "can/roles", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(v) != "admin: {}" || index != 7 {
+		t.Fatalf("unexpected value %q at index %d", v, index)
+	}
+
+	if _, index, err = c.Get(context.Background(), "can/roles", index); err != nil || index != 8 {
+		t.Fatalf("expected blocking query to return index 8, got %d %v", index, err)
+	}
+
+	if err := c.Put(context.Background(), "can/roles", []byte("admin: {}")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := c.Get(context.Background(), "missing", 0); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("expected key not found, got %v", err)
+	}
+
+	var paths []string
+	escaped := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.Header().Set("X-Consul-Index", "12")
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer escaped.Close()
+	c = &Consul{Address: escaped.URL}
+	if _, index, err := c.Get(context.Background(), "can/roles?v=2#1", 0); !errors.Is(err, ErrKeyNotFound) || index != 12 {
+		t.Fatalf("expected key not found at index 12, got %d %v", index, err)
+	}
+	if err := c.Put(context.Background(), "can/my roles", nil); err == nil {
+		t.Fatal("expected the put to fail")
+	}
+	if len(paths) != 2 || paths[0] != "/v1/kv/can/roles?v=2#1" || paths[1] != "/v1/kv/can/my roles" {
+		t.Fatalf("expected the keys to be escaped, got %q", paths)
+	}
+}
@@ -0,0 +1,134 @@
+package can
+
+import (
+	"context"
+	"net/http"
+)
+
+// Subject is the user or client a request is made by, along with the
+// names of the roles assigned to it.
+type Subject struct {
+	ID    string   `json:"id" yaml:"id"`
+	Roles []string `json:"roles" yaml:"roles"`
+}
+
+type subjectKey struct{}
+
+// WithSubject returns a copy of ctx carrying the subject of the current
+// request. Authentication middleware should call it once the user is
+// known; a Guard with a Store resolves the subject's roles from it.
+func WithSubject(ctx context.Context, s Subject) context.Context {
+	return context.WithValue(ctx, subjectKey{}, s)
+}
+
+// SubjectFrom returns the subject stored in ctx and true, or the zero
+// Subject and false if there is none.
+func SubjectFrom(ctx context.Context) (Subject, bool) {
+	s, ok := ctx.Value(subjectKey{}).(Subject)
+	return s, ok
+}
+
+// Attributes is a bag of request specific data used by authorizers that
+// decide on more than the role, such as remote authorization services.
+type Attributes map[string]any
+
+type attributesKey struct{}
+
+// WithAttributes returns a copy of ctx carrying the given attributes.
+func WithAttributes(ctx context.Context, a Attributes) context.Context {
+	return context.WithValue(ctx, attributesKey{}, a)
+}
+
+// AttributesFrom returns the attributes stored in ctx, or nil if there are none.
+func AttributesFrom(ctx context.Context) Attributes {
+	a, _ := ctx.Value(attributesKey{}).(Attributes)
+	return a
+}
+
+type roleKey struct{}
+
+// WithRole returns a copy of ctx carrying the role of the current
+// request. Authentication middleware should call it once the user is known.
+func WithRole(ctx context.Context, role Role) context.Context {
+	return context.WithValue(ctx, roleKey{}, role)
+}
+
+// RoleFrom returns the role stored in ctx and true, or nil and false if
+// there is none.
+func RoleFrom(ctx context.Context) (Role, bool) {
+	role, ok := ctx.Value(roleKey{}).(Role)
+	return role, ok
+}
+
+type tenantKey struct{}
+
+// WithTenant returns a copy of ctx carrying the tenant of the current request.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFrom returns the tenant stored in ctx and true, or "" and false
+// if there is none.
+func TenantFrom(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(tenantKey{}).(string)
+	return tenant, ok
+}
+
+// Labels describe the circumstances of a check, such as the environment
+// a service runs in. Conditional grants are only applied when the labels
+// match their selectors.
+type Labels map[string]string
+
+type labelsKey struct{}
+
+// WithLabels returns a copy of ctx carrying labels, merged over any
+// labels ctx already carries.
+func WithLabels(ctx context.Context, l Labels) context.Context {
+	if existing := LabelsFrom(ctx); len(existing) > 0 {
+		merged := make(Labels, len(existing)+len(l))
+		for k, v := range existing {
+			merged[k] = v
+		}
+		for k, v := range l {
+			merged[k] = v
+		}
+		l = merged
+	}
+
+	return context.WithValue(ctx, labelsKey{}, l)
+}
+
+// LabelsFrom returns the labels stored in ctx, or nil if there are none.
+func LabelsFrom(ctx context.Context) Labels {
+	l, _ := ctx.Value(labelsKey{}).(Labels)
+	return l
+}
+
+// Request describes the HTTP request a check is made for.
+type Request struct {
+	Method     string `json:"method"`
+	Path       string `json:"path"`
+	Host       string `json:"host,omitempty"`
+	RemoteAddr string `json:"remote_addr,omitempty"`
+}
+
+type requestKey struct{}
+
+// WithRequest returns a copy of ctx carrying a description of r. Guard
+// calls it before asking its Authorizer, so authorizers such as Webhook
+// can forward where a check comes from.
+func WithRequest(ctx context.Context, r *http.Request) context.Context {
+	return context.WithValue(ctx, requestKey{}, Request{
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		Host:       r.Host,
+		RemoteAddr: r.RemoteAddr,
+	})
+}
+
+// RequestFrom returns the request stored in ctx and true, or the zero
+// Request and false if there is none.
+func RequestFrom(ctx context.Context) (Request, bool) {
+	r, ok := ctx.Value(requestKey{}).(Request)
+	return r, ok
+}
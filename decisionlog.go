@@ -0,0 +1,196 @@
+package can
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DecisionLogVersion is the version of the DecisionEvent format. Fields
+// may be added within a version, a field is only removed or changed in
+// meaning with a new version.
+const DecisionLogVersion = "can.decision/v1"
+
+// DecisionEvent is the stable, machine-readable form of a Decision, for
+// compliance tooling consuming decision logs (see DecisionEncoder and
+// DecisionLogSchema). Unlike Decision, whose json form follows the Go
+// API, its fields only change with DecisionLogVersion.
+type DecisionEvent struct {
+	Version   string    `json:"version"`
+	Timestamp time.Time `json:"timestamp"`
+	Subject   string    `json:"subject,omitempty"`
+	Roles     []string  `json:"roles,omitempty"`
+	Tenant    string    `json:"tenant,omitempty"`
+	Method    string    `json:"method,omitempty"`
+	Path      string    `json:"path,omitempty"`
+	// Permission and Ability are what the check was made for.
+	Permission string `json:"permission"`
+	Ability    string `json:"ability"`
+	// Outcome is allowed, denied or undecided (see Outcome) and Allowed
+	// whether the request went through, which differs for undecided
+	// checks and in shadow mode, where denied requests go through too.
+	Outcome       string `json:"outcome"`
+	Allowed       bool   `json:"allowed"`
+	PolicyVersion uint64 `json:"policy_version,omitempty"`
+	TraceID       string `json:"trace_id,omitempty"`
+	Required      bool   `json:"required,omitempty"`
+	Replay        bool   `json:"replay,omitempty"`
+	Shadow        bool   `json:"shadow,omitempty"`
+	Synthetic     bool   `json:"synthetic,omitempty"`
+	// Actor is the subject acting with the role of Subject (see
+	// Impersonate).
+	Actor string `json:"actor,omitempty"`
+	// Elevations are the reasons of the active elevations of the
+	// subject (see Store.Elevate).
+	Elevations []string `json:"elevations,omitempty"`
+	// QuotaWarning is the usage of a quota past its warning threshold
+	// (see QuotaWarning), such as "4 of 5/day".
+	QuotaWarning string `json:"quota_warning,omitempty"`
+}
+
+// Event converts the decision into its DecisionEvent.
+func (d Decision) Event() DecisionEvent {
+	e := DecisionEvent{
+		Version:       DecisionLogVersion,
+		Timestamp:     d.Time.UTC(),
+		Subject:       d.Subject,
+		Roles:         d.Roles,
+		Tenant:        d.Tenant,
+		Method:        d.Method,
+		Path:          d.Path,
+		Permission:    d.Permission,
+		Ability:       d.Ability.text(),
+		Outcome:       Denied.String(),
+		Allowed:       d.Allowed || d.Shadow,
+		PolicyVersion: d.PolicyVersion,
+		TraceID:       d.TraceID,
+		Required:      d.Required,
+		Replay:        d.Replay,
+		Shadow:        d.Shadow,
+		Synthetic:     d.Synthetic,
+	}
+	switch {
+	case d.Undecided:
+		e.Outcome = Undecided.String()
+	case d.Allowed:
+		e.Outcome = Allowed.String()
+	}
+	if d.Impersonation != nil {
+		e.Actor = d.Impersonation.Actor
+	}
+	for _, elevation := range d.Elevations {
+		e.Elevations = append(e.Elevations, elevation.Reason)
+	}
+	if d.QuotaWarning != nil {
+		e.QuotaWarning = d.QuotaWarning.String()
+	}
+
+	return e
+}
+
+// DecisionEncoder writes decisions as JSON lines of DecisionEvent. It is
+// safe for concurrent use.
+type DecisionEncoder struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewDecisionEncoder returns an encoder writing to w, such as a log file:
+//
+//	g := &can.Guard{Audit: can.NewDecisionEncoder(f).Audit}
+func NewDecisionEncoder(w io.Writer) *DecisionEncoder {
+	return &DecisionEncoder{enc: json.NewEncoder(w)}
+}
+
+// Encode writes the event of d on a line of its own.
+func (e *DecisionEncoder) Encode(d Decision) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.enc.Encode(d.Event())
+}
+
+// Audit is Encode with the signature of Guard.Audit. Its error makes the
+// Guard deny the checks that must be audited.
+func (e *DecisionEncoder) Audit(ctx context.Context, d Decision) error {
+	return e.Encode(d)
+}
+
+// DecisionLogSchema returns a JSON Schema (draft 2020-12) of the events
+// of DecisionLogVersion.
+func DecisionLogSchema() []byte {
+	str := func(description string) map[string]any {
+		return map[string]any{"type": "string", "description": description}
+	}
+	boolean := func(description string) map[string]any {
+		return map[string]any{"type": "boolean", "description": description}
+	}
+	stringArray := func(description string) map[string]any {
+		return map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": description}
+	}
+
+	schema := map[string]any{
+		"$schema":     "https://json-schema.org/draft/2020-12/schema",
+		"$id":         "https://github.com/acmacalister/can/" + DecisionLogVersion,
+		"title":       "can decision event",
+		"description": "an authorization decision, one JSON object per line",
+		"type":        "object",
+		"required":    []string{"version", "timestamp", "permission", "ability", "outcome", "allowed"},
+		"properties": map[string]any{
+			"version":    map[string]any{"const": DecisionLogVersion, "description": "version of the event format"},
+			"timestamp":  map[string]any{"type": "string", "format": "date-time", "description": "when the decision was made, in UTC"},
+			"subject":    str("ID of the user or client the request was made by"),
+			"roles":      stringArray("role names of the subject"),
+			"tenant":     str("tenant of the request"),
+			"method":     str("HTTP method of the request"),
+			"path":       str("path of the request"),
+			"permission": str("permission the check was made for"),
+			"ability": map[string]any{
+				"anyOf": []any{
+					map[string]any{"enum": append(schemaAbilities[:len(schemaAbilities):len(schemaAbilities)], None.String())},
+					map[string]any{"type": "string", "pattern": "^[0-9]+$"},
+				},
+				"description": "ability the check was made for, the number of a custom ability or none for methods without one",
+			},
+			"outcome":        map[string]any{"enum": []string{Allowed.String(), Denied.String(), Undecided.String()}, "description": "result of the check"},
+			"allowed":        boolean("whether the request went through"),
+			"policy_version": map[string]any{"type": "integer", "minimum": 0, "description": "version of the roles the decision was made with"},
+			"trace_id":       str("distributed trace of the request"),
+			"required":       boolean("whether the policy requires the decision to be audited"),
+			"replay":         boolean("whether the request retried an allowed create"),
+			"shadow":         boolean("whether the guard let denied requests through"),
+			"synthetic":      boolean("whether the request came from synthetic monitoring"),
+			"actor":          str("subject acting with the role of subject"),
+			"elevations":     stringArray("reasons of the active elevations of the subject"),
+			"quota_warning":  str("usage of a quota past its warning threshold, such as 4 of 5/day"),
+		},
+	}
+
+	b, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+
+	return append(b, '\n')
+}
+
+// TraceIDFromHeader returns the trace ID of the W3C traceparent header
+// of r, or "" if it has none.
+func TraceIDFromHeader(r *http.Request) string {
+	// version "-" trace-id "-" parent-id "-" flags
+	parts := strings.Split(r.Header.Get("traceparent"), "-")
+	if len(parts) < 4 || len(parts[1]) != 32 || strings.Trim(parts[1], "0") == "" {
+		return ""
+	}
+	for _, c := range parts[1] {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return ""
+		}
+	}
+
+	return parts[1]
+}
@@ -0,0 +1,122 @@
+package can
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecisionEncoder(t *testing.T) {
+	s := NewStore(Roles{"user": NewRole().Allow("users", Read).Build()})
+	var buf bytes.Buffer
+	g := &Guard{Store: s, Audit: NewDecisionEncoder(&buf).Audit}
+
+	for _, method := range []string{http.MethodGet, http.MethodDelete} {
+		req := httptest.NewRequest(method, "/users", nil)
+		req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		req = req.WithContext(WithSubject(req.Context(), Subject{ID: "u1", Roles: []string{"user"}}))
+		g.Check(httptest.NewRecorder(), req, "users", BuildFromMethod(method))
+	}
+
+	dec := json.NewDecoder(&buf)
+	dec.DisallowUnknownFields()
+	var events []DecisionEvent
+	for dec.More() {
+		var e DecisionEvent
+		if err := dec.Decode(&e); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, e)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected an event per decision, got %d", len(events))
+	}
+
+	e := events[0]
+	if e.Version != DecisionLogVersion || e.Subject != "u1" || !reflect.DeepEqual(e.Roles, []string{"user"}) || e.Ability != "read" ||
+		e.Outcome != "allowed" || !e.Allowed || e.PolicyVersion != 1 || e.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("unexpected event %+v", e)
+	}
+	if e.Timestamp.Location() != time.UTC {
+		t.Fatalf("expected a UTC timestamp, got %v", e.Timestamp)
+	}
+	if e := events[1]; e.Outcome != "denied" || e.Allowed || e.Ability != "delete" {
+		t.Fatalf("expected a denied delete, got %+v", e)
+	}
+
+	if e := (Decision{Undecided: true, Allowed: true, Impersonation: &Impersonation{Actor: "s1"}}).Event(); e.Outcome != "undecided" || e.Actor != "s1" {
+		t.Fatalf("expected an undecided impersonated event, got %+v", e)
+	}
+	if e := (Decision{Ability: Ability(1 << 8)}).Event(); e.Ability != "256" {
+		t.Fatalf("expected a custom ability to be logged by number, got %q", e.Ability)
+	}
+
+	buf.Reset()
+	g.Shadow = true
+	req := httptest.NewRequest(http.MethodDelete, "/users", nil)
+	g.Check(httptest.NewRecorder(), req.WithContext(WithSubject(req.Context(), Subject{ID: "u1", Roles: []string{"user"}})), "users", Delete)
+	var shadow DecisionEvent
+	if err := json.NewDecoder(&buf).Decode(&shadow); err != nil {
+		t.Fatal(err)
+	}
+	if shadow.Outcome != "denied" || !shadow.Allowed || !shadow.Shadow {
+		t.Fatalf("expected a denial the shadow guard let through, got %+v", shadow)
+	}
+}
+
+func TestDecisionLogSchema(t *testing.T) {
+	var schema struct {
+		Required   []string                  `json:"required"`
+		Properties map[string]map[string]any `json:"properties"`
+	}
+	if err := json.Unmarshal(DecisionLogSchema(), &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	// the schema documents every field of the event
+	typ := reflect.TypeOf(DecisionEvent{})
+	for i := 0; i < typ.NumField(); i++ {
+		name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+		if _, ok := schema.Properties[name]; !ok {
+			t.Errorf("field %q is missing from the schema", name)
+		}
+	}
+	if len(schema.Properties) != typ.NumField() {
+		t.Fatalf("expected %d properties, got %d", typ.NumField(), len(schema.Properties))
+	}
+	for _, name := range schema.Required {
+		if _, ok := schema.Properties[name]; !ok {
+			t.Fatalf("required field %q is not a property", name)
+		}
+	}
+}
+
+func TestTraceIDFromHeader(t *testing.T) {
+	for header, want := range map[string]string{
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01": "4bf92f3577b34da6a3ce929d0e0e4736",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01": "",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01": "",
+		"00-4bf92f-00f067aa0ba902b7-01":                           "",
+		"":                                                        "",
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("traceparent", header)
+		if got := TraceIDFromHeader(req); got != want {
+			t.Fatalf("expected %q for %q, got %q", want, header, got)
+		}
+	}
+
+	g := &Guard{TraceID: func(r *http.Request) string { return "custom" }, Audit: func(ctx context.Context, d Decision) error {
+		if d.TraceID != "custom" {
+			t.Fatalf("expected the trace ID of the guard, got %q", d.TraceID)
+		}
+		return nil
+	}}
+	g.Check(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil), "index", Read)
+}
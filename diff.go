@@ -0,0 +1,180 @@
+package can
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ChangeKind is the kind of a Change.
+type ChangeKind int
+
+const (
+	// Added is a grant only in the new roles.
+	Added ChangeKind = iota
+	// Removed is a grant only in the old roles.
+	Removed
+	// Changed is a grant in both roles with a different value.
+	Changed
+)
+
+// String implements the Stringer interface.
+func (k ChangeKind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	}
+
+	return "changed"
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (k ChangeKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (k *ChangeKind) UnmarshalText(text []byte) error {
+	for _, kind := range []ChangeKind{Added, Removed, Changed} {
+		if kind.String() == string(text) {
+			*k = kind
+			return nil
+		}
+	}
+
+	return fmt.Errorf("can: unknown change kind %q", text)
+}
+
+// Change is a difference between two sets of roles.
+type Change struct {
+	Kind       ChangeKind `json:"kind"`
+	Role       string     `json:"role"`
+	Permission string     `json:"permission"`
+	// Grant names what changed: "ability <ability>", "deny <ability>",
+	// "audit <ability>", "condition <name>", "cidr <network>",
+	// "quota <ability>", "fields <ability>", "selector <label>" or
+	// "resource".
+	Grant string `json:"grant"`
+	// Old and New are the values of quotas, fields, selectors and
+	// resources, empty for the other grants.
+	Old string `json:"old,omitempty"`
+	New string `json:"new,omitempty"`
+}
+
+// String formats the change as a line of a diff.
+func (c Change) String() string {
+	line := c.Role + " " + c.Permission + " " + c.Grant
+	switch {
+	case c.Kind == Added && c.New != "":
+		return "+ " + line + ": " + c.New
+	case c.Kind == Added:
+		return "+ " + line
+	case c.Kind == Removed && c.Old != "":
+		return "- " + line + ": " + c.Old
+	case c.Kind == Removed:
+		return "- " + line
+	}
+
+	return "~ " + line + ": " + c.Old + " -> " + c.New
+}
+
+// Diff lists the grants added, removed and changed from old to new, for
+// surfacing permission changes in code review and change management.
+// Roles and permissions only in one set show up as all their grants
+// being added or removed.
+//
+// old - the roles before the change
+//
+// new - the roles after the change
+//
+// returns - the changes sorted by role, permission and grant
+func Diff(old, new Roles) []Change {
+	var changes []Change
+
+	for _, role := range sortedKeys(union(old, new)) {
+		for _, permission := range sortedKeys(union(old[role], new[role])) {
+			before, after := grants(old[role], permission), grants(new[role], permission)
+			for _, grant := range sortedKeys(union(before, after)) {
+				o, inOld := before[grant]
+				n, inNew := after[grant]
+				c := Change{Role: role, Permission: permission, Grant: grant, Old: o, New: n}
+				switch {
+				case !inOld:
+					c.Kind = Added
+				case !inNew:
+					c.Kind = Removed
+				case o != n:
+					c.Kind = Changed
+				default:
+					continue
+				}
+				changes = append(changes, c)
+			}
+		}
+	}
+
+	return changes
+}
+
+// union returns the keys of a and b.
+func union[M ~map[string]V, V any](a, b M) map[string]struct{} {
+	keys := make(map[string]struct{}, len(a)+len(b))
+	for k := range a {
+		keys[k] = struct{}{}
+	}
+	for k := range b {
+		keys[k] = struct{}{}
+	}
+
+	return keys
+}
+
+// grants flattens the permission of role into its grants and their
+// values, see Change.Grant.
+func grants(role Role, permission string) map[string]string {
+	p, ok := role[permission]
+	if !ok {
+		return nil
+	}
+
+	g := make(map[string]string)
+	for a := range p.Abilities {
+		g["ability "+a.text()] = ""
+	}
+	for a := range p.Denied {
+		g["deny "+a.text()] = ""
+	}
+	for a := range p.Audited {
+		g["audit "+a.text()] = ""
+	}
+	for _, c := range p.Conditions {
+		g["condition "+c] = ""
+	}
+	for _, n := range p.Networks {
+		g["cidr "+n.String()] = ""
+	}
+	for a, q := range p.Quotas {
+		g["quota "+a.text()] = q.String()
+	}
+	for a, fields := range p.Fields {
+		g["fields "+a.text()] = sortedJoin(fields)
+	}
+	for label, values := range p.Selectors {
+		g["selector "+label] = sortedJoin(values)
+	}
+	if p.Resource != "" {
+		g["resource"] = p.Resource
+	}
+
+	return g
+}
+
+// sortedJoin joins a sorted copy of values with commas.
+func sortedJoin(values []string) string {
+	sorted := append([]string(nil), values...)
+	sort.Strings(sorted)
+
+	return strings.Join(sorted, ",")
+}
@@ -0,0 +1,51 @@
+package can
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	old := Roles{
+		"admin": NewRole().Allow("users", All).Build(),
+		"user":  NewRole().Allow("users", Read, Update).Fields("users", Update, "name", "avatar").Build(),
+		"guest": NewRole().Allow("index", Read).Build(),
+	}
+	new := Roles{
+		"admin": NewRole().Allow("users", All).Deny("users", Delete).Build(),
+		"user":  NewRole().Allow("users", Read).Fields("users", Update, "name").Where("users", EnvironmentLabel, "staging").Build(),
+		"ops":   NewRole().Allow("metrics", Read).Build(),
+	}
+
+	var lines []string
+	for _, c := range Diff(old, new) {
+		lines = append(lines, c.String())
+	}
+
+	want := []string{
+		"+ admin users deny delete",
+		"- guest index ability read",
+		"+ ops metrics ability read",
+		"~ user users fields update: avatar,name -> name",
+		"+ user users selector environment: staging",
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected diff:\n%s", strings.Join(lines, "\n"))
+	}
+
+	if changes := Diff(old, old); len(changes) != 0 {
+		t.Fatalf("expected no changes, got %v", changes)
+	}
+
+	quotas := func(q map[Ability]Quota) Roles {
+		return Roles{"user": {"exports": {Abilities: map[Ability]struct{}{All: {}}, Quotas: q}}}
+	}
+	lines = nil
+	for _, c := range Diff(quotas(map[Ability]Quota{Ability(1 << 8): {5, time.Hour}}), quotas(map[Ability]Quota{Ability(1 << 9): {5, time.Hour}})) {
+		lines = append(lines, c.String())
+	}
+	if len(lines) != 2 || !strings.Contains(lines[0], "quota 256") || !strings.Contains(lines[1], "quota 512") {
+		t.Fatalf("expected custom abilities' quotas to be told apart, got %v", lines)
+	}
+}
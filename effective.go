@@ -0,0 +1,110 @@
+package can
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// AbilitySet is a set of abilities, see Role.Allowed.
+type AbilitySet map[Ability]struct{}
+
+// Has reports whether the set contains ability.
+func (s AbilitySet) Has(ability Ability) bool {
+	_, ok := s[ability]
+	return ok
+}
+
+// Abilities returns the abilities of the set, sorted.
+func (s AbilitySet) Abilities() []Ability {
+	abilities := make([]Ability, 0, len(s))
+	for a := range s {
+		abilities = append(abilities, a)
+	}
+	sort.Slice(abilities, func(i, j int) bool { return abilities[i] < abilities[j] })
+
+	return abilities
+}
+
+// MarshalJSON implements the json Marshaler interface, encoding the set
+// as a sorted list of ability names.
+func (s AbilitySet) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.Abilities())
+}
+
+// UnmarshalJSON implements the json Unmarshaler interface.
+func (s *AbilitySet) UnmarshalJSON(b []byte) error {
+	var abilities []Ability
+	if err := json.Unmarshal(b, &abilities); err != nil {
+		return err
+	}
+
+	*s = make(AbilitySet, len(abilities))
+	for _, a := range abilities {
+		(*s)[a] = struct{}{}
+	}
+
+	return nil
+}
+
+// Allowed returns the abilities the role holds on resource, for UIs
+// deciding which buttons and menus to show without probing Can for every
+// ability. The permission of resource is found like Can does, through
+// the hierarchy and aliases, and its denials are removed. All and Skip
+// grant read, create, update and delete, and All is only in the set if
+// nothing is denied.
+//
+// The abilities may still depend on the check: the compare function,
+// selectors and conditions of the permission are applied by Can.
+//
+// resource - the permission to look up
+//
+// returns - the abilities held, empty if none
+func (r Role) Allowed(resource string) AbilitySet {
+	set := make(AbilitySet)
+	if r == nil || resource == "" {
+		return set
+	}
+
+	p, ok := lookup(r, resource)
+	if !ok {
+		if unlistedAllowed(resource) {
+			set[All] = struct{}{}
+			for _, a := range crud {
+				set[a] = struct{}{}
+			}
+		}
+		return set
+	}
+
+	_, all := p.Abilities[All]
+	_, skip := p.Abilities[Skip]
+	for _, a := range crud {
+		if _, ok := p.Abilities[a]; (ok || all || skip) && !p.Denies(a) {
+			set[a] = struct{}{}
+		}
+	}
+	if (all || skip) && len(p.Denied) == 0 {
+		set[All] = struct{}{}
+	}
+
+	return set
+}
+
+// crud are the abilities granted by All.
+var crud = []Ability{Read, Create, Update, Delete}
+
+// EffectivePermissions returns the role of a subject holding the named
+// roles, combined in order like Store.SubjectRole does, to answer what
+// a user can do. Unknown names are skipped.
+//
+// roleNames - the roles of the subject
+//
+// returns - a copy of the merged role, nil if no name is known
+func (r Roles) EffectivePermissions(roleNames ...string) Role {
+	role, ok, _ := mergeNamed(r, roleNames)
+	if !ok {
+		return nil
+	}
+
+	return role.Clone()
+}
@@ -0,0 +1,59 @@
+package can
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestAllowed(t *testing.T) {
+	role := NewRole().Allow("projects", Read).Allow("projects/7", All).Deny("projects/7", Delete).Allow("books", All).Build()
+
+	if got := role.Allowed("projects/3").Abilities(); !reflect.DeepEqual(got, []Ability{Read}) {
+		t.Fatalf("expected the parent's abilities, got %v", got)
+	}
+	if got := role.Allowed("projects/7").Abilities(); !reflect.DeepEqual(got, []Ability{Read, Create, Update}) {
+		t.Fatalf("expected all but the denied ability, got %v", got)
+	}
+	if s := role.Allowed("books"); !s.Has(All) || !s.Has(Delete) {
+		t.Fatalf("expected every ability, got %v", s.Abilities())
+	}
+	if s := role.Allowed("users"); len(s) != 0 {
+		t.Fatalf("expected no ability, got %v", s.Abilities())
+	}
+
+	b, err := json.Marshal(role.Allowed("projects/7"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `["read","create","update"]` {
+		t.Fatalf("unexpected json %s", b)
+	}
+	var s AbilitySet
+	if err := json.Unmarshal(b, &s); err != nil || !reflect.DeepEqual(s, role.Allowed("projects/7")) {
+		t.Fatalf("unexpected round trip %v: %v", s, err)
+	}
+}
+
+func TestEffectivePermissions(t *testing.T) {
+	roles := Roles{
+		"user":   NewRole().Allow("users", Read).Build(),
+		"editor": NewRole().Allow("users", Update).Allow("posts", All).Build(),
+	}
+
+	role := roles.EffectivePermissions("user", "editor", "unknown")
+	if got := role.Allowed("users").Abilities(); !reflect.DeepEqual(got, []Ability{Read, Update}) {
+		t.Fatalf("expected merged abilities, got %v", got)
+	}
+	if !role.Allowed("posts").Has(Delete) {
+		t.Fatal("expected the editor's posts")
+	}
+
+	role["users"].Abilities[Delete] = struct{}{}
+	if roles["user"].Allowed("users").Has(Delete) {
+		t.Fatal("expected a copy")
+	}
+	if roles.EffectivePermissions("unknown") != nil {
+		t.Fatal("expected nil for unknown roles")
+	}
+}
@@ -0,0 +1,141 @@
+package can
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidElevation is returned by Elevate for elevations without a
+// reason, with a duration that isn't positive or to an unknown role.
+var ErrInvalidElevation = errors.New("can: invalid elevation")
+
+// Elevation is temporary access to a role, break-glass access for an
+// on-call incident for instance.
+type Elevation struct {
+	// Token identifies the elevation, see RevokeElevation.
+	Token   string    `json:"token"`
+	Subject string    `json:"subject"`
+	Role    string    `json:"role"`
+	Reason  string    `json:"reason"`
+	Issued  time.Time `json:"issued"`
+	Expires time.Time `json:"expires"`
+}
+
+// Active reports whether the elevation hasn't expired at t.
+func (e Elevation) Active(t time.Time) bool {
+	return t.Before(e.Expires)
+}
+
+// Elevate gives a subject the permissions of a role for a limited time:
+// SubjectRole merges the role into the effective role of the subject
+// until the elevation expires or is revoked. Every elevation is reported
+// to OnElevate, and a Guard marks the decisions made with it as Required,
+// so they are audited with their full context. Elevations are kept in
+// memory, they aren't shared with other instances or persisted.
+//
+// subject - the ID of the subject
+//
+// role - the name of the role to give, it must be a role of the Store
+//
+// d - how long the elevation lasts
+//
+// reason - why access is needed, e.g. an incident ticket
+//
+// returns - the elevation and ErrInvalidElevation if it can't be issued
+func (s *Store) Elevate(subject, role string, d time.Duration, reason string) (Elevation, error) {
+	switch {
+	case reason == "":
+		return Elevation{}, fmt.Errorf("%w: a reason is required", ErrInvalidElevation)
+	case d <= 0:
+		return Elevation{}, fmt.Errorf("%w: duration %s isn't positive", ErrInvalidElevation, d)
+	}
+	if _, ok := s.Role(role); !ok {
+		return Elevation{}, fmt.Errorf("%w: unknown role %q", ErrInvalidElevation, role)
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return Elevation{}, err
+	}
+	now := Now()
+	e := Elevation{Token: hex.EncodeToString(b), Subject: subject, Role: role, Reason: reason, Issued: now, Expires: now.Add(d)}
+
+	s.updateElevations(func(m map[string][]Elevation) {
+		m[subject] = append(m[subject], e)
+	})
+	if s.OnElevate != nil {
+		s.OnElevate(e)
+	}
+
+	return e, nil
+}
+
+// RevokeElevation ends an elevation before it expires.
+//
+// token - the token of the elevation
+//
+// returns - false if there is no such active elevation
+func (s *Store) RevokeElevation(token string) bool {
+	var found bool
+	s.updateElevations(func(m map[string][]Elevation) {
+		for subject, elevations := range m {
+			for i, e := range elevations {
+				if e.Token == token {
+					m[subject] = append(elevations[:i:i], elevations[i+1:]...)
+					found = true
+					return
+				}
+			}
+		}
+	})
+
+	return found
+}
+
+// Elevations returns the active elevations of a subject.
+func (s *Store) Elevations(subject string) []Elevation {
+	m := s.elevations.Load()
+	if m == nil {
+		return nil
+	}
+
+	var active []Elevation
+	now := Now()
+	for _, e := range (*m)[subject] {
+		if e.Active(now) {
+			active = append(active, e)
+		}
+	}
+
+	return active
+}
+
+// updateElevations applies fn to a copy of the elevations without the
+// expired ones and publishes the result.
+func (s *Store) updateElevations(fn func(m map[string][]Elevation)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next := make(map[string][]Elevation)
+	now := Now()
+	if m := s.elevations.Load(); m != nil {
+		for subject, elevations := range *m {
+			for _, e := range elevations {
+				if e.Active(now) {
+					next[subject] = append(next[subject], e)
+				}
+			}
+		}
+	}
+
+	fn(next)
+	for subject, elevations := range next {
+		if len(elevations) == 0 {
+			delete(next, subject)
+		}
+	}
+	s.elevations.Store(&next)
+}
@@ -0,0 +1,97 @@
+package can
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestElevate(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	defer func(n func() time.Time) { Now = n }(Now)
+	Now = func() time.Time { return now }
+
+	var issued []Elevation
+	s := NewStore(Roles{
+		"user":    NewRole().Allow("users", Read).Build(),
+		"on-call": NewRole().Allow("databases", All).Build(),
+	})
+	s.OnElevate = func(e Elevation) { issued = append(issued, e) }
+	sub := Subject{ID: "u1", Roles: []string{"user"}}
+
+	for _, tc := range []struct {
+		role, reason string
+		d            time.Duration
+	}{{"on-call", "", time.Hour}, {"on-call", "INC-1", 0}, {"root", "INC-1", time.Hour}} {
+		if _, err := s.Elevate("u1", tc.role, tc.d, tc.reason); !errors.Is(err, ErrInvalidElevation) {
+			t.Fatalf("expected %+v to be invalid, got %v", tc, err)
+		}
+	}
+
+	if role, _ := s.SubjectRole(sub); Can(context.Background(), role, "databases", Delete, nil) {
+		t.Fatal("expected no access before the elevation")
+	}
+	e, err := s.Elevate("u1", "on-call", time.Hour, "INC-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issued) != 1 || issued[0].Token != e.Token || e.Token == "" || !e.Expires.Equal(now.Add(time.Hour)) {
+		t.Fatalf("expected the elevation to be reported, got %+v", issued)
+	}
+	if role, _ := s.SubjectRole(sub); !Can(context.Background(), role, "databases", Delete, nil) || !Can(context.Background(), role, "users", Read, func() bool { return true }) {
+		t.Fatalf("expected the elevated role to be merged, got %v", role)
+	}
+
+	var audited []Decision
+	g := &Guard{Store: s, Audit: func(ctx context.Context, d Decision) error { audited = append(audited, d); return nil }}
+	r := httptest.NewRequest(http.MethodDelete, "/databases", nil).WithContext(WithSubject(context.Background(), sub))
+	if !g.Check(httptest.NewRecorder(), r, "databases", Delete) {
+		t.Fatal("expected the guard to allow the elevated subject")
+	}
+	if d := audited[0]; !d.Required || len(d.Elevations) != 1 || d.Elevations[0].Reason != "INC-1" {
+		t.Fatalf("expected an audited elevated decision, got %+v", d)
+	}
+
+	now = now.Add(time.Hour)
+	if role, _ := s.SubjectRole(sub); Can(context.Background(), role, "databases", Delete, nil) {
+		t.Fatal("expected the elevation to expire")
+	}
+
+	now = now.Add(-time.Minute)
+	if !s.RevokeElevation(e.Token) || s.RevokeElevation(e.Token) {
+		t.Fatal("expected the elevation to be revoked once")
+	}
+	if len(s.Elevations("u1")) != 0 {
+		t.Fatalf("expected no elevation left, got %+v", s.Elevations("u1"))
+	}
+}
+
+func TestElevateConflict(t *testing.T) {
+	s := NewStore(Roles{
+		"user": NewRole().Allow("users", Read).Build(),
+		"on-call": Role{
+			"databases": Permission{Abilities: map[Ability]struct{}{All: {}}},
+			"users":     Permission{Abilities: map[Ability]struct{}{All: {}}, Networks: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}},
+		},
+	})
+	var conflicts []error
+	s.OnRoleConflict = func(sub Subject, err error) { conflicts = append(conflicts, err) }
+
+	sub := Subject{ID: "u1", Roles: []string{"user"}}
+	if _, err := s.Elevate("u1", "on-call", time.Hour, "INC-2"); err != nil {
+		t.Fatal(err)
+	}
+
+	role, ok := s.SubjectRole(sub)
+	yes := func() bool { return true }
+	if !ok || !Can(context.Background(), role, "users", Read, yes) || !Can(context.Background(), role, "databases", Delete, yes) {
+		t.Fatalf("expected the conflicting elevation to keep the subject's access, got %v", role)
+	}
+	if len(conflicts) != 1 || !errors.Is(conflicts[0], ErrMergeConflict) {
+		t.Fatalf("expected the conflict to be reported, got %v", conflicts)
+	}
+}
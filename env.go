@@ -0,0 +1,81 @@
+package can
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrUndefinedVariable is returned when a role file references an
+// environment variable that is not set.
+var ErrUndefinedVariable = errors.New("can: undefined variable")
+
+// LookupEnv resolves the ${VAR} placeholders in role files. It defaults
+// to os.LookupEnv and can be replaced, e.g. to read from a secret store
+// or in tests.
+var LookupEnv = os.LookupEnv
+
+// expandNode replaces ${VAR} placeholders in every scalar of n, keys
+// included, so resource names, routes and include paths can differ
+// between deployments. $$ is an escaped $.
+func expandNode(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		v, err := expand(n.Value)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", n.Line, err)
+		}
+		n.Value = v
+		return nil
+	}
+
+	for _, c := range n.Content {
+		if err := expandNode(c); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// expand replaces the ${VAR} placeholders in s.
+func expand(s string) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		switch s[i+1] {
+		case '$':
+			b.WriteByte('$')
+			i++
+		case '{':
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("can: unterminated variable in %q", s)
+			}
+			name := s[i+2 : i+end]
+			if name == "" {
+				return "", fmt.Errorf("can: empty variable in %q", s)
+			}
+			v, ok := LookupEnv(name)
+			if !ok {
+				return "", fmt.Errorf("%w: %s", ErrUndefinedVariable, name)
+			}
+			b.WriteString(v)
+			i += end
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+
+	return b.String(), nil
+}
@@ -0,0 +1,45 @@
+package can
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	defer func(f func(string) (string, bool)) { LookupEnv = f }(LookupEnv)
+	env := map[string]string{"TENANT": "acme", "API_PREFIX": "api"}
+	LookupEnv = func(k string) (string, bool) {
+		v, ok := env[k]
+		return v, ok
+	}
+
+	r, err := OpenFile("testdata/env/rbac.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	role, ok := r["acme_admin"]
+	if !ok {
+		t.Fatalf("expected role names to be expanded, got %v", r)
+	}
+	if !Can(context.Background(), role, "acme_books", Delete, nil) {
+		t.Fatal("expected resource names to be expanded")
+	}
+	if !Can(context.Background(), role, "acme_books_api_shelves", Read, nil) {
+		t.Fatal("expected routes to be expanded")
+	}
+	if got := role["price"].Fields[Read]; len(got) != 1 || got[0] != "cost_$" {
+		t.Fatalf("expected $$ to be unescaped, got %v", got)
+	}
+
+	delete(env, "API_PREFIX")
+	if _, err := OpenFile("testdata/env/rbac.yml"); !errors.Is(err, ErrUndefinedVariable) {
+		t.Fatalf("expected ErrUndefinedVariable, got %v", err)
+	}
+
+	if _, err := Decode(strings.NewReader("admin:\n  ${TENANT:\n    abilities: [read]\n")); err == nil {
+		t.Fatal("expected an unterminated variable to fail")
+	}
+}
@@ -0,0 +1,68 @@
+package can
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// ErrorWriter writes the response of a request denied by a Guard, for
+// example a redirect to a login page or a structured error.
+type ErrorWriter func(w http.ResponseWriter, r *http.Request, d Decision)
+
+// Status returns the status code of a denial: 401 for unauthenticated
+// requests, 503 for undecided ones and 403 otherwise.
+func (d Decision) Status() int {
+	switch {
+	case !d.Authenticated:
+		return http.StatusUnauthorized
+	case d.Undecided:
+		return http.StatusServiceUnavailable
+	}
+
+	return http.StatusForbidden
+}
+
+// WriteError is the default ErrorWriter, it writes the status text of
+// the denial.
+func WriteError(w http.ResponseWriter, r *http.Request, d Decision) {
+	status := d.Status()
+	http.Error(w, http.StatusText(status), status)
+}
+
+// Problem is an RFC 7807 problem detail of a denial.
+type Problem struct {
+	Type       string `json:"type"`
+	Title      string `json:"title"`
+	Status     int    `json:"status"`
+	Detail     string `json:"detail,omitempty"`
+	Instance   string `json:"instance,omitempty"`
+	Permission string `json:"permission,omitempty"`
+	Ability    string `json:"ability,omitempty"`
+}
+
+// ProblemJSON is an ErrorWriter answering denials with an RFC 7807
+// application/problem+json document. Forbidden requests include the
+// permission and ability they were missing, which tells clients what to
+// ask for but also reveals the names of permissions.
+func ProblemJSON(w http.ResponseWriter, r *http.Request, d Decision) {
+	status := d.Status()
+	p := Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Instance: d.Path,
+	}
+	switch status {
+	case http.StatusServiceUnavailable:
+		p.Detail = "the request could not be authorized in time"
+	case http.StatusForbidden:
+		p.Detail = fmt.Sprintf("missing the %s ability on %s", d.Ability, d.Permission)
+		p.Permission, p.Ability = d.Permission, d.Ability.String()
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(p)
+}
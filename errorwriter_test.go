@@ -0,0 +1,47 @@
+package can
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestErrorWriter(t *testing.T) {
+	g := &Guard{ErrorWriter: ProblemJSON}
+	role := NewRole().Allow("users", Read).Build()
+
+	req := httptest.NewRequest(http.MethodDelete, "/users/42", nil)
+	w := httptest.NewRecorder()
+	if g.Check(w, req.WithContext(WithRole(req.Context(), role)), "users", Delete) {
+		t.Fatal("expected delete to be denied")
+	}
+
+	var p Problem
+	if err := json.NewDecoder(w.Body).Decode(&p); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusForbidden || w.Header().Get("Content-Type") != "application/problem+json" ||
+		p.Status != http.StatusForbidden || p.Permission != "users" || p.Ability != "delete" || p.Instance != "/users/42" {
+		t.Fatalf("unexpected problem %d %+v", w.Code, p)
+	}
+
+	w = httptest.NewRecorder()
+	g.Check(w, req, "users", Read)
+	p = Problem{}
+	if err := json.NewDecoder(w.Body).Decode(&p); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusUnauthorized || p.Permission != "" {
+		t.Fatalf("expected anonymous denials not to reveal the permission, got %d %+v", w.Code, p)
+	}
+
+	g.ErrorWriter = func(w http.ResponseWriter, r *http.Request, d Decision) {
+		http.Redirect(w, r, "/login", http.StatusFound)
+	}
+	w = httptest.NewRecorder()
+	g.Check(w, req, "users", Read)
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/login" {
+		t.Fatalf("expected a redirect, got %d", w.Code)
+	}
+}
@@ -0,0 +1,131 @@
+package can
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Etcd is a KV backed by the etcd v3 JSON gateway. Blocking reads use
+// the watch API, so Watch is notified as soon as the key changes.
+type Etcd struct {
+	// Endpoint is the base URL of an etcd member, e.g. http://127.0.0.1:2379
+	Endpoint string
+	// Client is the http client used for requests. Defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+type etcdKV struct {
+	Value       []byte `json:"value"`
+	ModRevision uint64 `json:"mod_revision,string"`
+}
+
+type etcdRangeResponse struct {
+	Kvs []etcdKV `json:"kvs"`
+}
+
+type etcdWatchResponse struct {
+	Result struct {
+		Events []struct {
+			Type string `json:"type"`
+			Kv   etcdKV `json:"kv"`
+		} `json:"events"`
+	} `json:"result"`
+}
+
+// Get implements the KV interface.
+func (e *Etcd) Get(ctx context.Context, key string, index uint64) ([]byte, uint64, error) {
+	if index == 0 {
+		return e.get(ctx, key)
+	}
+
+	return e.watch(ctx, key, index)
+}
+
+// Put implements the KVWriter interface.
+func (e *Etcd) Put(ctx context.Context, key string, value []byte) error {
+	resp, err := e.post(ctx, "/v3/kv/put", map[string]any{"key": []byte(key), "value": value})
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+// get reads the current value of key with a range request.
+func (e *Etcd) get(ctx context.Context, key string) ([]byte, uint64, error) {
+	resp, err := e.post(ctx, "/v3/kv/range", map[string]any{"key": []byte(key)})
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	var r etcdRangeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return nil, 0, err
+	}
+	if len(r.Kvs) == 0 {
+		return nil, 0, ErrKeyNotFound
+	}
+
+	return r.Kvs[0].Value, r.Kvs[0].ModRevision, nil
+}
+
+// watch blocks until key is modified after revision index.
+func (e *Etcd) watch(ctx context.Context, key string, index uint64) ([]byte, uint64, error) {
+	resp, err := e.post(ctx, "/v3/watch", map[string]any{
+		"create_request": map[string]any{
+			"key":            []byte(key),
+			"start_revision": fmt.Sprint(index + 1),
+		},
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var w etcdWatchResponse
+		if err := dec.Decode(&w); err != nil {
+			return nil, 0, err
+		}
+
+		for _, ev := range w.Result.Events {
+			if ev.Type == "DELETE" {
+				// the revision of the deletion, so the next watch sees the
+				// key created again
+				return nil, ev.Kv.ModRevision, ErrKeyNotFound
+			}
+			return ev.Kv.Value, ev.Kv.ModRevision, nil
+		}
+	}
+}
+
+// post sends a JSON request to the etcd gateway.
+func (e *Etcd) post(ctx context.Context, path string, body any) (*http.Response, error) {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.Endpoint+path, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient(e.Client).Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("can: etcd returned %s", resp.Status)
+	}
+
+	return resp, nil
+}
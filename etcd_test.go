@@ -0,0 +1,61 @@
+package can
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEtcd(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v3/kv/range":
+			fmt.Fprint(w, `{"header":{"revision":"3"},"kvs":[{"key":"cm9sZXM=","value":"YWRtaW46IHt9","mod_revision":"3"}]}`)
+		case "/v3/kv/put":
+			fmt.Fprint(w, `{"header":{"revision":"5"}}`)
+		case "/v3/watch":
+			fmt.Fprintln(w, `{"result":{"header":{"revision":"3"},"created":true}}`)
+			fmt.Fprintln(w, `{"result":{"events":[{"kv":{"key":"cm9sZXM=","value":"dXNlcjoge30=","mod_revision":"4"}}]}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	e := &Etcd{Endpoint: srv.URL}
+	v, index, err := e.Get(context.Background(), "roles", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(v) != "admin: {}" || index != 3 {
+		t.Fatalf("unexpected value %q at revision %d", v, index)
+	}
+
+	v, index, err = e.Get(context.Background(), "roles", index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(v) != "user: {}" || index != 4 {
+		t.Fatalf("unexpected watched value %q at revision %d", v, index)
+	}
+
+	if err := e.Put(context.Background(), "roles", []byte("admin: {}")); err != nil {
+		t.Fatal(err)
+	}
+
+	deleted := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"result":{"events":[{"type":"DELETE","kv":{"key":"cm9sZXM=","mod_revision":"9"}}]}}`)
+	}))
+	defer deleted.Close()
+	if _, index, err := (&Etcd{Endpoint: deleted.URL}).Get(context.Background(), "roles", 4); !errors.Is(err, ErrKeyNotFound) || index != 9 {
+		t.Fatalf("expected the deletion at revision 9, got %d %v", index, err)
+	}
+
+	e.Endpoint = srv.URL + "/missing"
+	if _, _, err := e.Get(context.Background(), "roles", 0); err == nil || errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("expected gateway error, got %v", err)
+	}
+}
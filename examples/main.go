@@ -1,7 +1,153 @@
+// Command example is a sample service showing the recommended can wiring:
+// roles loaded into a Store, a Guard middleware on every resource route
+// and admin endpoints to change grants at runtime.
 package main
 
-import "fmt"
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/acmacalister/can"
+	"github.com/acmacalister/can/canchi"
+	"github.com/go-chi/chi/v5"
+)
+
+// roleHeader carries the role of the caller. A real service resolves the
+// role from its authentication layer (session, JWT claims, ...) instead.
+const roleHeader = "X-Role"
 
 func main() {
-	fmt.Println("TODO!!!")
+	store, err := newStore()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	guard := &can.Guard{
+		Permission: canchi.PermissionFromPath,
+		Revision:   store.Revision,
+		Audit: func(ctx context.Context, d can.Decision) error {
+			log.Printf("%s %s %s/%s allowed=%v", d.Method, d.Path, d.Permission, d.Ability, d.Allowed)
+			return nil
+		},
+	}
+
+	r := chi.NewRouter()
+	r.Use(authenticate(store))
+
+	// Middlewares in a group run after routing, so the guard sees the url
+	// params and derives users instead of users_42 for /users/42.
+	r.Group(func(r chi.Router) {
+		r.Use(guard.Middleware)
+		r.Get("/", index)
+		r.Get("/users", listUsers)
+		r.Post("/users", createUser)
+		r.Get("/users/{id}", getUser)
+		r.Get("/books/search", searchBooks)
+	})
+
+	r.Route("/admin", func(r chi.Router) {
+		r.Get("/roles", listRoles(store))
+		r.Post("/grants", changeGrant(store, true))
+		r.Delete("/grants", changeGrant(store, false))
+	})
+
+	addr := envOr("ADDR", ":8080")
+	log.Printf("listening on %s", addr)
+	log.Fatal(http.ListenAndServe(addr, r))
+}
+
+// newStore loads the roles the service is authorized against.
+func newStore() (*can.Store, error) {
+	roles, err := can.OpenFile(envOr("POLICY", "policy.yml"))
+	if err != nil {
+		return nil, err
+	}
+
+	return can.NewStore(roles), nil
+}
+
+// authenticate stores the caller's role in the request context.
+func authenticate(store *can.Store) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if role, ok := store.Role(r.Header.Get(roleHeader)); ok {
+				r = r.WithContext(can.WithRole(r.Context(), role))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func index(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]string{"status": "ok"})
+}
+
+func listUsers(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, []map[string]string{{"id": "1", "name": "ada"}})
+}
+
+func createUser(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusCreated)
+}
+
+func getUser(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]string{"id": chi.URLParam(r, "id"), "name": "ada"})
+}
+
+func searchBooks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, []map[string]string{{"title": "The Go Programming Language"}})
+}
+
+// listRoles returns the loaded roles. It is guarded by can itself: only
+// roles with read on the admin permission may call it.
+func listRoles(store *can.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !can.Check(w, r, "admin", can.Read) {
+			return
+		}
+		writeJSON(w, store.Roles().Disk())
+	}
+}
+
+// changeGrant grants or revokes an ability, for example
+// POST /admin/grants?role=user&permission=books&ability=create
+func changeGrant(store *can.Store, grant bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !can.Check(w, r, "admin", can.Update) {
+			return
+		}
+
+		q := r.URL.Query()
+		ability := can.StringToAbility(q.Get("ability"))
+		if ability == can.None {
+			http.Error(w, "unknown ability", http.StatusBadRequest)
+			return
+		}
+
+		change := store.Revoke
+		if grant {
+			change = store.Grant
+		}
+		if err := change(r.Context(), q.Get("role"), q.Get("permission"), ability); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+
+	return def
 }
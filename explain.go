@@ -0,0 +1,62 @@
+package can
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Explanation describes how Can reached a decision.
+type Explanation struct {
+	Allowed bool `json:"allowed"`
+	// Permission is the role's permission the check matched, which may be
+	// a parent or wildcard of the checked permission, or "" if none did.
+	Permission string `json:"permission,omitempty"`
+	// Steps lists the evaluation steps in order.
+	Steps []string `json:"steps"`
+	// Comparators lists the conditions evaluated and the version of their
+	// comparator.
+	Comparators []ComparatorResult `json:"comparators,omitempty"`
+}
+
+// ComparatorResult is the outcome of a condition in an Explanation.
+type ComparatorResult struct {
+	Name       string `json:"name"`
+	Version    uint64 `json:"version"`
+	Registered bool   `json:"registered"`
+	Allowed    bool   `json:"allowed"`
+}
+
+// String formats the explanation as a trace, one step per line.
+func (e Explanation) String() string {
+	var b strings.Builder
+	for _, s := range e.Steps {
+		fmt.Fprintf(&b, "- %s\n", s)
+	}
+	if e.Allowed {
+		b.WriteString("allowed\n")
+	} else {
+		b.WriteString("denied\n")
+	}
+
+	return b.String()
+}
+
+// Explain is Can with a trace of how the decision was made, for
+// debugging policies and reviewing changes. Quotas are read, an
+// explanation doesn't use them.
+func Explain(ctx context.Context, role Role, permission string, ability Ability, compare func() bool) Explanation {
+	var e Explanation
+	e.Allowed = evaluate(ctx, role, permission, ability, compare, &e).Allow()
+
+	return e
+}
+
+// step records a step if trace is not nil. Checks without an
+// Explanation test for nil before calling it with arguments, so Can
+// doesn't box them.
+func (e *Explanation) step(format string, args ...any) {
+	if e != nil {
+		e.Steps = append(e.Steps, fmt.Sprintf(format, args...))
+	}
+}
@@ -0,0 +1,39 @@
+package can
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	r, err := OpenFile("testdata/rbac.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	e := Explain(ctx, r["user"], "users", Update, Compare(1, 1))
+	if !e.Allowed || e.Permission != "users" || !strings.Contains(e.String(), "compare returned true") {
+		t.Fatalf("unexpected explanation %+v", e)
+	}
+
+	e = Explain(ctx, r["user"], "users", Delete, Compare(1, 1))
+	if e.Allowed || !strings.Contains(e.String(), "delete is not granted") {
+		t.Fatalf("unexpected explanation %+v", e)
+	}
+
+	e = Explain(ctx, r["user"], "nope", Read, nil)
+	if e.Allowed || e.Permission != "" || !strings.Contains(e.String(), "not listed") {
+		t.Fatalf("unexpected explanation %+v", e)
+	}
+
+	for _, tt := range []struct {
+		permission string
+		ability    Ability
+	}{{"users", Read}, {"users", Delete}, {"books", Read}, {"admin", All}, {"", Read}} {
+		if got := Explain(ctx, r["user"], tt.permission, tt.ability, Compare(1, 1)).Allowed; got != Can(ctx, r["user"], tt.permission, tt.ability, Compare(1, 1)) {
+			t.Errorf("%s %s: expected Explain to agree with Can", tt.permission, tt.ability)
+		}
+	}
+}
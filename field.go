@@ -0,0 +1,104 @@
+package can
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// CanField checks whether role may use ability on a single field of a
+// permission's resource. The check must pass every step of Can, and when
+// the permission restricts the ability to a set of fields, field must be
+// one of them. Fields listed for All apply to every ability. Ownership
+// isn't checked, combine it with Can for that, and quotas are only read:
+// a quota that is used up denies the fields, checking them doesn't use
+// it.
+//
+// ctx - a standard ctx
+//
+// role - a role structure that contains the role and permissions to check authorization on.
+//
+// permission - defines the permission to check of a given object.
+//
+// ability - defines the ability to check of a given object.
+//
+// field - the name of the field
+//
+// returns a true or false if the field is allowed.
+func CanField(ctx context.Context, role Role, permission string, ability Ability, field string) bool {
+	perm, o := evaluatePermission(ctx, role, permission, ability, func() bool { return true }, nil, true)
+	if !o.Allow() {
+		return false
+	}
+
+	fields, restricted := perm.Fields[ability]
+	allFields, restrictedAll := perm.Fields[All]
+	if !restricted && !restrictedAll {
+		return true
+	}
+
+	for _, f := range append(fields, allFields...) {
+		if f == field {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FilterFields returns the fields of v that role may use ability on,
+// useful to strip a response down to what the caller may read. v is
+// either a map with string keys or a struct (or pointer to one), whose
+// fields are named after their json tag like encoding/json does.
+//
+// returns - the permitted fields and their values, or an error if v
+// isn't a map or struct
+func FilterFields(ctx context.Context, role Role, permission string, ability Ability, v any) (map[string]any, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil, fmt.Errorf("can: cannot filter fields of nil %T", v)
+		}
+		rv = rv.Elem()
+	}
+
+	out := make(map[string]any)
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("can: cannot filter fields of %T", v)
+		}
+		iter := rv.MapRange()
+		for iter.Next() {
+			name := iter.Key().String()
+			if CanField(ctx, role, permission, ability, name) {
+				out[name] = iter.Value().Interface()
+			}
+		}
+	case reflect.Struct:
+		t := rv.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if !sf.IsExported() {
+				continue
+			}
+			name := sf.Name
+			if tag, ok := sf.Tag.Lookup("json"); ok {
+				if tag == "-" {
+					continue
+				}
+				if n, _, _ := strings.Cut(tag, ","); n != "" {
+					name = n
+				}
+			}
+			if CanField(ctx, role, permission, ability, name) {
+				out[name] = rv.Field(i).Interface()
+			}
+		}
+	default:
+		return nil, fmt.Errorf("can: cannot filter fields of %T", v)
+	}
+
+	return out, nil
+}
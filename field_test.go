@@ -0,0 +1,98 @@
+package can
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestCanField(t *testing.T) {
+	r, err := OpenFile("testdata/rbac.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if !CanField(ctx, r["user"], "users", Update, "avatar") {
+		t.Fatal("expected user to update avatar")
+	}
+	if CanField(ctx, r["user"], "users", Update, "email") {
+		t.Fatal("expected user not to update email")
+	}
+	if !CanField(ctx, r["user"], "users", Read, "email") {
+		t.Fatal("expected unrestricted read")
+	}
+	if CanField(ctx, r["user"], "users", Delete, "avatar") {
+		t.Fatal("expected user not to delete")
+	}
+	if !CanField(ctx, r["admin"], "users", Update, "email") {
+		t.Fatal("expected admin to update any field")
+	}
+}
+
+func TestCanFieldRestrictions(t *testing.T) {
+	defer func(c QuotaCounter) { DefaultQuotaCounter = c }(DefaultQuotaCounter)
+	DefaultQuotaCounter = &MemoryCounter{}
+
+	role := Role{
+		"users":   Permission{Abilities: map[Ability]struct{}{Read: {}}, Networks: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}},
+		"exports": Permission{Abilities: map[Ability]struct{}{Read: {}}, Quotas: map[Ability]Quota{Read: {Limit: 1, Per: time.Hour}}},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	if CanField(WithRequest(context.Background(), req), role, "users", Read, "email") {
+		t.Fatal("expected the cidr to restrict fields")
+	}
+	req.RemoteAddr = "10.1.2.3:1234"
+	if !CanField(WithRequest(context.Background(), req), role, "users", Read, "email") {
+		t.Fatal("expected fields from the network to be allowed")
+	}
+
+	ctx := context.Background()
+	if !CanField(ctx, role, "exports", Read, "name") || !CanField(ctx, role, "exports", Read, "size") {
+		t.Fatal("expected field checks not to use the quota")
+	}
+	if !Can(ctx, role, "exports", Read, func() bool { return true }) || CanField(ctx, role, "exports", Read, "name") {
+		t.Fatal("expected a used up quota to deny fields")
+	}
+}
+
+func TestFilterFields(t *testing.T) {
+	r, err := OpenFile("testdata/rbac.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	type user struct {
+		ID          int64  `json:"id"`
+		DisplayName string `json:"display_name"`
+		Avatar      string `json:"avatar,omitempty"`
+		Password    string `json:"-"`
+		internal    bool
+	}
+
+	got, err := FilterFields(ctx, r["user"], "users", Update, &user{ID: 1, DisplayName: "ada", Avatar: "a.png", Password: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got["display_name"] != "ada" || got["avatar"] != "a.png" {
+		t.Fatalf("unexpected fields %v", got)
+	}
+
+	got, err = FilterFields(ctx, r["user"], "users", Update, map[string]any{"email": "a@b.c", "avatar": "b.png"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got["avatar"] != "b.png" {
+		t.Fatalf("unexpected fields %v", got)
+	}
+
+	if _, err := FilterFields(ctx, r["user"], "users", Update, 42); err == nil {
+		t.Fatal("expected error for unsupported value")
+	}
+}
@@ -0,0 +1,38 @@
+package can
+
+import "context"
+
+// Filter returns the items of a collection the role can access, for
+// listings such as "the projects I can read". keyFn returns the
+// permission of an item, usually a hierarchical key such as projects/42
+// falling back to projects (see HierarchySeparator). Like CanMessage
+// there is no ownership check, abilities held on the key are allowed;
+// use CanBatch with a compare function per item otherwise. Filtering
+// reads quotas without using them, as CanBatch does.
+//
+// ctx - a standard ctx, see Can
+//
+// role - the role to check
+//
+// ability - the ability to check on every item
+//
+// items - the collection to filter, left unchanged
+//
+// keyFn - returns the permission of an item
+//
+// returns - the allowed items, in order
+func Filter[T any](ctx context.Context, role Role, ability Ability, items []T, keyFn func(T) string) []T {
+	checks := make([]BatchCheck, len(items))
+	for i, item := range items {
+		checks[i] = BatchCheck{Permission: keyFn(item), Ability: ability, Compare: func() bool { return true }}
+	}
+
+	var allowed []T
+	for i, d := range CanBatch(ctx, role, checks) {
+		if d.Allowed {
+			allowed = append(allowed, items[i])
+		}
+	}
+
+	return allowed
+}
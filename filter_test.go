@@ -0,0 +1,51 @@
+package can
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFilter(t *testing.T) {
+	role := NewRole().Allow("projects/1", Read).Allow("projects/2", Read, Update).Deny("projects/3", Read).Build()
+
+	type project struct{ ID string }
+	projects := []project{{"1"}, {"2"}, {"3"}, {"4"}}
+	key := func(p project) string { return "projects/" + p.ID }
+
+	if got := Filter(context.Background(), role, Read, projects, key); !reflect.DeepEqual(got, []project{{"1"}, {"2"}}) {
+		t.Fatalf("unexpected readable projects %v", got)
+	}
+	if got := Filter(context.Background(), role, Update, projects, key); !reflect.DeepEqual(got, []project{{"2"}}) {
+		t.Fatalf("unexpected updatable projects %v", got)
+	}
+	if got := Filter(context.Background(), role, Read, nil, key); len(got) != 0 {
+		t.Fatalf("expected no projects, got %v", got)
+	}
+}
+
+func TestFilterQuota(t *testing.T) {
+	defer func(c QuotaCounter) { DefaultQuotaCounter = c }(DefaultQuotaCounter)
+	DefaultQuotaCounter = &MemoryCounter{}
+
+	role := Role{"projects": {
+		Abilities: map[Ability]struct{}{Read: {}},
+		Quotas:    map[Ability]Quota{Read: {5, 24 * time.Hour}},
+	}}
+	ids := make([]string, 100)
+	for i := range ids {
+		ids[i] = fmt.Sprint("projects/", i)
+	}
+	ctx := WithSubject(context.Background(), Subject{ID: "u1"})
+	key := func(id string) string { return id }
+	for i := 0; i < 2; i++ {
+		if got := Filter(ctx, role, Read, ids, key); len(got) != len(ids) {
+			t.Fatalf("expected filter %d to keep every item, got %d", i+1, len(got))
+		}
+	}
+	if !Can(ctx, role, "projects", Read, func() bool { return true }) {
+		t.Fatal("expected filtering not to use the quota")
+	}
+}
@@ -1,10 +1,26 @@
 module github.com/acmacalister/can
 
-go 1.19
+go 1.23
 
-require golang.org/x/exp v0.0.0-20221012211006-4de253d81b95
+require (
+	github.com/go-chi/chi/v5 v5.0.12
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/BurntSushi/toml v1.4.0
+	github.com/hashicorp/hcl/v2 v2.21.0
+	golang.org/x/text v0.14.0
+)
 
 require (
-	github.com/go-chi/chi/v5 v5.0.12 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
+	github.com/agext/levenshtein v1.2.1 // indirect
+	github.com/apparentlymart/go-textseg/v13 v13.0.0 // indirect
+	github.com/apparentlymart/go-textseg/v15 v15.0.0 // indirect
+	github.com/google/go-cmp v0.6.0 // indirect
+	github.com/mitchellh/go-wordwrap v0.0.0-20150314170334-ad45545899c7 // indirect
+	github.com/zclconf/go-cty v1.13.0 // indirect
+	golang.org/x/mod v0.8.0 // indirect
+	golang.org/x/sys v0.5.0 // indirect
+	golang.org/x/tools v0.6.0 // indirect
 )
@@ -0,0 +1,93 @@
+package can
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// GroupsKey is the top level key of a role file mapping identity
+// provider groups, such as LDAP, Active Directory or Google groups, to
+// the roles their members get:
+//
+//	groups:
+//	  engineering@example.com: [user, support]
+//	  cn=admins,ou=groups,dc=example,dc=com: [admin]
+//
+// OpenFile and Decode skip the key, load it with OpenGroups. The key is
+// reserved and can't be used as a role name.
+const GroupsKey = "groups"
+
+// Groups maps group names to role names.
+type Groups map[string][]string
+
+// OpenGroups reads the groups of a yaml role file. The file's roles and
+// includes are ignored.
+// filename - yaml encoded file for parsing
+//
+// returns - the groups, empty if the file has none, and an error
+func OpenGroups(filename string) (Groups, error) {
+	f, err := os.OpenFile(filename, os.O_RDONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	g, err := DecodeGroups(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	return g, nil
+}
+
+// DecodeGroups reads the groups of yaml encoded roles from r.
+func DecodeGroups(r io.Reader) (Groups, error) {
+	g := make(Groups)
+	if err := decodeKey(r, GroupsKey, &g); err != nil {
+		return nil, err
+	}
+
+	return g, nil
+}
+
+// RoleNames returns the sorted, de-duplicated names of the roles the
+// given groups map to. Unknown groups are skipped.
+func (g Groups) RoleNames(groups []string) []string {
+	seen := make(map[string]struct{})
+	for _, group := range groups {
+		for _, name := range g[group] {
+			seen[name] = struct{}{}
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Subject returns a subject with the roles of its groups, ready to be
+// stored with WithSubject.
+func (g Groups) Subject(id string, groups []string) Subject {
+	return Subject{ID: id, Roles: g.RoleNames(groups)}
+}
+
+// Resolve returns the effective role of a member of the given groups:
+// the union of the roles they map to, in order. A permission the roles
+// define in contradicting ways keeps the definition of the first of them.
+//
+// roles - the roles the group mapping refers to
+//
+// groups - the groups of the subject, e.g. from its identity token
+//
+// returns - the role and true, or nil and false if no group maps to a
+// known role
+func (g Groups) Resolve(roles Roles, groups []string) (Role, bool) {
+	role, ok, _ := mergeNamed(roles, g.RoleNames(groups))
+	return role, ok
+}
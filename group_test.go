@@ -0,0 +1,44 @@
+package can
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestGroups(t *testing.T) {
+	r, err := OpenFile("testdata/groups.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := r[GroupsKey]; ok || len(r) != 3 {
+		t.Fatalf("expected groups to be skipped, got %v", r)
+	}
+
+	g, err := OpenGroups("testdata/groups.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := g.RoleNames([]string{"oncall@example.com", "engineering@example.com", "nope"}); !reflect.DeepEqual(got, []string{"support", "user"}) {
+		t.Fatalf("unexpected role names %v", got)
+	}
+
+	yes := func() bool { return true }
+	role, ok := g.Resolve(r, []string{"oncall@example.com"})
+	if !ok || !Can(context.Background(), role, "books", Read, yes) || !Can(context.Background(), role, "books", Update, yes) {
+		t.Fatalf("expected the roles of the group to be merged, got %v", role)
+	}
+	if Can(context.Background(), role, "books", Delete, yes) {
+		t.Fatal("expected delete to be denied")
+	}
+
+	if _, ok := g.Resolve(r, []string{"nope"}); ok {
+		t.Fatal("expected unknown groups to resolve to no role")
+	}
+
+	sub := g.Subject("u1", []string{"admins"})
+	if role, ok := NewStore(r).SubjectRole(sub); !ok || !Can(context.Background(), role, "books", Delete, yes) {
+		t.Fatal("expected the subject to get the admin role")
+	}
+}
@@ -0,0 +1,340 @@
+package can
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Decision records the outcome of an authorization check made by a Guard.
+type Decision struct {
+	Time       time.Time `json:"time"`
+	Subject    string    `json:"subject,omitempty"`
+	Tenant     string    `json:"tenant,omitempty"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Permission string    `json:"permission"`
+	Ability    Ability   `json:"ability"`
+	Allowed    bool      `json:"allowed"`
+	// Replay is set for a Create retried with the idempotency key of an
+	// earlier allowed Create (see Idempotency).
+	Replay bool `json:"replay,omitempty"`
+	// Required is set when the policy requires the check to be audited
+	// (see Permission.Audited) or the request is impersonated or elevated.
+	// Such decisions carry the labels and attributes of the check as well.
+	Required   bool       `json:"required,omitempty"`
+	Labels     Labels     `json:"labels,omitempty"`
+	Attributes Attributes `json:"attributes,omitempty"`
+	// Synthetic is set for checks made by a synthetic monitoring client
+	// (see SyntheticRole), which should be excluded from access analytics.
+	Synthetic bool `json:"synthetic,omitempty"`
+	// PolicyVersion is the version of the roles of the Guard's Store when
+	// the decision was made (see Store.Version), 0 without a Store.
+	PolicyVersion uint64 `json:"policy_version,omitempty"`
+	// Authenticated is set when the request had a role or subject of its
+	// own rather than the DefaultRole, a denial is then answered with 403
+	// rather than 401 (see Status).
+	Authenticated bool `json:"authenticated,omitempty"`
+	// Shadow is set for decisions of a Guard in shadow mode, which lets
+	// denied requests through (see Guard.Shadow).
+	Shadow bool `json:"shadow,omitempty"`
+	// Undecided is set when the request ctx was done before the check was
+	// decided, Allowed then follows OnUndecided (see Decide).
+	Undecided bool `json:"undecided,omitempty"`
+	// Impersonation is set for requests made by an actor with the role of
+	// someone else (see Impersonate).
+	Impersonation *Impersonation `json:"impersonation,omitempty"`
+	// Elevations are the active elevations of the subject whose role was
+	// resolved by the Store (see Store.Elevate).
+	Elevations []Elevation `json:"elevations,omitempty"`
+	// Roles are the role names of the subject of the request.
+	Roles []string `json:"roles,omitempty"`
+	// TraceID identifies the distributed trace of the request (see
+	// Guard.TraceID).
+	TraceID string `json:"trace_id,omitempty"`
+	// QuotaWarning is set for allowed checks past the QuotaWarning of
+	// the quota of their ability (see Permission.Quotas).
+	QuotaWarning *QuotaUsage `json:"quota_warning,omitempty"`
+}
+
+// Guard enforces authorization for HTTP handlers. It extracts the role
+// from the request context (see WithRole, or WithSubject together with
+// Store), asks the Authorizer for a
+// decision, writes the error response on denial and reports every
+// decision to Audit. The zero value is ready to use.
+type Guard struct {
+	// Authorizer makes the decisions. Defaults to Local. Any CanFn can be
+	// used, including a Chain of them.
+	Authorizer Authorizer
+	// Store resolves the roles of the subject in the request context when
+	// the context carries no role (see WithSubject), may be nil.
+	Store *Store
+	// DefaultRole is used for requests whose context carries no role, for
+	// example anonymous visitors. When nil such requests are denied. They
+	// aren't authenticated, so their denials are answered with 401.
+	DefaultRole Role
+	// Permission derives the permission from a request in Middleware and
+	// ServeMux. Defaults to PermissionFromPattern for requests routed by
+	// an http.ServeMux and PermissionFromPath otherwise.
+	Permission func(r *http.Request) string
+	// Compare builds the compare function for a request. When nil, checks
+	// are made at the route level and compare is always satisfied.
+	Compare func(r *http.Request) func() bool
+	// Audit is called with every decision, may be nil. Its error is
+	// ignored unless the policy requires the check to be audited, in
+	// which case the request is denied, as it is when Audit is nil.
+	Audit func(ctx context.Context, d Decision) error
+	// Labels are added to every check, for example the environment the
+	// service runs in. Labels already in the request context take precedence.
+	Labels Labels
+	// Backoff slows down subjects repeatedly denied the same permission, may be nil.
+	Backoff *Backoff
+	// Idempotency marks retried Create requests as replays, may be nil.
+	Idempotency *Idempotency
+	// ErrorWriter writes the response of denied requests. Defaults to
+	// WriteError, see ProblemJSON for RFC 7807 responses.
+	ErrorWriter ErrorWriter
+	// Shadow evaluates and audits every check but lets denied requests
+	// through, to find missing grants on an existing API before enforcing.
+	// The would-be denials are reported to Audit with Allowed unset and
+	// Shadow set.
+	Shadow bool
+	// Revision returns the policy revision in effect, such as Store.Revision.
+	// When set, it is stamped on every response in the RevisionHeader header
+	// so client error reports can be matched with the exact policy.
+	Revision func() string
+	// TraceID returns the trace ID recorded in decisions. Defaults to
+	// TraceIDFromHeader.
+	TraceID func(r *http.Request) string
+}
+
+// RevisionHeader is the response header carrying the policy revision.
+const RevisionHeader = "Can-Policy-Revision"
+
+// DefaultGuard is the Guard used by Check.
+var DefaultGuard = &Guard{}
+
+// Check authorizes the request with DefaultGuard. It is meant for
+// handlers that don't use a middleware yet:
+//
+//	if !can.Check(w, r, "users", can.Update) {
+//		return
+//	}
+//
+// w - the response writer, written to when the request is denied
+//
+// r - the request, its context must carry a role (see WithRole)
+//
+// permission - defines the permission to check
+//
+// ability - defines the ability to check
+//
+// returns - true if the request is allowed. On false the response has
+// already been written and the handler should return
+func Check(w http.ResponseWriter, r *http.Request, permission string, ability Ability) bool {
+	return DefaultGuard.Check(w, r, permission, ability)
+}
+
+// Check authorizes the request, see the package level Check function.
+func (g *Guard) Check(w http.ResponseWriter, r *http.Request, permission string, ability Ability) bool {
+	if g.Revision != nil {
+		w.Header().Set(RevisionHeader, g.Revision())
+	}
+
+	// a subject is authenticated even if none of its roles are known
+	// locally, the Authorizer may still know them
+	role, authenticated := g.role(r.Context())
+	if _, ok := SubjectFrom(r.Context()); ok {
+		authenticated = true
+	}
+	d := g.decide(r, role, authenticated, permission, ability)
+	if d.QuotaWarning != nil && d.Allowed {
+		w.Header().Set(QuotaWarningHeader, d.QuotaWarning.String())
+	}
+	if g.Shadow {
+		return true
+	}
+
+	if g.Backoff != nil && !d.Allowed {
+		if delay := g.Backoff.deny(r, permission, d.Time); delay > 0 {
+			g.Backoff.apply(w, r, delay)
+		}
+	}
+
+	if !d.Allowed {
+		if g.ErrorWriter != nil {
+			g.ErrorWriter(w, r, d)
+		} else {
+			WriteError(w, r, d)
+		}
+	}
+
+	return d.Allowed
+}
+
+// Middleware authorizes every request before calling next. The
+// permission is derived with Permission and the ability from the
+// request method (see BuildFromMethod).
+func (g *Guard) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !g.Check(w, r, g.permission(r), BuildFromMethod(r.Method)) {
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ServeMux returns a handler that authorizes every request against the
+// route of mux it matches before serving it. Unlike wrapping mux with
+// Middleware, the permission is derived from the matched pattern (see
+// PermissionFromPattern), so no path segment held by a wildcard ends up
+// in it. Requests mux doesn't route, such as 404s and redirects, are
+// served without a check.
+//
+// Path values are not set yet when Compare runs, use Middleware on the
+// handlers of mux if Compare needs r.PathValue.
+func (g *Guard) ServeMux(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, pattern := mux.Handler(r)
+		if pattern == "" {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		permission := patternPermission(pattern)
+		if g.Permission != nil {
+			r.Pattern = pattern
+			permission = g.Permission(r)
+		}
+		if !g.Check(w, r, permission, BuildFromMethod(r.Method)) {
+			return
+		}
+
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// role returns the role for a request context: the role stored in ctx,
+// the Store role of the subject stored in ctx or the DefaultRole. It
+// reports whether the request is authenticated, which it isn't when it
+// falls back to the DefaultRole.
+func (g *Guard) role(ctx context.Context) (Role, bool) {
+	if role, ok := RoleFrom(ctx); ok {
+		return role, true
+	}
+
+	if sub, ok := SubjectFrom(ctx); ok && g.Store != nil {
+		if role, ok := g.Store.SubjectRole(sub); ok {
+			return role, true
+		}
+	}
+
+	return g.DefaultRole, false
+}
+
+// permission returns the permission for r.
+func (g *Guard) permission(r *http.Request) string {
+	if g.Permission == nil {
+		if r.Pattern != "" {
+			return PermissionFromPattern(r)
+		}
+		return PermissionFromPath(r)
+	}
+
+	return g.Permission(r)
+}
+
+// decide makes and audits the decision for a request.
+func (g *Guard) decide(r *http.Request, role Role, authenticated bool, permission string, ability Ability) Decision {
+	d := Decision{
+		Time:          Now(),
+		Method:        r.Method,
+		Path:          r.URL.Path,
+		Permission:    permission,
+		Ability:       ability,
+		Shadow:        g.Shadow,
+		Authenticated: authenticated,
+	}
+	if g.TraceID != nil {
+		d.TraceID = g.TraceID(r)
+	} else {
+		d.TraceID = TraceIDFromHeader(r)
+	}
+	if sub, ok := SubjectFrom(r.Context()); ok {
+		d.Subject, d.Synthetic, d.Roles = sub.ID, sub.Synthetic(), sub.Roles
+		if _, ok := RoleFrom(r.Context()); !ok && g.Store != nil {
+			d.Elevations = g.Store.Elevations(sub.ID)
+		}
+	}
+	d.Tenant, _ = TenantFrom(r.Context())
+	if g.Store != nil {
+		d.PolicyVersion = g.Store.Version()
+	}
+
+	idempotent := g.Idempotency != nil && ability == Create
+	replay := idempotent && g.Idempotency.replay(r, permission, d.Time)
+
+	ctx := WithRequest(r.Context(), r)
+	if replay {
+		// the first create already used the quota
+		ctx = context.WithValue(ctx, replayKey{}, true)
+	}
+	if len(g.Labels) > 0 {
+		ctx = WithLabels(WithLabels(ctx, g.Labels), LabelsFrom(r.Context()))
+	}
+	var usage QuotaUsage
+	ctx = context.WithValue(ctx, quotaUsageKey{}, &usage)
+	if authenticated || role != nil {
+		outcome := Decide(ctx, g.authorizer(), role, permission, ability, g.compare(r))
+		d.Allowed, d.Undecided = outcome.Allow(), outcome == Undecided
+		if outcome == Allowed && usage.Quota.Limit > 0 {
+			d.QuotaWarning = &usage
+		}
+	}
+	if imp, ok := ImpersonationFrom(ctx); ok {
+		imp.Role = role
+		d.Impersonation = &imp
+	}
+	if p, ok := lookup(role, permission); (ok && p.RequiresAudit(ability)) || d.Impersonation != nil || len(d.Elevations) > 0 {
+		d.Required = true
+		d.Labels, d.Attributes = LabelsFrom(ctx), AttributesFrom(ctx)
+	}
+
+	d.Replay = replay && d.Allowed
+
+	switch {
+	case g.Audit != nil:
+		if err := g.Audit(r.Context(), d); err != nil && d.Required && d.Allowed {
+			// the decision was reported as allowed, report the denial too
+			d.Allowed = false
+			g.Audit(r.Context(), d)
+		}
+	case d.Required:
+		d.Allowed = false
+	}
+
+	if idempotent && d.Allowed {
+		g.Idempotency.record(r, permission, d.Time)
+	}
+
+	return d
+}
+
+// authorizer returns the configured Authorizer or Local.
+func (g *Guard) authorizer() Authorizer {
+	if g.Authorizer == nil {
+		return Local
+	}
+
+	return g.Authorizer
+}
+
+// compare returns the compare function for r.
+func (g *Guard) compare(r *http.Request) func() bool {
+	if g.Compare == nil {
+		return func() bool { return true }
+	}
+
+	return g.Compare(r)
+}
@@ -0,0 +1,273 @@
+package can
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	r, err := OpenFile("testdata/rbac.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decisions []Decision
+	defer func(g *Guard) { DefaultGuard = g }(DefaultGuard)
+	DefaultGuard = &Guard{Audit: func(ctx context.Context, d Decision) error {
+		decisions = append(decisions, d)
+		return nil
+	}}
+
+	tests := []struct {
+		name    string
+		role    Role
+		ability Ability
+		allowed bool
+		status  int
+	}{
+		{"user read", r["user"], Read, true, http.StatusOK},
+		{"user create", r["user"], Create, false, http.StatusForbidden},
+		{"no role", nil, Read, false, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/users", nil)
+			if tt.role != nil {
+				req = req.WithContext(WithRole(req.Context(), tt.role))
+			}
+			w := httptest.NewRecorder()
+
+			if Check(w, req, "users", tt.ability) != tt.allowed {
+				t.Fatalf("expected allowed to be %v", tt.allowed)
+			}
+			if w.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, w.Code)
+			}
+		})
+	}
+
+	if len(decisions) != len(tests) {
+		t.Fatalf("expected %d audited decisions, got %d", len(tests), len(decisions))
+	}
+	if d := decisions[1]; d.Permission != "users" || d.Ability != Create || d.Allowed {
+		t.Fatalf("unexpected decision %+v", d)
+	}
+}
+
+func TestGuardRevision(t *testing.T) {
+	s := NewStore(Roles{"user": Role{"users": Permission{Abilities: map[Ability]struct{}{Read: {}}}}})
+	g := &Guard{Revision: s.Revision}
+
+	role, _ := s.Role("user")
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req = req.WithContext(WithRole(req.Context(), role))
+	w := httptest.NewRecorder()
+
+	g.Check(w, req, "users", Delete)
+	if got := w.Header().Get(RevisionHeader); got == "" || got != s.Revision() {
+		t.Fatalf("expected revision header %q, got %q", s.Revision(), got)
+	}
+}
+
+func TestGuardMiddleware(t *testing.T) {
+	r, err := OpenFile("testdata/rbac.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var remoteCalls int
+	g := &Guard{Authorizer: Chain(DefaultCan, func(ctx context.Context, role *Role, compare func() bool, permission string, ability Ability) bool {
+		remoteCalls++
+		return true
+	})}
+
+	mux := http.NewServeMux()
+	// the guard runs after routing, so the mux has set the pattern
+	mux.Handle("GET /users/{id}", g.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+	mux.Handle("POST /users", g.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+	router := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mux.ServeHTTP(w, req.WithContext(WithRole(req.Context(), r["user"])))
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/42", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected user to read users, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/users", nil))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected user to be denied create, got %d", w.Code)
+	}
+
+	if remoteCalls != 1 {
+		t.Fatalf("expected the chain to reach the second fn once, got %d", remoteCalls)
+	}
+}
+
+func TestGuardServeMux(t *testing.T) {
+	r, err := OpenFile("testdata/rbac.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var id string
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) { id = r.PathValue("id") })
+	mux.HandleFunc("POST /users", func(w http.ResponseWriter, r *http.Request) {})
+
+	g := &Guard{DefaultRole: r["user"]}
+	h := g.ServeMux(mux)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/books", nil))
+	if w.Code != http.StatusOK || id != "books" {
+		t.Fatalf("expected user to read users, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/users", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected the anonymous user to be denied create, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/nope", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected unrouted requests to reach the mux, got %d", w.Code)
+	}
+
+	// wrapping a single handler works too, the mux sets the pattern
+	mux = http.NewServeMux()
+	mux.Handle("POST /users/{id}", g.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/users/42", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected the anonymous user to be denied create, got %d", w.Code)
+	}
+}
+
+func TestGuardDefaultRole(t *testing.T) {
+	r, err := OpenFile("testdata/rbac.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := &Guard{DefaultRole: r["user"]}
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+
+	w := httptest.NewRecorder()
+	if !g.Check(w, req, "users", Read) {
+		t.Fatalf("expected default role to read users, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	if g.Check(w, req, "users", Delete) || w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected the anonymous request to be unauthorized, got %d", w.Code)
+	}
+}
+
+func TestGuardSubject(t *testing.T) {
+	r, err := OpenFile("testdata/rbac.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var audited Decision
+	g := &Guard{Store: NewStore(r), Audit: func(ctx context.Context, d Decision) error { audited = d; return nil }}
+
+	req := httptest.NewRequest(http.MethodDelete, "/books", nil)
+	ctx := WithSubject(req.Context(), Subject{ID: "u1", Roles: []string{"user", "admin"}})
+	req = req.WithContext(WithTenant(ctx, "acme"))
+
+	if !g.Check(httptest.NewRecorder(), req, "books", Delete) {
+		t.Fatal("expected the merged roles of the subject to allow")
+	}
+	if audited.Subject != "u1" || audited.Tenant != "acme" {
+		t.Fatalf("expected subject and tenant to be audited, got %+v", audited)
+	}
+
+	req = req.WithContext(WithSubject(req.Context(), Subject{ID: "u2", Roles: []string{"user"}}))
+	if g.Check(httptest.NewRecorder(), req, "books", Delete) {
+		t.Fatal("expected user subject to be denied")
+	}
+}
+
+func TestGuardRequiredAudit(t *testing.T) {
+	r, err := OpenFile("testdata/audit.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, "/payments", nil)
+	req = req.WithContext(WithAttributes(WithRole(req.Context(), r["finance"]), Attributes{"ip": "10.0.0.1"}))
+
+	g := &Guard{Labels: Labels{EnvironmentLabel: "production"}}
+	if g.Check(httptest.NewRecorder(), req, "payments", Delete) {
+		t.Fatal("expected a required audit without a sink to deny")
+	}
+	if !g.Check(httptest.NewRecorder(), req, "payments", Read) {
+		t.Fatal("expected abilities without audit requirement to be allowed")
+	}
+
+	var audited []Decision
+	sinkErr := errors.New("sink down")
+	g.Audit = func(ctx context.Context, d Decision) error {
+		audited = append(audited, d)
+		return sinkErr
+	}
+	if g.Check(httptest.NewRecorder(), req, "payments", Delete) {
+		t.Fatal("expected a failing sink to deny")
+	}
+	if len(audited) != 2 || !audited[0].Allowed || audited[1].Allowed {
+		t.Fatalf("expected the denial to be reported after the failure, got %+v", audited)
+	}
+
+	sinkErr, audited = nil, nil
+	if !g.Check(httptest.NewRecorder(), req, "payments", Delete) {
+		t.Fatal("expected an audited delete to be allowed")
+	}
+	if d := audited[0]; !d.Required || d.Labels[EnvironmentLabel] != "production" || d.Attributes["ip"] != "10.0.0.1" {
+		t.Fatalf("expected the full context to be audited, got %+v", d)
+	}
+
+	sinkErr = errors.New("sink down")
+	if !g.Check(httptest.NewRecorder(), req, "payments", Read) {
+		t.Fatal("expected a failing sink to be ignored for optional audits")
+	}
+}
+
+func TestGuardShadow(t *testing.T) {
+	r, err := OpenFile("testdata/rbac.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var denials []Decision
+	g := &Guard{Shadow: true, Backoff: &Backoff{}, Audit: func(ctx context.Context, d Decision) error {
+		if !d.Allowed {
+			denials = append(denials, d)
+		}
+		return nil
+	}}
+
+	for _, role := range []Role{r["user"], nil} {
+		req := httptest.NewRequest(http.MethodDelete, "/users", nil)
+		if role != nil {
+			req = req.WithContext(WithRole(req.Context(), role))
+		}
+		w := httptest.NewRecorder()
+		if !g.Check(w, req, "users", Delete) || w.Code != http.StatusOK || w.Header().Get("Retry-After") != "" {
+			t.Fatalf("expected shadow mode to let the request through, got %d", w.Code)
+		}
+	}
+
+	if len(denials) != 2 || !denials[0].Shadow || denials[0].Permission != "users" || denials[0].Ability != Delete {
+		t.Fatalf("expected the would-be denials to be audited, got %+v", denials)
+	}
+}
@@ -0,0 +1,100 @@
+package can
+
+import "strings"
+
+// HierarchySeparator separates the levels of hierarchical resources such
+// as projects/7/tasks. When a role has no exact entry for a permission,
+// Can walks up the hierarchy and uses the closest parent that is
+// granted, so an ability on projects implies it on projects/7/tasks.
+// A "*" level in a role's permission key matches any single level, as in
+// projects/*/tasks. Set it to "" to disable hierarchy lookups.
+var HierarchySeparator = "/"
+
+// Wildcard matches any single level of a hierarchical permission.
+const Wildcard = "*"
+
+// lookup finds the permission of role that applies to permission, trying
+// the exact key first, then every level of the hierarchy from the most
+// to the least specific and finally the alias of permission (see Aliases).
+// Unlike Can, it doesn't report the use of an alias.
+func lookup(role Role, permission string) (Permission, bool) {
+	p, _, ok := lookupKey(role, permission)
+	if !ok {
+		if target, aliased := unalias(permission); aliased {
+			p, _, ok = lookupKey(role, target)
+		}
+	}
+
+	return p, ok
+}
+
+// lookupKey is lookup without aliases that also returns the key of the
+// matched permission.
+func lookupKey(role Role, permission string) (Permission, string, bool) {
+	if p, ok := role[permission]; ok {
+		return p, permission, true
+	}
+
+	if HierarchySeparator == "" {
+		return Permission{}, "", false
+	}
+
+	levels := strings.Split(permission, HierarchySeparator)
+	for n := len(levels); n > 0; n-- {
+		if n < len(levels) {
+			key := strings.Join(levels[:n], HierarchySeparator)
+			if p, ok := role[key]; ok {
+				return p, key, true
+			}
+		}
+
+		if key, ok := matchWildcard(role, levels[:n]); ok {
+			return role[key], key, true
+		}
+	}
+
+	return Permission{}, "", false
+}
+
+// matchWildcard finds the wildcard key of role matching levels. When
+// several keys match, the one with the fewest wildcards wins, ties are
+// broken by key so the result doesn't depend on map order.
+func matchWildcard(role Role, levels []string) (string, bool) {
+	var (
+		best      string
+		bestCount = -1
+	)
+
+	for key := range role {
+		if !strings.Contains(key, Wildcard) {
+			continue
+		}
+
+		pattern := strings.Split(key, HierarchySeparator)
+		if len(pattern) != len(levels) {
+			continue
+		}
+
+		count, ok := 0, true
+		for i, level := range pattern {
+			if level == Wildcard {
+				count++
+				continue
+			}
+			if level != levels[i] {
+				ok = false
+				break
+			}
+		}
+
+		if ok && (bestCount == -1 || count < bestCount || (count == bestCount && key < best)) {
+			best, bestCount = key, count
+		}
+	}
+
+	if bestCount == -1 {
+		return "", false
+	}
+
+	return best, true
+}
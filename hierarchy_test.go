@@ -0,0 +1,51 @@
+package can
+
+import (
+	"context"
+	"testing"
+)
+
+func TestHierarchy(t *testing.T) {
+	role, err := ConfigErr(DiskRoles{
+		"member": DiskRole{
+			"projects":         DiskPermission{Abilities: []string{"read"}},
+			"projects/*/tasks": DiskPermission{Abilities: []string{"all"}},
+			"projects/7/tasks": DiskPermission{Abilities: []string{"read"}},
+			"billing/*":        DiskPermission{Abilities: []string{"update"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	member := role["member"]
+	ctx := context.Background()
+
+	tests := []struct {
+		permission string
+		ability    Ability
+		want       bool
+	}{
+		{"projects", Read, true},
+		{"projects/3", Read, true},
+		{"projects/3", Delete, false},
+		{"projects/3/tasks", Delete, true},
+		{"projects/3/tasks/9", Delete, true},
+		{"projects/7/tasks", Delete, false},
+		{"projects/7/tasks/1", Read, true},
+		{"billing/invoices", Update, true},
+		{"billing", Update, false},
+		{"users/1", Read, false},
+	}
+
+	for _, tt := range tests {
+		if got := Can(ctx, member, tt.permission, tt.ability, Compare(1, 1)); got != tt.want {
+			t.Errorf("%s %s: expected %v, got %v", tt.permission, tt.ability, tt.want, got)
+		}
+	}
+
+	defer func(s string) { HierarchySeparator = s }(HierarchySeparator)
+	HierarchySeparator = ""
+	if Can(ctx, member, "projects/3", Read, Compare(1, 1)) {
+		t.Fatal("expected hierarchy lookups to be disabled")
+	}
+}
@@ -0,0 +1,110 @@
+package can
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// IdempotencyHeader is the request header carrying an idempotency key.
+const IdempotencyHeader = "Idempotency-Key"
+
+// Idempotency recognizes retried Create requests. A Create that carries
+// the same idempotency key as an allowed Create of the same subject and
+// permission within Window is still authorized, but its Decision is
+// marked as a Replay so audit sinks and quotas count a single logical
+// create.
+type Idempotency struct {
+	// Header is the request header carrying the key. Defaults to IdempotencyHeader.
+	Header string
+	// Key identifies the subject of a request. Defaults to the subject ID
+	// (see WithSubject) or the remote address.
+	Key func(r *http.Request) string
+	// Window is how long keys are remembered. Defaults to 24h.
+	Window time.Duration
+
+	mu        sync.Mutex
+	seen      map[string]time.Time
+	lastSweep time.Time
+}
+
+// replay reports whether an allowed Create of permission was already
+// made with the idempotency key of r within the window.
+func (i *Idempotency) replay(r *http.Request, permission string, now time.Time) bool {
+	key, ok := i.key(r, permission)
+	if !ok {
+		return false
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	at, ok := i.seen[key]
+	return ok && now.Sub(at) <= orDuration(i.Window, 24*time.Hour)
+}
+
+// record remembers the idempotency key of an allowed Create. The window
+// starts with the first create, replays don't extend it.
+func (i *Idempotency) record(r *http.Request, permission string, now time.Time) {
+	key, ok := i.key(r, permission)
+	if !ok {
+		return
+	}
+	window := orDuration(i.Window, 24*time.Hour)
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.seen == nil {
+		i.seen = make(map[string]time.Time)
+	}
+	i.sweep(now, window)
+
+	if at, ok := i.seen[key]; ok && now.Sub(at) <= window {
+		return
+	}
+	i.seen[key] = now
+}
+
+// sweep drops expired keys at most once per window.
+func (i *Idempotency) sweep(now time.Time, window time.Duration) {
+	if now.Sub(i.lastSweep) < window {
+		return
+	}
+	i.lastSweep = now
+
+	for k, at := range i.seen {
+		if now.Sub(at) > window {
+			delete(i.seen, k)
+		}
+	}
+}
+
+// key returns the key identifying the subject, permission and
+// idempotency key of r, or false if r carries no idempotency key.
+func (i *Idempotency) key(r *http.Request, permission string) (string, bool) {
+	header := i.Header
+	if header == "" {
+		header = IdempotencyHeader
+	}
+	idem := r.Header.Get(header)
+	if idem == "" {
+		return "", false
+	}
+
+	var subject string
+	switch sub, ok := SubjectFrom(r.Context()); {
+	case i.Key != nil:
+		subject = i.Key(r)
+	case ok:
+		subject = sub.ID
+	default:
+		subject = r.RemoteAddr
+		if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			subject = host
+		}
+	}
+
+	return subject + "\x00" + permission + "\x00" + idem, true
+}
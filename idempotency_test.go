@@ -0,0 +1,53 @@
+package can
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestIdempotency(t *testing.T) {
+	r, err := OpenFile("testdata/rbac.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decisions []Decision
+	g := &Guard{
+		DefaultRole: r["admin"],
+		Idempotency: &Idempotency{Window: time.Hour},
+		Audit:       func(ctx context.Context, d Decision) error { decisions = append(decisions, d); return nil },
+	}
+
+	create := func(subject, key string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/users", nil)
+		if key != "" {
+			req.Header.Set(IdempotencyHeader, key)
+		}
+		return req.WithContext(WithSubject(req.Context(), Subject{ID: subject}))
+	}
+
+	for _, req := range []*http.Request{create("u1", "k1"), create("u1", "k1"), create("u2", "k1"), create("u1", ""), create("u1", "")} {
+		if !g.Check(httptest.NewRecorder(), req, "users", Create) {
+			t.Fatal("expected admin to create users")
+		}
+	}
+
+	var replays []bool
+	for _, d := range decisions {
+		replays = append(replays, d.Replay)
+	}
+	want := []bool{false, true, false, false, false}
+	for i := range want {
+		if replays[i] != want[i] {
+			t.Fatalf("expected replays %v, got %v", want, replays)
+		}
+	}
+
+	g.Idempotency.record(create("u1", "k2"), "users", time.Now().Add(-2*time.Hour))
+	if g.Idempotency.replay(create("u1", "k2"), "users", time.Now()) {
+		t.Fatal("expected expired keys not to replay")
+	}
+}
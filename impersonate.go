@@ -0,0 +1,84 @@
+package can
+
+import (
+	"context"
+	"errors"
+)
+
+// ImpersonatePermission is the permission an actor needs, with the
+// create ability, to act with the role of someone else (see
+// Impersonate), for example support staff reproducing a customer issue.
+// The target is checked as a level below it: a grant on impersonate
+// covers every subject, one on impersonate/42 only the subject 42. It
+// must be granted explicitly, whatever Unlisted is.
+const ImpersonatePermission = "impersonate"
+
+// ErrImpersonationDenied is returned by Impersonate when the actor may
+// not impersonate.
+var ErrImpersonationDenied = errors.New("can: impersonation denied")
+
+// Impersonation describes a request made by an actor with the role of
+// someone else.
+type Impersonation struct {
+	// Actor is the ID of the subject impersonating, empty if ctx carried
+	// no subject.
+	Actor string `json:"actor,omitempty"`
+	// ActorRole is the role of the actor.
+	ActorRole Role `json:"actor_role"`
+	// Target is the ID of the subject impersonated.
+	Target string `json:"target"`
+	// Role is the role the actor acts with, the effective role of every
+	// check.
+	Role Role `json:"role"`
+}
+
+type impersonationKey struct{}
+
+// Impersonate lets an actor act with the permissions of a target
+// subject, when its role grants create on the target below
+// ImpersonatePermission. The returned ctx carries targetRole (see
+// WithRole), so a Guard decides with it, and the impersonation, so the
+// Guard records both roles, the actor and the target in every Decision.
+// Impersonated decisions are Required: a Guard without Audit denies
+// them, so impersonation is never unaudited.
+//
+// ctx - the ctx of the request, its subject is the actor
+//
+// actorRole - the role of the actor
+//
+// target - the subject to act as, its ID must not be empty
+//
+// targetRole - the role to act with, usually the role of target
+//
+// returns - the ctx to serve the request with and ErrImpersonationDenied
+// if the actor may not impersonate target
+func Impersonate(ctx context.Context, actorRole Role, target Subject, targetRole Role) (context.Context, error) {
+	if target.ID == "" || !Can(ctx, actorRole, impersonateKey(target.ID), Create, func() bool { return true }) {
+		return ctx, ErrImpersonationDenied
+	}
+
+	imp := Impersonation{ActorRole: actorRole, Target: target.ID, Role: targetRole}
+	if sub, ok := SubjectFrom(ctx); ok {
+		imp.Actor = sub.ID
+	}
+
+	return context.WithValue(WithRole(ctx, targetRole), impersonationKey{}, imp), nil
+}
+
+// impersonateKey returns the permission to impersonate the subject id.
+// Without HierarchySeparator targets can't be told apart, the grant on
+// ImpersonatePermission covers every subject.
+func impersonateKey(id string) string {
+	if HierarchySeparator == "" {
+		return ImpersonatePermission
+	}
+
+	return ImpersonatePermission + HierarchySeparator + id
+}
+
+// ImpersonationFrom returns the impersonation stored in ctx and true, or
+// the zero Impersonation and false if there is none.
+func ImpersonationFrom(ctx context.Context) (Impersonation, bool) {
+	imp, ok := ctx.Value(impersonationKey{}).(Impersonation)
+	return imp, ok
+}
@@ -0,0 +1,71 @@
+package can
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestImpersonate(t *testing.T) {
+	support := NewRole().Allow(ImpersonatePermission, Create).Build()
+	customer := NewRole().Allow("invoices", All).Build()
+	ctx := WithSubject(context.Background(), Subject{ID: "s1", Roles: []string{"support"}})
+
+	if _, err := Impersonate(ctx, customer, Subject{ID: "s2"}, support); !errors.Is(err, ErrImpersonationDenied) {
+		t.Fatalf("expected a role without the grant to be denied, got %v", err)
+	}
+
+	ctx, err := Impersonate(ctx, support, Subject{ID: "c1"}, customer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imp, ok := ImpersonationFrom(ctx); !ok || imp.Actor != "s1" || imp.Target != "c1" {
+		t.Fatalf("expected the impersonation in ctx, got %+v", imp)
+	}
+
+	var audited []Decision
+	g := &Guard{Audit: func(ctx context.Context, d Decision) error { audited = append(audited, d); return nil }}
+	w := httptest.NewRecorder()
+	if !g.Check(w, httptest.NewRequest(http.MethodDelete, "/invoices", nil).WithContext(ctx), "invoices", Delete) {
+		t.Fatalf("expected the actor to act with the customer role, got %d", w.Code)
+	}
+	d := audited[0]
+	if d.Impersonation == nil || d.Impersonation.Actor != "s1" || !d.Required {
+		t.Fatalf("expected an audited impersonated decision, got %+v", d)
+	}
+	if _, ok := d.Impersonation.Role["invoices"]; !ok {
+		t.Fatalf("expected the effective role, got %v", d.Impersonation.Role)
+	}
+	if _, ok := d.Impersonation.ActorRole[ImpersonatePermission]; !ok {
+		t.Fatalf("expected the actor role, got %v", d.Impersonation.ActorRole)
+	}
+
+	g.Audit = nil
+	if g.Check(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/invoices", nil).WithContext(ctx), "invoices", Delete) {
+		t.Fatal("expected impersonation without an audit sink to be denied")
+	}
+}
+
+func TestImpersonateTarget(t *testing.T) {
+	defer func(e Effect) { Unlisted = e }(Unlisted)
+	ctx := WithSubject(context.Background(), Subject{ID: "s1"})
+	customer := NewRole().Allow("invoices", All).Build()
+
+	scoped := NewRole().Allow(ImpersonatePermission+"/c1", Create).Build()
+	if _, err := Impersonate(ctx, scoped, Subject{ID: "c1"}, customer); err != nil {
+		t.Fatalf("expected the scoped grant to allow its target, got %v", err)
+	}
+	if _, err := Impersonate(ctx, scoped, Subject{ID: "c2"}, customer); !errors.Is(err, ErrImpersonationDenied) {
+		t.Fatalf("expected the scoped grant to deny other targets, got %v", err)
+	}
+	if _, err := Impersonate(ctx, NewRole().Allow(ImpersonatePermission, Create).Build(), Subject{}, customer); !errors.Is(err, ErrImpersonationDenied) {
+		t.Fatalf("expected a target without an ID to be denied, got %v", err)
+	}
+
+	Unlisted = Allow
+	if _, err := Impersonate(ctx, customer, Subject{ID: "c2"}, customer); !errors.Is(err, ErrImpersonationDenied) {
+		t.Fatalf("expected impersonation to need an explicit grant, got %v", err)
+	}
+}
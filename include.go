@@ -0,0 +1,149 @@
+package can
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// IncludeKey is the top level key of a role file listing other role
+// files to include, such as
+//
+//	include: [base_roles.yml, billing_roles.yml]
+//
+// Relative paths are resolved from the directory of the including file.
+// Included roles are merged with the file's own roles (see Merge). The
+// key is reserved and can't be used as a role name.
+const IncludeKey = "include"
+
+// ErrIncludeCycle is returned when role files include each other.
+var ErrIncludeCycle = errors.New("can: include cycle")
+
+// openFile loads filename and its includes, also returning the origins
+// of their permission keys. stack holds the absolute paths of the files
+// currently being included, to detect cycles.
+func openFile(filename string, stack []string) (Roles, origins, error) {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, f := range stack {
+		if f == abs {
+			return nil, nil, fmt.Errorf("%w: %s", ErrIncludeCycle, strings.Join(append(stack[i:], abs), " -> "))
+		}
+	}
+	stack = append(stack, abs)
+
+	f, err := os.OpenFile(filename, os.O_RDONLY, 0600)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	var doc yaml.Node
+	if err := yaml.NewDecoder(f).Decode(&doc); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	if err := expandNode(&doc); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	includes, err := splitIncludes(&doc)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	splitReserved(&doc)
+
+	own := make(Roles)
+	if err := doc.Decode(&own); err != nil {
+		return nil, nil, inFile(err, filename)
+	}
+	var disk DiskRoles
+	if err := doc.Decode(&disk); err != nil {
+		return nil, nil, inFile(err, filename)
+	}
+	keys := permissionOrigins(disk, filename)
+	if len(includes) == 0 {
+		return own, keys, nil
+	}
+
+	// load every include before failing, so all their problems are reported
+	sets := make([]Roles, 0, len(includes)+1)
+	keySets := make([]origins, 0, len(includes)+1)
+	var errs []error
+	for _, inc := range includes {
+		if !filepath.IsAbs(inc) {
+			inc = filepath.Join(filepath.Dir(abs), inc)
+		}
+		r, o, err := openFile(inc, stack)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		sets = append(sets, r)
+		keySets = append(keySets, o)
+	}
+	if len(errs) > 0 {
+		return nil, nil, errors.Join(errs...)
+	}
+
+	keys, err = mergeOrigins(append(keySets, keys)...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	merged, err := Merge(append(sets, own)...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	if err := DefaultBudget.Check(merged); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	return merged, keys, nil
+}
+
+// splitIncludes removes the include key from a decoded yaml document
+// and returns the files it lists.
+func splitIncludes(doc *yaml.Node) ([]string, error) {
+	key, value := splitKey(doc, IncludeKey)
+	if key == nil {
+		return nil, nil
+	}
+
+	var files []string
+	if err := value.Decode(&files); err != nil {
+		return nil, fmt.Errorf("can: line %d: %s must be a list of files", key.Line, IncludeKey)
+	}
+
+	return files, nil
+}
+
+// splitKey removes a top level key from a decoded yaml document and
+// returns its key and value nodes, or nil if the document has no such key.
+func splitKey(doc *yaml.Node, name string) (key, value *yaml.Node) {
+	root := doc
+	if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		root = root.Content[0]
+	}
+	if root.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != name {
+			continue
+		}
+
+		key, value = root.Content[i], root.Content[i+1]
+		root.Content = append(root.Content[:i], root.Content[i+2:]...)
+
+		return key, value
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,39 @@
+package can
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestInclude(t *testing.T) {
+	r, err := OpenFile("testdata/include/main.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if !Can(ctx, r["viewer"], "users", Read, Compare(1, 1)) || !Can(ctx, r["viewer"], "invoices", Read, Compare(1, 1)) {
+		t.Fatal("expected viewer to be merged from both includes")
+	}
+	if !Can(ctx, r["admin"], "users", Delete, nil) || !Can(ctx, r["admin"], "invoices", Delete, nil) {
+		t.Fatal("expected admin from the file and its include")
+	}
+	if _, ok := r[IncludeKey]; ok {
+		t.Fatal("expected include not to be decoded as a role")
+	}
+
+	if _, err := OpenFile("testdata/include/cycle.yml"); !errors.Is(err, ErrIncludeCycle) {
+		t.Fatalf("expected include cycle, got %v", err)
+	}
+
+	_, err = OpenFile("testdata/include/missing.yml")
+	if err == nil || !strings.Contains(err.Error(), "nope_a.yml") || !strings.Contains(err.Error(), "nope_b.yml") {
+		t.Fatalf("expected both missing includes to be reported, got %v", err)
+	}
+
+	if _, err := Decode(strings.NewReader("include: [a.yml]\nadmin: {}\n")); err == nil {
+		t.Fatal("expected include to be rejected outside of OpenFile")
+	}
+}
@@ -0,0 +1,105 @@
+package can
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrKeyNotFound is returned by a KV when the watched key does not exist.
+var ErrKeyNotFound = errors.New("can: key not found")
+
+// KV is implemented by key/value stores that can supply yaml encoded
+// roles, such as etcd or Consul.
+type KV interface {
+	// Get returns the value stored at key and an index that changes
+	// whenever the value changes. If index is non-zero, Get blocks until
+	// the value's index differs from index or ctx is done. A key that
+	// was deleted is reported as ErrKeyNotFound, with the index of the
+	// deletion if the store knows it.
+	Get(ctx context.Context, key string, index uint64) ([]byte, uint64, error)
+}
+
+// KVWriter is implemented by key/value stores that can be written to.
+type KVWriter interface {
+	Put(ctx context.Context, key string, value []byte) error
+}
+
+// KVPersister is a Persister that saves yaml encoded roles to a key,
+// usually the key a Watch reads from.
+type KVPersister struct {
+	KV  KVWriter
+	Key string
+}
+
+// Save implements the Persister interface.
+func (p KVPersister) Save(ctx context.Context, r Roles) error {
+	b, err := yaml.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	return p.KV.Put(ctx, p.Key, b)
+}
+
+// WatchRetry is how long Watch waits before retrying after an error.
+var WatchRetry = 5 * time.Second
+
+// Watch loads roles from key and keeps store in sync with it, re-parsing
+// the value every time the key changes. A value that fails to parse is
+// reported to onError and the previous roles are kept. Watch blocks until
+// ctx is done.
+//
+// ctx - a standard ctx, canceling it stops the watch
+//
+// kv - the key/value store to read roles from
+//
+// key - the key holding yaml encoded roles
+//
+// store - the store to update on every change
+//
+// onError - called with every read or parse error, may be nil
+//
+// returns - the ctx error once the watch stops
+func Watch(ctx context.Context, kv KV, key string, store *Store, onError func(error)) error {
+	var index uint64
+	for {
+		value, next, err := kv.Get(ctx, key, index)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if err != nil {
+			if onError != nil {
+				onError(err)
+			}
+			// watch past a deletion, or it is reported again and again
+			if next > index {
+				index = next
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-After(WatchRetry):
+			}
+			continue
+		}
+
+		if next == index {
+			continue
+		}
+		index = next
+
+		r, err := Decode(bytes.NewReader(value))
+		if err != nil {
+			if onError != nil {
+				onError(err)
+			}
+			continue
+		}
+		store.Set(r)
+	}
+}
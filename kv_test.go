@@ -0,0 +1,131 @@
+package can
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type fakeKV struct {
+	values chan []byte
+	index  uint64
+}
+
+func (f *fakeKV) Get(ctx context.Context, key string, index uint64) ([]byte, uint64, error) {
+	select {
+	case v := <-f.values:
+		f.index++
+		return v, f.index, nil
+	case <-ctx.Done():
+		return nil, 0, ctx.Err()
+	}
+}
+
+func TestWatch(t *testing.T) {
+	kv := &fakeKV{values: make(chan []byte)}
+	store := NewStore(nil)
+	errs := make(chan error, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- Watch(ctx, kv, "roles", store, func(err error) { errs <- err })
+	}()
+
+	kv.values <- []byte("admin:\n  users:\n    abilities: [all]\n")
+	kv.values <- []byte("admin: [not, a, role")
+	<-errs
+
+	if !store.Can(context.Background(), "admin", "users", Delete, nil) {
+		t.Fatal("expected roles from kv to be loaded and kept after a bad update")
+	}
+
+	kv.values <- []byte("admin:\n  users:\n    abilities: [read]\n")
+	kv.values <- []byte("admin:\n  users:\n    abilities: [read]\n")
+	if store.Can(context.Background(), "admin", "users", Delete, nil) {
+		t.Fatal("expected roles to be reloaded")
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("watch did not stop")
+	}
+}
+
+// deletingKV reports the deletion of its key once, at index 2, and the
+// key created again at index 3 to watches past the deletion.
+type deletingKV struct {
+	indexes chan uint64
+}
+
+func (kv *deletingKV) Get(ctx context.Context, key string, index uint64) ([]byte, uint64, error) {
+	kv.indexes <- index
+	switch index {
+	case 0:
+		return []byte("admin: {}\n"), 1, nil
+	case 1:
+		return nil, 2, ErrKeyNotFound
+	case 2:
+		return []byte("admin:\n  users:\n    abilities: [all]\n"), 3, nil
+	}
+
+	<-ctx.Done()
+	return nil, 0, ctx.Err()
+}
+
+func TestWatchDeleted(t *testing.T) {
+	defer func(d time.Duration) { WatchRetry = d }(WatchRetry)
+	WatchRetry = time.Millisecond
+
+	kv := &deletingKV{indexes: make(chan uint64, 8)}
+	store := NewStore(nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go Watch(ctx, kv, "roles", store, nil)
+
+	for _, want := range []uint64{0, 1, 2, 3} {
+		select {
+		case index := <-kv.indexes:
+			if index != want {
+				t.Fatalf("expected a watch from index %d, got %d", want, index)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected a watch from index %d", want)
+		}
+	}
+	if !store.Can(context.Background(), "admin", "users", Delete, nil) {
+		t.Fatal("expected the key created again to be loaded")
+	}
+}
+
+type fakeKVWriter map[string][]byte
+
+func (f fakeKVWriter) Put(ctx context.Context, key string, value []byte) error {
+	f[key] = value
+	return nil
+}
+
+func TestKVPersister(t *testing.T) {
+	kv := fakeKVWriter{}
+	s := NewStore(nil)
+	s.Persister = KVPersister{KV: kv, Key: "roles"}
+
+	if err := s.Grant(context.Background(), "admin", "users", All); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Decode(bytes.NewReader(kv["roles"]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Can(context.Background(), r["admin"], "users", Delete, nil) {
+		t.Fatalf("expected saved roles to grant all, got %s", kv["roles"])
+	}
+}
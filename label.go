@@ -0,0 +1,30 @@
+package can
+
+// EnvironmentLabel is the label matched by the environments shorthand of
+// a permission in the role file.
+const EnvironmentLabel = "environment"
+
+// Selected reports whether the permission applies given labels. A
+// permission without selectors always applies, otherwise every selector
+// key must be present in labels with one of the selector's values.
+func (p Permission) Selected(labels Labels) bool {
+	for key, values := range p.Selectors {
+		v, ok := labels[key]
+		if !ok {
+			return false
+		}
+
+		matched := false
+		for _, want := range values {
+			if v == want {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	return true
+}
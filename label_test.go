@@ -0,0 +1,53 @@
+package can
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSelectors(t *testing.T) {
+	r, err := ConfigErr(DiskRoles{
+		"developer": DiskRole{
+			"deployments": DiskPermission{Abilities: []string{"all"}, Environments: []string{"dev", "staging"}},
+			"logs":        DiskPermission{Abilities: []string{"read"}, Labels: map[string][]string{"region": {"eu"}}},
+			"docs":        DiskPermission{Abilities: []string{"read"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dev := r["developer"]
+
+	staging := WithLabels(context.Background(), Labels{EnvironmentLabel: "staging", "region": "us"})
+	prod := WithLabels(context.Background(), Labels{EnvironmentLabel: "prod", "region": "eu"})
+
+	if !Can(staging, dev, "deployments", Delete, nil) {
+		t.Fatal("expected staging deployments to be allowed")
+	}
+	if Can(prod, dev, "deployments", Delete, nil) {
+		t.Fatal("expected prod deployments to be denied")
+	}
+	if Can(context.Background(), dev, "deployments", Delete, nil) {
+		t.Fatal("expected missing labels to be denied")
+	}
+	if Can(staging, dev, "logs", Read, Compare(1, 1)) || !Can(prod, dev, "logs", Read, Compare(1, 1)) {
+		t.Fatal("expected logs to be limited to eu")
+	}
+	if !Can(prod, dev, "docs", Read, Compare(1, 1)) {
+		t.Fatal("expected unconditional grants to ignore labels")
+	}
+
+	g := &Guard{Labels: Labels{EnvironmentLabel: "prod"}}
+	req := httptest.NewRequest(http.MethodDelete, "/deployments", nil)
+	req = req.WithContext(WithRole(req.Context(), dev))
+	if g.Check(httptest.NewRecorder(), req, "deployments", Delete) {
+		t.Fatal("expected guard labels to apply")
+	}
+
+	req = req.WithContext(WithLabels(req.Context(), Labels{EnvironmentLabel: "dev"}))
+	if !g.Check(httptest.NewRecorder(), req, "deployments", Delete) {
+		t.Fatal("expected request labels to take precedence over guard labels")
+	}
+}
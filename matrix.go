@@ -0,0 +1,221 @@
+package can
+
+import (
+	"encoding/csv"
+	"fmt"
+	"html"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Access is the access a role has to an ability in an access Matrix.
+type Access int
+
+const (
+	// NoAccess means the ability isn't granted.
+	NoAccess Access = iota
+	// FullAccess means the ability is granted on every record.
+	FullAccess
+	// OwnerAccess means the ability is granted when the compare function
+	// of the check is satisfied, usually on the subject's own records.
+	OwnerAccess
+	// ConditionalAccess means the ability is granted when the labels of
+	// the check match the permission's selectors, its conditions are met,
+	// it comes from one of its networks and its quota isn't used up.
+	ConditionalAccess
+	// DeniedAccess means the ability is explicitly denied.
+	DeniedAccess
+)
+
+// String implements the Stringer interface.
+func (a Access) String() string {
+	switch a {
+	case FullAccess:
+		return "allow"
+	case OwnerAccess:
+		return "owner"
+	case ConditionalAccess:
+		return "conditional"
+	case DeniedAccess:
+		return "deny"
+	}
+
+	return ""
+}
+
+// MatrixAbilities are the abilities reported in an access Matrix, All
+// and Skip grant each of them.
+var MatrixAbilities = []Ability{Read, Create, Update, Delete}
+
+// Matrix is the access every role has to every permission, for security
+// reviews and compliance audits.
+type Matrix struct {
+	Rows []MatrixRow
+}
+
+// MatrixRow is the access of a role to a permission, with one entry per
+// ability of MatrixAbilities.
+type MatrixRow struct {
+	Role       string
+	Permission string
+	Access     []Access
+}
+
+// AccessMatrix computes the access matrix of roles: a row for every role
+// and every permission any role lists, sorted by role and permission. A
+// permission the role doesn't list is reported as Can would decide it,
+// through its parents in the hierarchy and Unlisted.
+//
+// roles - the roles
+//
+// returns - the matrix
+func AccessMatrix(roles Roles) Matrix {
+	names := make([]string, 0, len(roles))
+	set := make(map[string]struct{})
+	for name, role := range roles {
+		names = append(names, name)
+		for permission := range role {
+			set[permission] = struct{}{}
+		}
+	}
+	sort.Strings(names)
+
+	permissions := make([]string, 0, len(set))
+	for permission := range set {
+		permissions = append(permissions, permission)
+	}
+	sort.Strings(permissions)
+
+	var m Matrix
+	for _, name := range names {
+		for _, permission := range permissions {
+			row := MatrixRow{Role: name, Permission: permission, Access: make([]Access, len(MatrixAbilities))}
+			for i, ability := range MatrixAbilities {
+				row.Access[i] = access(roles[name], permission, ability)
+			}
+			m.Rows = append(m.Rows, row)
+		}
+	}
+
+	return m
+}
+
+// access returns the access of role to ability on permission.
+func access(role Role, permission string, ability Ability) Access {
+	p, ok := lookup(role, permission)
+	if !ok {
+		if unlistedAllowed(permission) {
+			return FullAccess
+		}
+		return NoAccess
+	}
+
+	if p.Denies(ability) {
+		return DeniedAccess
+	}
+
+	_, okAbility := p.Abilities[ability]
+	_, okAll := p.Abilities[All]
+	_, okSkip := p.Abilities[Skip]
+	switch {
+	case !okAbility && !okAll && !okSkip:
+		return NoAccess
+	case len(p.Selectors) > 0 || len(p.Conditions) > 0 || len(p.Networks) > 0 || len(p.Quotas) > 0:
+		return ConditionalAccess
+	case okAll || okSkip:
+		return FullAccess
+	}
+
+	return OwnerAccess
+}
+
+// header returns the column names of the matrix.
+func (m Matrix) header() []string {
+	header := []string{"role", "permission"}
+	for _, a := range MatrixAbilities {
+		header = append(header, a.String())
+	}
+
+	return header
+}
+
+// records returns the rows of the matrix as strings.
+func (m Matrix) records() [][]string {
+	records := make([][]string, 0, len(m.Rows))
+	for _, row := range m.Rows {
+		record := []string{row.Role, row.Permission}
+		for _, a := range row.Access {
+			record = append(record, a.String())
+		}
+		records = append(records, record)
+	}
+
+	return records
+}
+
+// WriteCSV writes the matrix as CSV, with a header line.
+func (m Matrix) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(m.header()); err != nil {
+		return err
+	}
+	if err := cw.WriteAll(m.records()); err != nil {
+		return err
+	}
+
+	return cw.Error()
+}
+
+// WriteMarkdown writes the matrix as a Markdown table.
+func (m Matrix) WriteMarkdown(w io.Writer) error {
+	row := func(cells []string) string {
+		escaped := make([]string, len(cells))
+		for i, c := range cells {
+			escaped[i] = strings.ReplaceAll(c, "|", `\|`)
+		}
+		return "| " + strings.Join(escaped, " | ") + " |\n"
+	}
+
+	header := m.header()
+	separator := make([]string, len(header))
+	for i := range separator {
+		separator[i] = "---"
+	}
+
+	var b strings.Builder
+	b.WriteString(row(header))
+	b.WriteString(row(separator))
+	for _, record := range m.records() {
+		b.WriteString(row(record))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteHTML writes the matrix as an HTML table. Every cell has the class
+// access-<access> (e.g. access-deny) for styling.
+func (m Matrix) WriteHTML(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("<table>\n<thead>\n<tr>")
+	for _, h := range m.header() {
+		fmt.Fprintf(&b, "<th>%s</th>", html.EscapeString(h))
+	}
+	b.WriteString("</tr>\n</thead>\n<tbody>\n")
+	for _, row := range m.Rows {
+		fmt.Fprintf(&b, "<tr><td>%s</td><td>%s</td>", html.EscapeString(row.Role), html.EscapeString(row.Permission))
+		for _, a := range row.Access {
+			class := a.String()
+			if class == "" {
+				class = "none"
+			}
+			fmt.Fprintf(&b, `<td class="access-%s">%s</td>`, class, a)
+		}
+		b.WriteString("</tr>\n")
+	}
+	b.WriteString("</tbody>\n</table>\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
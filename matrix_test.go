@@ -0,0 +1,53 @@
+package can
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestAccessMatrix(t *testing.T) {
+	roles := Roles{
+		"admin":  NewRole().Allow("users", All).Deny("users", Delete).Build(),
+		"user":   NewRole().Allow("users", Read, Update).Where("reports", EnvironmentLabel, "staging").Allow("reports", Read).Build(),
+		"viewer": NewRole().Allow("reports", Read).Build(),
+	}
+
+	m := AccessMatrix(roles)
+	if len(m.Rows) != 6 {
+		t.Fatalf("expected a row for every role and permission, got %d", len(m.Rows))
+	}
+
+	var buf bytes.Buffer
+	if err := m.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `role,permission,read,create,update,delete
+admin,reports,,,,
+admin,users,allow,allow,allow,deny
+user,reports,conditional,,,
+user,users,owner,,owner,
+viewer,reports,owner,,,
+viewer,users,,,,
+`
+	if buf.String() != want {
+		t.Fatalf("unexpected CSV:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := m.WriteMarkdown(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "| role | permission | read | create | update | delete |\n| --- |") ||
+		!strings.Contains(buf.String(), "| admin | users | allow | allow | allow | deny |\n") {
+		t.Fatalf("unexpected Markdown:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := (Matrix{Rows: []MatrixRow{{Role: "<b>", Permission: "users", Access: []Access{FullAccess, NoAccess, NoAccess, NoAccess}}}}).WriteHTML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `<td>&lt;b&gt;</td><td>users</td><td class="access-allow">allow</td><td class="access-none"></td>`) {
+		t.Fatalf("unexpected HTML:\n%s", buf.String())
+	}
+}
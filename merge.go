@@ -0,0 +1,235 @@
+package can
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+)
+
+// ErrMergeConflict is returned by Merge when two role sets define the
+// same permission in contradicting ways.
+var ErrMergeConflict = errors.New("can: merge conflict")
+
+// Merge combines several role sets, for example one per team, into one.
+// Roles and permissions present in several sets are combined:
+//
+//   - granted abilities are the union of every set
+//   - denied abilities are the union of every set, and a denial always
+//     wins over a grant when checking
+//   - audited abilities are the union of every set
+//   - resource, selectors, conditions, networks, quotas and field restrictions must agree, otherwise
+//     the sets contradict each other and ErrMergeConflict is returned
+//
+// Roles don't record which resource or route produced a permission key,
+// so Merge can't detect keys that collide across sets; OpenFiles and
+// included files report them with ErrPermissionCollision. The inputs
+// are not modified.
+//
+// roles - the role sets to merge, in order
+//
+// returns - the merged roles and an error
+func Merge(roles ...Roles) (Roles, error) {
+	merged := make(Roles)
+	for _, set := range roles {
+		for name, role := range set {
+			target, ok := merged[name]
+			if !ok {
+				merged[name] = role.Clone()
+				continue
+			}
+
+			for key, p := range role {
+				existing, ok := target[key]
+				if !ok {
+					target[key] = p.Clone()
+					continue
+				}
+
+				combined, err := mergePermission(existing, p)
+				if err != nil {
+					return nil, fmt.Errorf("%w: role %q permission %q: %v", ErrMergeConflict, name, key, err)
+				}
+				target[key] = combined
+			}
+		}
+	}
+
+	return merged, nil
+}
+
+// mergePermission combines two definitions of the same permission.
+func mergePermission(a, b Permission) (Permission, error) {
+	switch {
+	case a.Resource != "" && b.Resource != "" && a.Resource != b.Resource:
+		return a, fmt.Errorf("resource %q contradicts %q", b.Resource, a.Resource)
+	case !reflect.DeepEqual(a.Selectors, b.Selectors):
+		return a, fmt.Errorf("selectors %v contradict %v", b.Selectors, a.Selectors)
+	case !reflect.DeepEqual(a.Conditions, b.Conditions):
+		return a, fmt.Errorf("conditions %v contradict %v", b.Conditions, a.Conditions)
+	case !reflect.DeepEqual(a.Networks, b.Networks):
+		return a, fmt.Errorf("networks %v contradict %v", b.Networks, a.Networks)
+	case !reflect.DeepEqual(a.Fields, b.Fields) && a.Fields != nil && b.Fields != nil:
+		return a, fmt.Errorf("fields %v contradict %v", b.Fields, a.Fields)
+	case !reflect.DeepEqual(a.Quotas, b.Quotas) && a.Quotas != nil && b.Quotas != nil:
+		return a, fmt.Errorf("quotas %v contradict %v", quotaNames(b.Quotas), quotaNames(a.Quotas))
+	}
+
+	if a.Resource == "" {
+		a.Resource = b.Resource
+	}
+	if a.Fields == nil {
+		a.Fields = b.Fields
+	}
+	if a.Quotas == nil {
+		a.Quotas = b.Quotas
+	}
+	for ability := range b.Abilities {
+		a.Abilities[ability] = struct{}{}
+	}
+	for ability := range b.Denied {
+		if a.Denied == nil {
+			a.Denied = make(map[Ability]struct{})
+		}
+		a.Denied[ability] = struct{}{}
+	}
+	for ability := range b.Audited {
+		if a.Audited == nil {
+			a.Audited = make(map[Ability]struct{})
+		}
+		a.Audited[ability] = struct{}{}
+	}
+
+	return a, nil
+}
+
+// OpenFiles loads every yaml role file matching globs and merges them
+// with Merge. Files are merged in lexical order and a file matched by
+// several globs is only loaded once.
+//
+// globs - file names or patterns as understood by filepath.Glob
+//
+// returns - the merged roles and an error, including when a glob
+// matches no file. The problems of every file are reported together
+func OpenFiles(globs ...string) (Roles, error) {
+	seen := make(map[string]struct{})
+	var files []string
+	for _, g := range globs {
+		matches, err := filepath.Glob(g)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("can: no role files match %q", g)
+		}
+		for _, m := range matches {
+			if _, ok := seen[m]; !ok {
+				seen[m] = struct{}{}
+				files = append(files, m)
+			}
+		}
+	}
+	sort.Strings(files)
+
+	// load every file before failing, so all their problems are reported
+	sets := make([]Roles, 0, len(files))
+	keySets := make([]origins, 0, len(files))
+	var errs []error
+	for _, f := range files {
+		r, o, err := openFile(f, nil)
+		if err != nil {
+			// the errors of openFile already name the file
+			errs = append(errs, err)
+			continue
+		}
+		sets = append(sets, r)
+		keySets = append(keySets, o)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	if _, err := mergeOrigins(keySets...); err != nil {
+		return nil, err
+	}
+	merged, err := Merge(sets...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := DefaultBudget.Check(merged); err != nil {
+		return nil, err
+	}
+
+	return merged, nil
+}
+
+// origin is the config entry a permission key was built from.
+type origin struct {
+	entry string
+	file  string
+}
+
+// origins holds the origin of every permission key, by role and key.
+type origins map[string]map[string]origin
+
+// permissionOrigins finds the origins of the permission keys of the
+// roles of file. Collisions within the file are left to buildRole.
+func permissionOrigins(c DiskRoles, file string) origins {
+	o := make(origins, len(c))
+	for name, role := range c {
+		keys := make(map[string]origin)
+		for resource, p := range role {
+			keys[PermissionKey(resource)] = origin{entry: fmt.Sprintf("resource %q", resource), file: file}
+			for _, route := range p.Routes {
+				if _, ok := keys[PermissionKey(resource, route)]; !ok {
+					keys[PermissionKey(resource, route)] = origin{entry: fmt.Sprintf("route %q of resource %q", route, resource), file: file}
+				}
+			}
+		}
+		o[name] = keys
+	}
+
+	return o
+}
+
+// mergeOrigins combines the origins of several files, reporting every
+// permission key that different entries produce, the same check
+// buildRole does within a file.
+func mergeOrigins(sets ...origins) (origins, error) {
+	merged := make(origins)
+	var errs []error
+	for _, set := range sets {
+		names := make([]string, 0, len(set))
+		for name := range set {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			if merged[name] == nil {
+				merged[name] = make(map[string]origin)
+			}
+			keys := make([]string, 0, len(set[name]))
+			for key := range set[name] {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+
+			for _, key := range keys {
+				o := set[name][key]
+				other, ok := merged[name][key]
+				if !ok {
+					merged[name][key] = o
+					continue
+				}
+				if other.entry != o.entry {
+					errs = append(errs, fmt.Errorf("%w: role %q: %s in %s and %s in %s both map to %q", ErrPermissionCollision, name, o.entry, o.file, other.entry, other.file, key))
+				}
+			}
+		}
+	}
+
+	return merged, errors.Join(errs...)
+}
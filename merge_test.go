@@ -0,0 +1,92 @@
+package can
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenFiles(t *testing.T) {
+	r, err := OpenFiles("testdata/teams/*.yml", "testdata/teams/billing.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	support := r["support"]
+	if !Can(ctx, support, "users", Read, Compare(1, 1)) || !Can(ctx, support, "users", Update, Compare(1, 1)) {
+		t.Fatal("expected abilities from both files")
+	}
+	if Can(ctx, support, "users", Delete, Compare(1, 1)) {
+		t.Fatal("expected deny to win over a grant from another file")
+	}
+	if !Can(ctx, r["admin"], "invoices", Delete, nil) {
+		t.Fatal("expected admin from billing")
+	}
+
+	if _, err := OpenFiles("testdata/teams/*.json"); err == nil {
+		t.Fatal("expected an error for a glob matching nothing")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	a := Roles{"user": Role{"users": Permission{Abilities: map[Ability]struct{}{Read: {}}, Resource: "users"}}}
+	b := Roles{"user": Role{"users": Permission{Abilities: map[Ability]struct{}{Update: {}}}}}
+
+	merged, err := Merge(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(merged["user"]["users"].Abilities) != 2 || merged["user"]["users"].Resource != "users" {
+		t.Fatalf("unexpected merge %+v", merged["user"]["users"])
+	}
+	if len(a["user"]["users"].Abilities) != 1 {
+		t.Fatal("expected inputs to be left untouched")
+	}
+
+	c := Roles{"user": Role{"users": Permission{Abilities: map[Ability]struct{}{Read: {}}, Resource: "accounts"}}}
+	if _, err := Merge(a, c); !errors.Is(err, ErrMergeConflict) {
+		t.Fatalf("expected resource conflict, got %v", err)
+	}
+
+	d := Roles{"user": Role{"users": Permission{Abilities: map[Ability]struct{}{Read: {}}, Selectors: map[string][]string{EnvironmentLabel: {"dev"}}}}}
+	if _, err := Merge(a, d); !errors.Is(err, ErrMergeConflict) {
+		t.Fatalf("expected selector conflict, got %v", err)
+	}
+}
+
+func TestOpenFilesCollision(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.yml": "user:\n  users:\n    abilities: [read]\n    routes: [admin]\n",
+		"b.yml": "user:\n  users_admin:\n    abilities: [delete]\n",
+		"c.yml": "user:\n  users:\n    abilities: [update]\n    routes: [admin]\n",
+	}
+	for name, body := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := OpenFiles(filepath.Join(dir, "a.yml"), filepath.Join(dir, "b.yml")); !errors.Is(err, ErrPermissionCollision) {
+		t.Fatalf("expected keys colliding across files to be rejected, got %v", err)
+	}
+
+	r, err := OpenFiles(filepath.Join(dir, "a.yml"), filepath.Join(dir, "c.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Can(context.Background(), r["user"], "users_admin", Update, Compare(1, 1)) {
+		t.Fatal("expected the same route in several files to merge")
+	}
+
+	include := "include: [a.yml]\n" + files["b.yml"]
+	if err := os.WriteFile(filepath.Join(dir, "include.yml"), []byte(include), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenFile(filepath.Join(dir, "include.yml")); !errors.Is(err, ErrPermissionCollision) {
+		t.Fatalf("expected keys colliding with an include to be rejected, got %v", err)
+	}
+}
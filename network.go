@@ -0,0 +1,115 @@
+package can
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// ErrInvalidNetwork is returned when a role file restricts a permission
+// to a network that isn't a CIDR block or IP address.
+var ErrInvalidNetwork = errors.New("can: invalid network")
+
+// InNetwork reports whether a check from remoteAddr, an IP address with
+// or without a port, may use the permission: permissions without
+// networks are granted everywhere, the others only to addresses in one
+// of their networks.
+func (p Permission) InNetwork(remoteAddr string) bool {
+	if len(p.Networks) == 0 {
+		return true
+	}
+
+	addr, ok := parseRemoteAddr(remoteAddr)
+	if !ok {
+		return false
+	}
+	for _, network := range p.Networks {
+		if network.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// inNetwork checks the networks of p against the remote address of the
+// request in ctx (see WithRequest). Checks without a request are denied
+// by permissions with networks.
+func inNetwork(ctx context.Context, p Permission, trace *Explanation) bool {
+	if len(p.Networks) == 0 {
+		return true
+	}
+
+	req, _ := RequestFrom(ctx)
+	if !p.InNetwork(req.RemoteAddr) {
+		if trace != nil {
+			trace.step("remote address %q not in networks %v", req.RemoteAddr, p.Networks)
+		}
+		return false
+	}
+
+	return true
+}
+
+// parseRemoteAddr returns the IP address of a remote address.
+func parseRemoteAddr(remoteAddr string) (netip.Addr, bool) {
+	host := remoteAddr
+	if h, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		host = h
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+
+	return addr.Unmap(), true
+}
+
+// buildNetworks converts config representations of networks, CIDR blocks
+// or single IP addresses, reporting those that can't be parsed.
+func buildNetworks(cidrs []string) ([]netip.Prefix, error) {
+	networks := make([]netip.Prefix, 0, len(cidrs))
+	var invalid []string
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if !strings.Contains(cidr, "/") {
+			addr, err := netip.ParseAddr(cidr)
+			if err != nil {
+				invalid = append(invalid, cidr)
+				continue
+			}
+			addr = addr.Unmap()
+			networks = append(networks, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		network, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			invalid = append(invalid, cidr)
+			continue
+		}
+		networks = append(networks, network.Masked())
+	}
+	if len(invalid) > 0 {
+		return networks, fmt.Errorf("%w: %s", ErrInvalidNetwork, strings.Join(invalid, ", "))
+	}
+
+	return networks, nil
+}
+
+// networkNames returns the networks as CIDR blocks, or nil if there are none.
+func networkNames(networks []netip.Prefix) []string {
+	if len(networks) == 0 {
+		return nil
+	}
+
+	names := make([]string, len(networks))
+	for i, n := range networks {
+		names[i] = n.String()
+	}
+
+	return names
+}
@@ -0,0 +1,69 @@
+package can
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNetworks(t *testing.T) {
+	roles, err := Decode(strings.NewReader(`
+admin:
+  admin_settings:
+    abilities: [all]
+  admin_settings_danger:
+    abilities: [all]
+    cidr: [10.0.0.0/8, 192.168.1.7, "fd00::/8"]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := roles["admin"]["admin_settings_danger"]
+	if got := strings.Join(networkNames(p.Networks), " "); got != "10.0.0.0/8 192.168.1.7/32 fd00::/8" {
+		t.Fatalf("expected the networks, got %s", got)
+	}
+
+	for addr, want := range map[string]bool{
+		"10.1.2.3:4242":          true,
+		"[::ffff:10.1.2.3]:4242": true,
+		"192.168.1.7":            true,
+		"[fd00::1]:80":           true,
+		"192.168.1.8:4242":       false,
+		"203.0.113.1:4242":       false,
+		"not an address":         false,
+		"":                       false,
+	} {
+		if got := p.InNetwork(addr); got != want {
+			t.Fatalf("expected %q in network to be %v, got %v", addr, want, got)
+		}
+	}
+
+	g := &Guard{DefaultRole: roles["admin"]}
+	check := func(remote, permission string) bool {
+		req := httptest.NewRequest(http.MethodDelete, "/settings", nil)
+		req.RemoteAddr = remote
+		return g.Check(httptest.NewRecorder(), req, permission, Delete)
+	}
+	if !check("10.0.0.1:1234", "admin_settings_danger") || check("203.0.113.1:1234", "admin_settings_danger") {
+		t.Fatal("expected delete to be granted from the internal network only")
+	}
+	if !check("203.0.113.1:1234", "admin_settings") {
+		t.Fatal("expected permissions without networks to be granted everywhere")
+	}
+
+	cached := &Guard{DefaultRole: roles["admin"], Authorizer: Cached(Local, time.Minute, 0)}
+	for remote, want := range map[string]bool{"10.0.0.1:1": true, "203.0.113.1:1": false} {
+		req := httptest.NewRequest(http.MethodDelete, "/settings", nil)
+		req.RemoteAddr = remote
+		if got := cached.Check(httptest.NewRecorder(), req, "admin_settings_danger", Delete); got != want {
+			t.Fatalf("expected the cache to decide per remote address, got %v for %s", got, remote)
+		}
+	}
+
+	if _, err := Decode(strings.NewReader("admin:\n  users:\n    abilities: [read]\n    cidr: [10.0.0.0/33, intranet]\n")); !errors.Is(err, ErrInvalidNetwork) || !strings.Contains(err.Error(), "intranet") {
+		t.Fatalf("expected the invalid networks to be reported, got %v", err)
+	}
+}
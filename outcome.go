@@ -0,0 +1,96 @@
+package can
+
+import "context"
+
+// Outcome is the result of a check made with Decide.
+type Outcome int
+
+const (
+	// Denied means the check was denied.
+	Denied Outcome = iota
+	// Allowed means the check was allowed.
+	Allowed
+	// Undecided means the check could not be decided, for example a
+	// remote Authorizer or a comparator cut off by the ctx of the check.
+	// OnUndecided controls whether it is allowed.
+	Undecided
+)
+
+// String implements the Stringer interface.
+func (o Outcome) String() string {
+	switch o {
+	case Allowed:
+		return "allowed"
+	case Undecided:
+		return "undecided"
+	}
+
+	return "denied"
+}
+
+// Allow reports whether the outcome allows the check, applying
+// OnUndecided to undecided checks.
+func (o Outcome) Allow() bool {
+	return o == Allowed || (o == Undecided && OnUndecided == Allow)
+}
+
+// OnUndecided is the effect applied to checks that could not be decided
+// because their ctx was done, for example because a remote Authorizer
+// timed out or the client went away. Checks answered from the role, such
+// as a permission the role doesn't grant, are decided whatever the ctx.
+// It defaults to Deny (fail closed). Can, Chain and Decide enforce it.
+var OnUndecided = Deny
+
+// OutcomeAuthorizer is implemented by authorizers that tell checks they
+// could not decide apart from denied ones, such as remote authorizers
+// whose request was cut off by the ctx. Decide uses it when available.
+type OutcomeAuthorizer interface {
+	AuthorizeOutcome(ctx context.Context, role Role, permission string, ability Ability, compare func() bool) Outcome
+}
+
+// Decide asks a for a decision and tells undecided checks apart from
+// denied ones. A denial is always Denied. Unless a is an
+// OutcomeAuthorizer, an allow returned once ctx is done is Undecided, as
+// a may have given up on the check and followed OnUndecided.
+//
+// ctx - a standard ctx, its cancellation and deadline are honored
+//
+// a - the authorizer
+//
+// role, permission, ability, compare - the check, see Can
+//
+// returns - the outcome of the check
+func Decide(ctx context.Context, a Authorizer, role Role, permission string, ability Ability, compare func() bool) Outcome {
+	if o, ok := a.(OutcomeAuthorizer); ok {
+		return o.AuthorizeOutcome(ctx, role, permission, ability, compare)
+	}
+
+	return decided(ctx, a.Authorize(ctx, role, permission, ability, compare))
+}
+
+// decided returns the outcome of a check an Authorizer returned allowed
+// for, see Decide.
+func decided(ctx context.Context, allowed bool) Outcome {
+	switch {
+	case !allowed:
+		return Denied
+	case ctx.Err() != nil:
+		return Undecided
+	}
+
+	return Allowed
+}
+
+// outcome returns the outcome of a check that was decided.
+func outcome(allowed bool) Outcome {
+	if allowed {
+		return Allowed
+	}
+
+	return Denied
+}
+
+// undecided returns the decision of a check that could not be decided.
+func undecided() bool {
+	return OnUndecided == Allow
+}
@@ -0,0 +1,119 @@
+package can
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDecide(t *testing.T) {
+	r, err := OpenFile("testdata/rbac.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if o := Decide(ctx, Local, r["admin"], "users", Create, nil); o != Allowed {
+		t.Fatalf("expected allowed, got %s", o)
+	}
+	if o := Decide(ctx, Local, r["user"], "users", Create, nil); o != Denied {
+		t.Fatalf("expected denied, got %s", o)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if o := Decide(canceled, Local, r["admin"], "users", Create, nil); o != Allowed {
+		t.Fatalf("expected a canceled check the role grants to be allowed, got %s", o)
+	}
+	if o := Decide(canceled, Local, r["user"], "users", Create, nil); o != Denied {
+		t.Fatalf("expected a canceled check the role doesn't grant to be denied, got %s", o)
+	}
+
+	slow := AuthorizerFunc(func(ctx context.Context, role Role, permission string, ability Ability, compare func() bool) bool {
+		<-ctx.Done()
+		return true
+	})
+	deadline, cancel := context.WithTimeout(ctx, time.Millisecond)
+	defer cancel()
+	if o := Decide(deadline, slow, r["admin"], "users", Create, nil); o != Undecided {
+		t.Fatalf("expected a timed out check to be undecided, got %s", o)
+	}
+
+	defer RegisterComparator("live", nil)
+	RegisterComparator("live", func(ctx context.Context) bool { return ctx.Err() == nil })
+	conditioned := Role{"users": {Abilities: map[Ability]struct{}{All: {}}, Conditions: []string{"live"}}}
+	if o := Decide(canceled, Local, conditioned, "users", Read, nil); o != Undecided || o.Allow() {
+		t.Fatalf("expected a cut off comparator to leave the check undecided, got %s", o)
+	}
+
+	OnUndecided = Allow
+	defer func() { OnUndecided = Deny }()
+	if !Undecided.Allow() || !Can(canceled, conditioned, "users", Read, nil) {
+		t.Fatal("expected undecided checks to fail open")
+	}
+	if Can(canceled, r["user"], "users", Create, nil) || Can(canceled, r["user"], "users", Delete, nil) {
+		t.Fatal("expected checks the role doesn't grant to stay denied")
+	}
+	if Chain(DefaultCan).Authorize(canceled, r["user"], "users", Create, nil) {
+		t.Fatal("expected chain to deny what its first function denies")
+	}
+	remote := CanFn(func(ctx context.Context, role *Role, compare func() bool, permission string, ability Ability) bool {
+		t.Fatal("expected the chain to stop once ctx is done")
+		return false
+	})
+	if !Chain(DefaultCan, remote).Authorize(canceled, r["admin"], "users", Create, nil) {
+		t.Fatal("expected chain to fail open for the functions it couldn't call")
+	}
+	if _, err := Impersonate(canceled, r["admin"], Subject{ID: "c1"}, r["user"]); !errors.Is(err, ErrImpersonationDenied) {
+		t.Fatalf("expected impersonation without a grant to be denied, got %v", err)
+	}
+
+	late, cancelLate := context.WithCancel(ctx)
+	denyLate := AuthorizerFunc(func(ctx context.Context, role Role, permission string, ability Ability, compare func() bool) bool {
+		cancelLate()
+		return false
+	})
+	if o := Decide(late, denyLate, r["admin"], "users", Create, nil); o != Denied {
+		t.Fatalf("expected a returned denial to stay denied, got %s", o)
+	}
+}
+
+func TestGuardUndecided(t *testing.T) {
+	r, err := OpenFile("testdata/rbac.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("expected the canceled check not to reach the webhook")
+	}))
+	defer srv.Close()
+
+	var got Decision
+	g := &Guard{
+		Authorizer: &Webhook{URL: srv.URL, FailOpen: true},
+		Audit:      func(ctx context.Context, d Decision) error { got = d; return nil },
+	}
+	ctx, cancel := context.WithCancel(WithRole(context.Background(), r["admin"]))
+	cancel()
+	req := httptest.NewRequest(http.MethodGet, "/users", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+
+	if g.Check(w, req, "users", Read) {
+		t.Fatal("expected canceled request to be denied")
+	}
+	if !got.Undecided || got.Allowed {
+		t.Fatalf("expected an undecided denial, got %+v", got)
+	}
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", w.Code)
+	}
+
+	g.Authorizer = nil
+	if !g.Check(httptest.NewRecorder(), req, "users", Read) || got.Undecided {
+		t.Fatalf("expected a canceled check decided by the role to be allowed, got %+v", got)
+	}
+}
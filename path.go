@@ -0,0 +1,235 @@
+package can
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// ErrInvalidPath is returned by NormalizePath for paths that can't be
+// turned into a permission safely.
+var ErrInvalidPath = errors.New("can: invalid path")
+
+// the problems of invalid paths, allocated once so the checks of valid
+// and invalid paths alike don't allocate
+var (
+	errUndecodable  = fmt.Errorf("%w: invalid percent-encoding", ErrInvalidPath)
+	errInvalidUTF8  = fmt.Errorf("%w: invalid utf-8", ErrInvalidPath)
+	errControl      = fmt.Errorf("%w: control or format character", ErrInvalidPath)
+	errDotSegment   = fmt.Errorf("%w: dot segment", ErrInvalidPath)
+	errEncodedSlash = fmt.Errorf("%w: segment decodes to a slash", ErrInvalidPath)
+	errOnlyParams   = fmt.Errorf("%w: every segment is a url param", ErrInvalidPath)
+)
+
+// PathOptions controls how NormalizePath builds a permission from a
+// path. The zero value builds the permissions of PermissionFromPath.
+type PathOptions struct {
+	// Params are url param values, percent-encoded or not, whose
+	// segments are dropped (see PermissionFromParams).
+	Params []string
+	// Prefixes are leading segments dropped before the permission is
+	// built, at most one of them. Nil drops v1, an empty slice none.
+	Prefixes []string
+	// Separator joins the segments. Defaults to Separator.
+	Separator string
+	// Index is the permission of the root path. Defaults to index.
+	Index string
+}
+
+// NormalizePath builds the permission of an escaped url path, the
+// normalization behind PermissionFromPath:
+//
+//   - empty segments of repeated, leading or trailing slashes are skipped
+//   - segments are percent-decoded and normalized to unicode NFC
+//   - a leading prefix such as v1 and url param segments are dropped
+//   - the segments are joined with the separator
+//
+// Paths that can't be decoded safely, that contain control characters
+// or dot segments, or whose segments decode to a "/" or "\" are
+// rejected, so encoded paths can't be used to reach another permission.
+// It never panics, whatever the path.
+//
+// escapedPath - the escaped path, such as r.URL.EscapedPath()
+//
+// opts - the options
+//
+// returns - the permission, or "" and an error wrapping ErrInvalidPath
+func NormalizePath(escapedPath string, opts PathOptions) (string, error) {
+	segments, err := pathSegments(escapedPath)
+	if err != nil {
+		return "", err
+	}
+
+	var param func(segment string) bool
+	if len(opts.Params) > 0 {
+		values := make(map[string]struct{}, len(opts.Params))
+		for _, v := range opts.Params {
+			if v == "" {
+				continue
+			}
+			if decoded, err := url.PathUnescape(v); err == nil {
+				v = decoded
+			}
+			values[norm.NFC.String(v)] = struct{}{}
+		}
+		param = func(segment string) bool {
+			_, ok := values[segment]
+			return ok
+		}
+	}
+
+	return permissionKey(segments, param, opts)
+}
+
+// pathSegments splits an escaped url path into decoded, NFC normalized
+// segments. Empty segments from repeated or trailing slashes are
+// skipped. It fails for paths that can't be decoded, aren't valid
+// utf-8, contain control characters or contain dot segments.
+func pathSegments(escaped string) ([]string, error) {
+	raw := strings.Split(escaped, "/")
+	segments := make([]string, 0, len(raw))
+	for _, s := range raw {
+		if s == "" {
+			continue
+		}
+
+		decoded, err := cleanSegment(s)
+		if err != nil {
+			return nil, err
+		}
+
+		segments = append(segments, decoded)
+	}
+
+	return segments, nil
+}
+
+// cleanSegment decodes and normalizes an escaped path segment, see
+// pathSegments. Segments of printable ascii without escapes are returned
+// as is.
+func cleanSegment(s string) (string, error) {
+	if plain(s) {
+		if s == "." || s == ".." {
+			return "", errDotSegment
+		}
+		return s, nil
+	}
+
+	decoded, err := url.PathUnescape(s)
+	if err != nil {
+		return "", errUndecodable
+	}
+	if !utf8.ValidString(decoded) {
+		return "", errInvalidUTF8
+	}
+
+	for _, c := range decoded {
+		if unicode.IsControl(c) || unicode.Is(unicode.Cf, c) {
+			return "", errControl
+		}
+	}
+
+	decoded = norm.NFC.String(decoded)
+	if decoded == "." || decoded == ".." {
+		return "", errDotSegment
+	}
+
+	return decoded, nil
+}
+
+// permissionKey joins path segments into a permission, skipping a
+// leading prefix and the segments param reports true for.
+func permissionKey(segments []string, param func(segment string) bool, opts PathOptions) (string, error) {
+	prefixes := opts.Prefixes
+	if prefixes == nil {
+		prefixes = []string{"v1"}
+	}
+	if len(segments) > 0 {
+		for _, prefix := range prefixes {
+			if segments[0] == prefix {
+				segments = segments[1:]
+				break
+			}
+		}
+	}
+
+	if len(segments) == 0 {
+		if opts.Index == "" {
+			return "index", nil
+		}
+		return opts.Index, nil
+	}
+
+	parts := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		if param != nil && param(segment) {
+			continue
+		}
+		if strings.ContainsAny(segment, "/\\") {
+			return "", errEncodedSlash
+		}
+		parts = append(parts, segment)
+	}
+
+	if len(parts) == 0 {
+		return "", errOnlyParams
+	}
+
+	separator := opts.Separator
+	if separator == "" {
+		separator = Separator
+	}
+	return strings.Join(parts, separator), nil
+}
+
+// plain reports whether s is printable ascii without escapes.
+func plain(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] <= ' ' || s[i] >= 0x7f || s[i] == '%' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// PermissionFromPattern builds a permission from the pattern of the
+// http.ServeMux route that matched r, the standard library counterpart
+// of PermissionFromPath. Wildcard segments are dropped, so a request
+// matched by "GET /users/{id}/books" becomes users_books, no matter the
+// id in the path.
+//
+// r - a standard http request routed by an http.ServeMux
+//
+// returns - a string representation of a permission, or "" if r wasn't
+// routed by a ServeMux. Can never allows the empty permission
+func PermissionFromPattern(r *http.Request) string {
+	return patternPermission(r.Pattern)
+}
+
+// patternPermission builds a permission from a ServeMux pattern, which
+// may start with a method and a host.
+func patternPermission(pattern string) string {
+	if i := strings.IndexAny(pattern, " \t"); i >= 0 {
+		pattern = strings.TrimLeft(pattern[i:], " \t")
+	}
+
+	i := strings.IndexByte(pattern, '/')
+	if i < 0 {
+		return ""
+	}
+
+	segments, err := pathSegments(strings.TrimSuffix(pattern[i:], "{$}"))
+	if err != nil {
+		return ""
+	}
+
+	permission, _ := permissionKey(segments, isRouteParam, PathOptions{})
+	return permission
+}
@@ -0,0 +1,157 @@
+package can
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+func TestPermissionFromParams(t *testing.T) {
+	tests := []struct {
+		path   string
+		params []string
+		want   string
+	}{
+		{"/", nil, "index"},
+		{"/v1", nil, "index"},
+		{"/users", nil, "users"},
+		{"/users/", nil, "users"},
+		{"//users//books/", nil, "users_books"},
+		{"/v1/users/42/books", []string{"42"}, "users_books"},
+		{"/users/1/books1", []string{"1"}, "users_books1"},
+		{"/users/b%6Fok", nil, "users_book"},
+		{"/caf%C3%A9", nil, "café"},
+		{"/cafe%CC%81", nil, "café"},
+		{"/users/a%2Fb", []string{"a%2Fb"}, "users"},
+		{"/x", nil, "x"},
+		{"/users/42", nil, "users_42"},
+		{"/42", []string{"42"}, ""},
+		{"/users%2Fadmin", nil, ""},
+		{"/users/%2e%2e/admin", nil, ""},
+		{"/users/../admin", nil, ""},
+		{"/users/%00", nil, ""},
+		{"/users/%0a", nil, ""},
+		{"/users/%E2%80%AEnimda", nil, ""},
+		{"/users/%ff", nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if got := PermissionFromParams(req, tt.params...); got != tt.want {
+				t.Fatalf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPermissionFromPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{"GET /users/{id}", "users"},
+		{"GET /users/{id}/books/{path...}", "users_books"},
+		{"POST example.com/v1/users", "users"},
+		{"/{$}", "index"},
+		{"GET /v1/{$}", "index"},
+		{"/books/search/{$}", "books_search"},
+		{"/{id}", ""},
+		{"/caf%C3%A9", "café"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Pattern = tt.pattern
+		if got := PermissionFromPattern(req); got != tt.want {
+			t.Errorf("%q: expected %q, got %q", tt.pattern, tt.want, got)
+		}
+	}
+}
+
+func TestCanEmptyPermission(t *testing.T) {
+	role := Role{"": Permission{Abilities: map[Ability]struct{}{All: {}}}}
+	if Can(context.Background(), role, "", Read, nil) {
+		t.Fatal("expected the empty permission to be denied")
+	}
+}
+
+func TestNormalizePath(t *testing.T) {
+	tests := []struct {
+		path string
+		opts PathOptions
+		want string
+		err  error
+	}{
+		{"", PathOptions{}, "index", nil},
+		{"/", PathOptions{Index: "root"}, "root", nil},
+		{"//", PathOptions{}, "index", nil},
+		{"a", PathOptions{}, "a", nil},
+		{"users//", PathOptions{}, "users", nil},
+		{"/v1/users", PathOptions{Prefixes: []string{}}, "v1_users", nil},
+		{"/api/v2/users", PathOptions{Prefixes: []string{"api"}}, "v2_users", nil},
+		{"/users/42/books", PathOptions{Params: []string{"42"}, Separator: "."}, "users.books", nil},
+		{"/users/%2e", PathOptions{}, "", errDotSegment},
+		{"/users/%zz", PathOptions{}, "", errUndecodable},
+		{"/users/%ff", PathOptions{}, "", errInvalidUTF8},
+		{"/users/%07", PathOptions{}, "", errControl},
+		{"/users%5Cadmin", PathOptions{}, "", errEncodedSlash},
+		{"/42", PathOptions{Params: []string{"42"}}, "", errOnlyParams},
+	}
+
+	for _, tt := range tests {
+		got, err := NormalizePath(tt.path, tt.opts)
+		if got != tt.want || !errors.Is(err, tt.err) || (tt.err != nil && !errors.Is(err, ErrInvalidPath)) {
+			t.Fatalf("NormalizePath(%q) = %q, %v, want %q, %v", tt.path, got, err, tt.want, tt.err)
+		}
+	}
+
+	defer func(a map[string]string) { Aliases = a }(Aliases)
+	Aliases = map[string]string{"members": "users"}
+	if got, _ := NormalizePath("/members/42", PathOptions{}); got != "members_42" {
+		t.Fatalf("expected aliases to be resolved by Can only, got %q", got)
+	}
+}
+
+func FuzzNormalizePath(f *testing.F) {
+	for _, seed := range []string{"", "/", "//", "a", "/v1", "/users/42", "//users//books/", "/users/b%6Fok", "/cafe%CC%81", "/users%2Fadmin", "/%2e%2e", "/%", "/%e2%80%ae", "/x%00y", "/users/42%"} {
+		f.Add(seed, "42")
+	}
+
+	f.Fuzz(func(t *testing.T, path, param string) {
+		opts := PathOptions{Params: []string{param}, Prefixes: []string{}, Separator: "/"}
+		got, err := NormalizePath(path, opts)
+		if err != nil {
+			if got != "" || !errors.Is(err, ErrInvalidPath) {
+				t.Fatalf("expected an empty permission and ErrInvalidPath, got %q, %v", got, err)
+			}
+			return
+		}
+
+		if got == "" || !utf8.ValidString(got) || !norm.NFC.IsNormalString(got) || strings.Contains(got, "\\") {
+			t.Fatalf("NormalizePath(%q) = %q, not a clean permission", path, got)
+		}
+		segments := strings.Split(got, "/")
+		for i, segment := range segments {
+			if segment == "" || segment == "." || segment == ".." || strings.ContainsFunc(segment, unicode.IsControl) {
+				t.Fatalf("NormalizePath(%q) = %q, unsafe segment %q", path, got, segment)
+			}
+			segments[i] = url.PathEscape(segment)
+		}
+
+		// a permission is the normalized form of its own path
+		again, err := NormalizePath("/"+strings.Join(segments, "/"), PathOptions{Prefixes: []string{}, Separator: "/"})
+		if err != nil || again != got {
+			t.Fatalf("NormalizePath(%q) = %q, normalizing it again gives %q, %v", path, got, again, err)
+		}
+	})
+}
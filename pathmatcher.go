@@ -0,0 +1,232 @@
+package can
+
+import (
+	"net/http"
+	"strings"
+)
+
+// PathMatcher maps request paths to the permission keys of a set of
+// roles with a trie, in time linear in the length of the path and
+// without the allocations of PermissionFromPath, for services with
+// thousands of routes. See NewPathMatcher.
+type PathMatcher struct {
+	root *pathNode
+}
+
+// pathNode is a node of the trie of a PathMatcher, its children are
+// keyed by the parts of permission keys split with Separator, param is
+// the child of a declared url param.
+type pathNode struct {
+	children   map[string]*pathNode
+	param      *pathNode
+	permission string
+}
+
+// NewPathMatcher compiles the permission keys of roles into a
+// PathMatcher. A path matches a key when its segments spell out the key,
+// or when it matches one of patterns whose permission is the key.
+// Patterns are paths or http.ServeMux patterns such as
+// "GET /accounts/{id}/invoices/{iid}/lines", their wildcard segments
+// match any single segment: /accounts/1/invoices/2/lines matches
+// accounts_invoices_lines, like PermissionFromPattern. Other segments
+// are never taken for url params. A leading v1 is skipped and the root
+// path matches index. Hierarchical keys such as projects/7 aren't built
+// from paths and are left out (see HierarchySeparator).
+//
+// The matcher is a snapshot, build a new one when the roles change.
+//
+// roles - the roles whose permissions are matched
+//
+// patterns - the routes declaring url params, patterns of keys no role
+// lists are left out
+//
+// returns - the matcher
+func NewPathMatcher(roles Roles, patterns ...string) *PathMatcher {
+	m := &PathMatcher{root: &pathNode{}}
+	keys := make(map[string]struct{})
+	for _, role := range roles {
+		for key := range role {
+			if HierarchySeparator != "" && strings.Contains(key, HierarchySeparator) {
+				continue
+			}
+			keys[key] = struct{}{}
+			m.root.insert(keyParts(key), key)
+		}
+	}
+
+	for _, pattern := range patterns {
+		key := patternPermission(pattern)
+		if _, ok := keys[key]; !ok {
+			continue
+		}
+		m.insertPattern(pattern, key)
+	}
+
+	return m
+}
+
+// insert adds the parts of a permission key below n.
+func (n *pathNode) insert(parts []string, key string) {
+	for _, part := range parts {
+		n = n.child(part)
+	}
+	n.permission = key
+}
+
+// child returns the child of n for part, adding it if needed.
+func (n *pathNode) child(part string) *pathNode {
+	child, ok := n.children[part]
+	if !ok {
+		if n.children == nil {
+			n.children = make(map[string]*pathNode)
+		}
+		child = &pathNode{}
+		n.children[part] = child
+	}
+
+	return child
+}
+
+// insertPattern adds a route pattern of key to the trie, parsed like
+// patternPermission.
+func (m *PathMatcher) insertPattern(pattern, key string) {
+	if i := strings.IndexAny(pattern, " \t"); i >= 0 {
+		pattern = strings.TrimLeft(pattern[i:], " \t")
+	}
+	segments, err := pathSegments(strings.TrimSuffix(pattern[strings.IndexByte(pattern, '/'):], "{$}"))
+	if err != nil {
+		return
+	}
+	if len(segments) > 0 && segments[0] == "v1" {
+		segments = segments[1:]
+	}
+
+	n := m.root
+	for _, segment := range segments {
+		if !isRouteParam(segment) {
+			for _, part := range keyParts(segment) {
+				n = n.child(part)
+			}
+			continue
+		}
+		if n.param == nil {
+			n.param = &pathNode{}
+		}
+		n = n.param
+	}
+	n.permission = key
+}
+
+// keyParts splits a permission key into its parts.
+func keyParts(key string) []string {
+	if Separator == "" {
+		return []string{key}
+	}
+
+	return strings.Split(key, Separator)
+}
+
+// walk follows the parts of segment from n, returning nil if they aren't
+// in the trie.
+func (n *pathNode) walk(segment string) *pathNode {
+	if Separator == "" {
+		return n.children[segment]
+	}
+
+	for n != nil {
+		i := strings.Index(segment, Separator)
+		if i < 0 {
+			return n.children[segment]
+		}
+		n, segment = n.children[segment[:i]], segment[i+len(Separator):]
+	}
+
+	return nil
+}
+
+// Match finds the permission and ability of a request. Paths that don't
+// match a key or one of the patterns of the matcher, or that
+// PermissionFromPath rejects, aren't matched.
+//
+// method - the http method of the request, see BuildFromMethod
+//
+// path - the escaped path of the request
+//
+// returns - the permission, the ability and whether the path matched
+func (m *PathMatcher) Match(method, path string) (string, Ability, bool) {
+	segment, rest, ok := nextSegment(path)
+	if ok && segment == "v1" {
+		path = rest
+		segment, _, ok = nextSegment(path)
+	}
+	if !ok {
+		return "", None, false
+	}
+
+	n := m.root.children["index"]
+	if segment != "" {
+		if n, ok = m.root.match(path); !ok {
+			return "", None, false
+		}
+	}
+	if n == nil || n.permission == "" {
+		return "", None, false
+	}
+
+	return n.permission, BuildFromMethod(method), true
+}
+
+// match follows the segments of path from n, trying the children of a
+// segment before a url param. It reports false for paths
+// PermissionFromPath rejects.
+func (n *pathNode) match(path string) (*pathNode, bool) {
+	segment, rest, ok := nextSegment(path)
+	if !ok {
+		return nil, false
+	}
+	if segment == "" {
+		return n, true
+	}
+
+	if next := n.walk(segment); next != nil {
+		if found, ok := next.match(rest); !ok || (found != nil && found.permission != "") {
+			return found, ok
+		}
+	}
+	if n.param != nil {
+		return n.param.match(rest)
+	}
+
+	return nil, true
+}
+
+// nextSegment splits the first non empty segment off an escaped path,
+// decoded with cleanSegment. The segment is "" at the end of the path
+// and ok is false if the segment is rejected.
+func nextSegment(path string) (segment, rest string, ok bool) {
+	for path != "" {
+		segment, rest = path, ""
+		if i := strings.IndexByte(path, '/'); i >= 0 {
+			segment, rest = path[:i], path[i+1:]
+		}
+		if segment != "" {
+			segment, err := cleanSegment(segment)
+			if err != nil || strings.ContainsAny(segment, "/\\") {
+				return "", "", false
+			}
+			return segment, rest, true
+		}
+		path = rest
+	}
+
+	return "", "", true
+}
+
+// Permission returns the permission of r, or "" if its path doesn't
+// match, so it can be used as the Permission of a Guard. Unlike
+// PermissionFromPath, paths of permissions no role lists are denied
+// even when Unlisted allows them.
+func (m *PathMatcher) Permission(r *http.Request) string {
+	permission, _, _ := m.Match(r.Method, r.URL.EscapedPath())
+	return permission
+}
@@ -0,0 +1,110 @@
+package can
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPathMatcher(t *testing.T) {
+	r, err := ConfigErr(DiskRoles{
+		"user": DiskRole{
+			"index":      DiskPermission{Abilities: []string{"read"}},
+			"users":      DiskPermission{Abilities: []string{"read"}, Routes: []string{"{id}/books"}},
+			"accounts":   DiskPermission{Abilities: []string{"read"}, Routes: []string{"{id}/invoices/{iid}/lines"}},
+			"projects/7": DiskPermission{Abilities: []string{"read"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewPathMatcher(r, "/users/{id}", "GET /users/{id}/books", "/accounts/{id}/invoices/{iid}/lines", "/unknown/{id}")
+
+	for _, tc := range []struct {
+		method, path, permission string
+		ability                  Ability
+	}{
+		{http.MethodGet, "/", "index", Read},
+		{http.MethodGet, "/v1", "index", Read},
+		{http.MethodGet, "/users", "users", Read},
+		{http.MethodDelete, "/v1/users/42/", "users", Delete},
+		{http.MethodPost, "/users/42/books", "users_books", Create},
+		{http.MethodGet, "/users_books", "users_books", Read},
+		{http.MethodGet, "/accounts/1/invoices/2/lines", "accounts_invoices_lines", Read},
+		{http.MethodGet, "/accounts/%C3%A9/invoices/2/lines", "accounts_invoices_lines", Read},
+	} {
+		permission, ability, ok := m.Match(tc.method, tc.path)
+		if !ok || permission != tc.permission || ability != tc.ability {
+			t.Fatalf("%s %s: expected %s %s, got %q %s %v", tc.method, tc.path, tc.permission, tc.ability, permission, ability, ok)
+		}
+	}
+
+	for _, path := range []string{
+		"/42",
+		"/users/42/43",
+		"/users/anything/else",
+		"/users/42/books/43",
+		"/unknown/42",
+		"/accounts/1/invoices",
+		"/accounts/invoices/1/lines",
+		"/accounts/1/invoices/lines",
+		"/projects/7",
+		"/users/%2e%2e/admin",
+		"/users/a%2Fb/books",
+		"/users/%ZZ",
+	} {
+		if permission, _, ok := m.Match(http.MethodGet, path); ok {
+			t.Fatalf("expected %s not to match, got %q", path, permission)
+		}
+	}
+
+	g := &Guard{DefaultRole: r["user"], Permission: m.Permission, Compare: func(r *http.Request) func() bool { return func() bool { return true } }}
+	h := g.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/accounts/1/invoices/2/lines", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected the matched permission to be allowed, got %d", w.Code)
+	}
+}
+
+// benchmarkRoles returns a role with n resources of two nested routes.
+func benchmarkRoles(n int) Roles {
+	role := make(DiskRole, n)
+	for i := 0; i < n; i++ {
+		role[fmt.Sprintf("resource%d", i)] = DiskPermission{Abilities: []string{"read"}, Routes: []string{"{id}/items", "{id}/items/{iid}/lines"}}
+	}
+
+	r, err := ConfigErr(DiskRoles{"user": role})
+	if err != nil {
+		panic(err)
+	}
+
+	return r
+}
+
+func BenchmarkPathMatcher(b *testing.B) {
+	patterns := make([]string, 0, 2000)
+	for i := 0; i < 1000; i++ {
+		patterns = append(patterns, fmt.Sprintf("/resource%d/{id}/items", i), fmt.Sprintf("/resource%d/{id}/items/{iid}/lines", i))
+	}
+	m := NewPathMatcher(benchmarkRoles(1000), patterns...)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, ok := m.Match(http.MethodGet, "/v1/resource500/42/items/7/lines"); !ok {
+			b.Fatal("expected a match")
+		}
+	}
+}
+
+func BenchmarkPermissionFromParams(b *testing.B) {
+	req := httptest.NewRequest(http.MethodGet, "/v1/resource500/42/items/7/lines", nil)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if PermissionFromParams(req, "42", "7") == "" {
+			b.Fatal("expected a permission")
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package can
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrInvalidExpectation is returned for policy tests that can't be parsed.
+var ErrInvalidExpectation = errors.New("can: invalid expectation")
+
+// PolicyTest is a test case of a policy test file. Expect reads as a
+// sentence, "<role> can|cannot <ability> <permission>":
+//
+//	# rbac_test.yml
+//	- expect: user can read users
+//	  compare: true
+//	- expect: user cannot create users
+//	- admin can delete users/42
+//
+// A test without options may be written as the sentence alone. Run the
+// tests of a file with go test with cantest.RunPolicyTests.
+type PolicyTest struct {
+	Expect string `yaml:"expect"`
+	// Compare is the result of the compare function of the check.
+	Compare bool `yaml:"compare,omitempty"`
+	// Labels are the labels of the check (see WithLabels).
+	Labels Labels `yaml:"labels,omitempty"`
+}
+
+// UnmarshalYAML implement the yaml Unmarshaler interface, so a test can
+// be written as its sentence alone.
+func (p *PolicyTest) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*p = PolicyTest{Expect: value.Value}
+		return nil
+	}
+
+	type plain PolicyTest
+	return value.Decode((*plain)(p))
+}
+
+// Scenario converts the test into the Scenario it expects.
+func (p PolicyTest) Scenario() (Scenario, error) {
+	words := strings.Fields(p.Expect)
+	if len(words) != 4 || (words[1] != "can" && words[1] != "cannot") {
+		return Scenario{}, fmt.Errorf("%w: %q, want \"<role> can|cannot <ability> <permission>\"", ErrInvalidExpectation, p.Expect)
+	}
+
+	ability := StringToAbility(words[2])
+	if ability == None {
+		return Scenario{}, fmt.Errorf("%w: %q: unknown ability %q", ErrInvalidExpectation, p.Expect, words[2])
+	}
+
+	return Scenario{
+		Name:       p.Expect,
+		Role:       words[0],
+		Permission: words[3],
+		Ability:    ability,
+		Compare:    p.Compare,
+		Labels:     p.Labels,
+		Allowed:    words[1] == "can",
+	}, nil
+}
+
+// OpenPolicyTests reads the scenarios of a yaml policy test file.
+// filename - yaml encoded list of PolicyTest
+//
+// returns - the scenarios and an error
+func OpenPolicyTests(filename string) ([]Scenario, error) {
+	f, err := os.OpenFile(filename, os.O_RDONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	s, err := DecodePolicyTests(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	return s, nil
+}
+
+// DecodePolicyTests reads the scenarios of a yaml policy test file from
+// r. Every invalid test is reported.
+func DecodePolicyTests(r io.Reader) ([]Scenario, error) {
+	var tests []PolicyTest
+	if err := yaml.NewDecoder(r).Decode(&tests); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	var (
+		scenarios = make([]Scenario, 0, len(tests))
+		errs      []error
+	)
+	for _, test := range tests {
+		s, err := test.Scenario()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		scenarios = append(scenarios, s)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return scenarios, nil
+}
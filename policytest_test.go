@@ -0,0 +1,28 @@
+package can
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDecodePolicyTests(t *testing.T) {
+	scenarios, err := DecodePolicyTests(strings.NewReader(`
+- expect: user can update users
+  compare: true
+  labels: {environment: staging}
+- admin cannot delete users
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Scenario{Name: "user can update users", Role: "user", Permission: "users", Ability: Update, Compare: true, Labels: Labels{EnvironmentLabel: "staging"}, Allowed: true}
+	if len(scenarios) != 2 || scenarios[0].String() != want.String() || scenarios[0].Labels[EnvironmentLabel] != "staging" || scenarios[1].Allowed {
+		t.Fatalf("unexpected scenarios %+v", scenarios)
+	}
+
+	_, err = DecodePolicyTests(strings.NewReader("- user may read users\n- user can fly users\n- user can read\n"))
+	if !errors.Is(err, ErrInvalidExpectation) || strings.Count(err.Error(), "\n") != 2 {
+		t.Fatalf("expected every invalid test to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,239 @@
+package can
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInvalidQuota is returned when a role file declares a quota that
+// can't be parsed.
+var ErrInvalidQuota = errors.New("can: invalid quota")
+
+// Quota limits how many times a subject may use an ability of a
+// permission per window, such as creating 5 exports a day. Windows are
+// fixed: they start with the first use and the count starts over once
+// they end.
+type Quota struct {
+	Limit int64
+	Per   time.Duration
+}
+
+// quotaUnits are the named windows of ParseQuota.
+var quotaUnits = map[string]time.Duration{
+	"second": time.Second,
+	"minute": time.Minute,
+	"hour":   time.Hour,
+	"day":    24 * time.Hour,
+}
+
+// ParseQuota parses a quota written as <limit>/<window>. The window is
+// second, minute, hour, day or a duration such as 15m, as in 5/day or
+// 100/15m.
+func ParseQuota(s string) (Quota, error) {
+	limit, per, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok {
+		return Quota{}, fmt.Errorf("%w %q, want <limit>/<window>", ErrInvalidQuota, s)
+	}
+
+	var q Quota
+	var err error
+	if q.Limit, err = strconv.ParseInt(strings.TrimSpace(limit), 10, 64); err != nil || q.Limit < 0 {
+		return Quota{}, fmt.Errorf("%w %q: limit %q isn't a count", ErrInvalidQuota, s, limit)
+	}
+	per = strings.TrimSpace(per)
+	if q.Per, ok = quotaUnits[per]; !ok {
+		if q.Per, err = time.ParseDuration(per); err != nil || q.Per <= 0 {
+			return Quota{}, fmt.Errorf("%w %q: window %q isn't second, minute, hour, day or a positive duration", ErrInvalidQuota, s, per)
+		}
+	}
+
+	return q, nil
+}
+
+// String formats the quota as ParseQuota reads it.
+func (q Quota) String() string {
+	for name, d := range quotaUnits {
+		if d == q.Per {
+			return fmt.Sprintf("%d/%s", q.Limit, name)
+		}
+	}
+
+	return fmt.Sprintf("%d/%s", q.Limit, q.Per)
+}
+
+// MarshalText implements the encoding TextMarshaler interface.
+func (q Quota) MarshalText() ([]byte, error) {
+	return []byte(q.String()), nil
+}
+
+// UnmarshalText implements the encoding TextUnmarshaler interface.
+func (q *Quota) UnmarshalText(text []byte) error {
+	parsed, err := ParseQuota(string(text))
+	if err != nil {
+		return err
+	}
+	*q = parsed
+
+	return nil
+}
+
+// QuotaCounter counts the uses of quotas (see Permission.Quotas). It is
+// implemented by MemoryCounter and Redis, other backends only need to
+// keep a counter per key that starts over every window.
+type QuotaCounter interface {
+	// Incr adds n to the counter of key and returns its new value. The
+	// counter expires window after it was created. An n of 0 reads the
+	// counter without using the quota.
+	Incr(ctx context.Context, key string, n int64, window time.Duration) (int64, error)
+}
+
+// DefaultQuotaCounter counts the quotas Can enforces. The default
+// MemoryCounter only counts the checks of this instance, use Redis to
+// share quotas between instances.
+var DefaultQuotaCounter QuotaCounter = &MemoryCounter{}
+
+// MemoryCounter is a QuotaCounter keeping its counters in memory. The
+// zero value is ready to use.
+type MemoryCounter struct {
+	mu        sync.Mutex
+	counters  map[string]*quotaWindow
+	lastSweep time.Time
+}
+
+type quotaWindow struct {
+	count   int64
+	expires time.Time
+}
+
+// Incr implements the QuotaCounter interface.
+func (m *MemoryCounter) Incr(ctx context.Context, key string, n int64, window time.Duration) (int64, error) {
+	now := Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.counters == nil {
+		m.counters = make(map[string]*quotaWindow)
+	}
+	m.sweep(now, window)
+
+	w, ok := m.counters[key]
+	if !ok || !now.Before(w.expires) {
+		w = &quotaWindow{expires: now.Add(window)}
+		m.counters[key] = w
+	}
+	w.count += n
+
+	return w.count, nil
+}
+
+// sweep drops expired counters at most once per window.
+func (m *MemoryCounter) sweep(now time.Time, window time.Duration) {
+	if now.Sub(m.lastSweep) < window {
+		return
+	}
+	m.lastSweep = now
+
+	for k, w := range m.counters {
+		if !now.Before(w.expires) {
+			delete(m.counters, k)
+		}
+	}
+}
+
+// QuotaWarning is the share of a quota after which allowed checks are
+// flagged, so clients can warn users before the quota is used up. A
+// Guard reports the quota in Decision.QuotaWarning and the
+// QuotaWarningHeader of the response. Defaults to 80%, 0 disables the
+// warnings.
+var QuotaWarning = 0.8
+
+// QuotaWarningHeader is the response header a Guard sets for requests
+// past the QuotaWarning of a quota, such as "4 of 5/day".
+const QuotaWarningHeader = "Can-Quota-Warning"
+
+// QuotaUsage reports how much of a quota a subject has used.
+type QuotaUsage struct {
+	Quota Quota `json:"quota"`
+	Used  int64 `json:"used"`
+}
+
+// String formats the usage as its QuotaWarningHeader, e.g. 4 of 5/day.
+func (u QuotaUsage) String() string {
+	return fmt.Sprintf("%d of %s", u.Used, u.Quota)
+}
+
+// quotaUsageKey stores a *QuotaUsage in the ctx of a Guard's checks,
+// set by the checks past the QuotaWarning of a quota.
+type quotaUsageKey struct{}
+
+// replayKey marks the ctx of a replayed Create, see Idempotency.
+type replayKey struct{}
+
+// withinQuota uses the quota of ability on a permission, if it has one.
+// A quota on All applies to every ability. Checks of a replayed Create
+// don't use the quota again, and peeking checks only read it. The check
+// is denied when the counter fails.
+func withinQuota(ctx context.Context, perm Permission, key string, ability Ability, peek bool, trace *Explanation) bool {
+	declared := ability
+	q, ok := perm.Quotas[ability]
+	if !ok {
+		if q, ok = perm.Quotas[All]; !ok {
+			return true
+		}
+		declared = All
+	}
+	if replayed, _ := ctx.Value(replayKey{}).(bool); replayed {
+		if trace != nil {
+			trace.step("replayed create, quota %s not used again", q)
+		}
+		return true
+	}
+
+	var n int64 = 1
+	if peek {
+		n = 0
+	}
+	count, err := DefaultQuotaCounter.Incr(ctx, quotaKey(ctx, key, declared), n, q.Per)
+	if err != nil {
+		if trace != nil {
+			trace.step("quota %s could not be counted: %v", q, err)
+		}
+		return false
+	}
+	if used := count - n; used >= q.Limit {
+		if trace != nil {
+			trace.step("quota %s used up", q)
+		}
+		return false
+	}
+	if trace != nil {
+		trace.step("quota %s used %d times", q, count)
+	}
+
+	if QuotaWarning > 0 && float64(count) >= QuotaWarning*float64(q.Limit) {
+		if trace != nil {
+			trace.step("quota %s is past its warning threshold of %g%%", q, QuotaWarning*100)
+		}
+		if usage, ok := ctx.Value(quotaUsageKey{}).(*QuotaUsage); ok {
+			*usage = QuotaUsage{Quota: q, Used: count}
+		}
+	}
+
+	return true
+}
+
+// quotaKey returns the counter key of the subject in ctx for the quota
+// declared on ability of a permission, so a quota on All counts every
+// ability. Checks without a subject share a counter.
+func quotaKey(ctx context.Context, permission string, ability Ability) string {
+	sub, _ := SubjectFrom(ctx)
+	tenant, _ := TenantFrom(ctx)
+
+	return tenant + "\x00" + sub.ID + "\x00" + permission + "\x00" + ability.text()
+}
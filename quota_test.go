@@ -0,0 +1,194 @@
+package can
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseQuota(t *testing.T) {
+	for s, want := range map[string]Quota{
+		"5/day":     {5, 24 * time.Hour},
+		" 100/15m ": {100, 15 * time.Minute},
+		"0/second":  {0, time.Second},
+	} {
+		q, err := ParseQuota(s)
+		if err != nil || q != want {
+			t.Fatalf("expected %q to be %v, got %v %v", s, want, q, err)
+		}
+		if back, _ := ParseQuota(q.String()); back != q {
+			t.Fatalf("expected %v to round trip, got %v", q, back)
+		}
+	}
+
+	for _, s := range []string{"5", "x/day", "-1/day", "5/fortnight", "5/-1h"} {
+		if _, err := ParseQuota(s); !errors.Is(err, ErrInvalidQuota) {
+			t.Fatalf("expected %q to be invalid, got %v", s, err)
+		}
+	}
+}
+
+func TestQuota(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	defer func(n func() time.Time, c QuotaCounter) { Now, DefaultQuotaCounter = n, c }(Now, DefaultQuotaCounter)
+	Now = func() time.Time { return now }
+	DefaultQuotaCounter = &MemoryCounter{}
+
+	roles, err := Decode(strings.NewReader(`
+user:
+  exports:
+    abilities: [read, create]
+    quotas:
+      create: 2/day
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	role := roles["user"]
+	if q := role["exports"].Quotas[Create]; q != (Quota{2, 24 * time.Hour}) {
+		t.Fatalf("expected a quota of 2/day, got %v", q)
+	}
+	if got := roles.Disk()["user"]["exports"].Quotas["create"]; got != "2/day" {
+		t.Fatalf("expected the quota to be written back, got %q", got)
+	}
+
+	ctx := WithSubject(context.Background(), Subject{ID: "u1"})
+	yes := func() bool { return true }
+	if e := Explain(ctx, role, "exports", Create, yes); !e.Allowed {
+		t.Fatalf("expected the explanation to be allowed, got %s", e)
+	}
+	for i := 0; i < 2; i++ {
+		if !Can(ctx, role, "exports", Create, yes) {
+			t.Fatalf("expected create %d to be within the quota", i+1)
+		}
+	}
+	if Can(ctx, role, "exports", Create, yes) {
+		t.Fatal("expected the third create to exceed the quota")
+	}
+	if e := Explain(ctx, role, "exports", Create, yes); e.Allowed || !strings.Contains(e.String(), "used up") {
+		t.Fatalf("expected the explanation to report the quota, got %s", e)
+	}
+	if !Can(ctx, role, "exports", Read, yes) {
+		t.Fatal("expected abilities without a quota to be unlimited")
+	}
+	if !Can(WithSubject(context.Background(), Subject{ID: "u2"}), role, "exports", Create, yes) {
+		t.Fatal("expected quotas to be counted per subject")
+	}
+
+	now = now.Add(24 * time.Hour)
+	if !Can(ctx, role, "exports", Create, yes) {
+		t.Fatal("expected the quota to start over")
+	}
+}
+
+func TestQuotaAll(t *testing.T) {
+	defer func(c QuotaCounter) { DefaultQuotaCounter = c }(DefaultQuotaCounter)
+	DefaultQuotaCounter = &MemoryCounter{}
+
+	role := Role{"exports": {
+		Abilities: map[Ability]struct{}{All: {}},
+		Quotas:    map[Ability]Quota{All: {2, time.Hour}},
+	}}
+	ctx := WithSubject(context.Background(), Subject{ID: "u1"})
+	if !Can(ctx, role, "exports", Read, nil) || !Can(ctx, role, "exports", Update, nil) {
+		t.Fatal("expected the first two checks to be within the quota")
+	}
+	if Can(ctx, role, "exports", Delete, nil) {
+		t.Fatal("expected a quota on all to count every ability")
+	}
+}
+
+func TestQuotaReplay(t *testing.T) {
+	defer func(c QuotaCounter) { DefaultQuotaCounter = c }(DefaultQuotaCounter)
+	DefaultQuotaCounter = &MemoryCounter{}
+
+	role := NewRole().Allow("exports", All).Build()
+	p := role["exports"]
+	p.Quotas = map[Ability]Quota{Create: {1, time.Hour}}
+	role["exports"] = p
+
+	var decisions []Decision
+	g := &Guard{
+		DefaultRole: role,
+		Idempotency: &Idempotency{},
+		Audit:       func(ctx context.Context, d Decision) error { decisions = append(decisions, d); return nil },
+	}
+	create := func(key string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/exports", nil)
+		req.Header.Set(IdempotencyHeader, key)
+		return req.WithContext(WithSubject(req.Context(), Subject{ID: "u1"}))
+	}
+
+	for _, key := range []string{"k1", "k1"} {
+		if !g.Check(httptest.NewRecorder(), create(key), "exports", Create) {
+			t.Fatal("expected a retried create not to use the quota again")
+		}
+	}
+	if !decisions[1].Replay {
+		t.Fatalf("expected the retry to be a replay, got %+v", decisions[1])
+	}
+	if g.Check(httptest.NewRecorder(), create("k2"), "exports", Create) {
+		t.Fatal("expected a new create to exceed the quota")
+	}
+}
+
+func TestQuotaWarning(t *testing.T) {
+	defer func(c QuotaCounter, w float64) { DefaultQuotaCounter, QuotaWarning = c, w }(DefaultQuotaCounter, QuotaWarning)
+	DefaultQuotaCounter = &MemoryCounter{}
+
+	role := NewRole().Allow("exports", All).Build()
+	p := role["exports"]
+	p.Quotas = map[Ability]Quota{Create: {5, 24 * time.Hour}}
+	role["exports"] = p
+
+	var decisions []Decision
+	g := &Guard{DefaultRole: role, Audit: func(ctx context.Context, d Decision) error { decisions = append(decisions, d); return nil }}
+	var headers []string
+	for i := 0; i < 6; i++ {
+		w := httptest.NewRecorder()
+		g.Check(w, httptest.NewRequest(http.MethodPost, "/exports", nil), "exports", Create)
+		headers = append(headers, w.Header().Get(QuotaWarningHeader))
+	}
+
+	if got := strings.Join(headers, ","); got != ",,,4 of 5/day,5 of 5/day," {
+		t.Fatalf("expected warnings from the 4th create on, got %q", got)
+	}
+	if d := decisions[3]; d.QuotaWarning == nil || d.QuotaWarning.Used != 4 || d.Event().QuotaWarning != "4 of 5/day" {
+		t.Fatalf("expected the usage in the decision, got %+v", d.QuotaWarning)
+	}
+	if decisions[2].QuotaWarning != nil || decisions[5].QuotaWarning != nil || decisions[5].Allowed {
+		t.Fatalf("expected no warning below the threshold or once denied, got %+v %+v", decisions[2], decisions[5])
+	}
+
+	QuotaWarning = 0
+	DefaultQuotaCounter = &MemoryCounter{}
+	decisions = nil
+	for i := 0; i < 5; i++ {
+		g.Check(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/exports", nil), "exports", Create)
+	}
+	if decisions[4].QuotaWarning != nil {
+		t.Fatal("expected warnings to be disabled")
+	}
+}
+
+func TestQuotaStrict(t *testing.T) {
+	_, err := Decode(strings.NewReader(`
+user:
+  exports:
+    abilities: [create]
+    quotas:
+      crate: 5/day
+      create: 5/fortnight
+`))
+	if !errors.Is(err, ErrUnknownAbility) || !errors.Is(err, ErrInvalidQuota) {
+		t.Fatalf("expected the unknown ability and invalid quota to be reported, got %v", err)
+	}
+	var pe *PositionError
+	if !errors.As(err, &pe) || pe.Line != 6 {
+		t.Fatalf("expected the position of the unknown ability, got %v", err)
+	}
+}
@@ -0,0 +1,205 @@
+package can
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Redis is a QuotaCounter backed by a Redis server, so every instance
+// counts the same quotas. It speaks the Redis protocol directly and
+// keeps a few connections open between checks.
+type Redis struct {
+	// Addr is the host:port of the server, e.g. 127.0.0.1:6379
+	Addr string
+	// Password is sent with AUTH on new connections when not empty.
+	Password string
+	// Prefix is prepended to every key. Defaults to "can:quota:".
+	Prefix string
+	// Dial opens connections. Defaults to a net.Dialer.
+	Dial func(ctx context.Context, network, addr string) (net.Conn, error)
+	// MaxIdle is the number of connections kept open. Defaults to 4.
+	MaxIdle int
+
+	mu   sync.Mutex
+	idle []*redisConn
+}
+
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// incrScript increments a counter, setting its expiry when it has none.
+const incrScript = `local n = redis.call('INCRBY', KEYS[1], ARGV[1])
+if redis.call('PTTL', KEYS[1]) < 0 then redis.call('PEXPIRE', KEYS[1], ARGV[2]) end
+return n`
+
+// Incr implements the QuotaCounter interface.
+func (c *Redis) Incr(ctx context.Context, key string, n int64, window time.Duration) (int64, error) {
+	prefix := c.Prefix
+	if prefix == "" {
+		prefix = "can:quota:"
+	}
+
+	reply, err := c.do(ctx, "EVAL", incrScript, "1", prefix+key, strconv.FormatInt(n, 10), strconv.FormatInt(window.Milliseconds(), 10))
+	if err != nil {
+		return 0, err
+	}
+	count, ok := reply.(int64)
+	if !ok {
+		return 0, fmt.Errorf("can: redis returned %v, want an integer", reply)
+	}
+
+	return count, nil
+}
+
+// do sends a command and reads its reply, on an idle connection if there
+// is one. Connections are only reused after a complete exchange.
+func (c *Redis) do(ctx context.Context, args ...string) (any, error) {
+	conn, err := c.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// interrupt the exchange when ctx is done
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Unix(1, 0)) })
+	reply, err := conn.exchange(args...)
+	if !stop() || err != nil {
+		conn.Close()
+		if err == nil {
+			err = ctx.Err()
+		}
+		return nil, err
+	}
+
+	c.release(conn)
+	if e, ok := reply.(redisError); ok {
+		return nil, e
+	}
+
+	return reply, nil
+}
+
+// conn returns an idle connection, or a new authenticated one.
+func (c *Redis) conn(ctx context.Context) (*redisConn, error) {
+	c.mu.Lock()
+	if n := len(c.idle); n > 0 {
+		conn := c.idle[n-1]
+		c.idle = c.idle[:n-1]
+		c.mu.Unlock()
+		return conn, nil
+	}
+	c.mu.Unlock()
+
+	dial := c.Dial
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	nc, err := dial(ctx, "tcp", c.Addr)
+	if err != nil {
+		return nil, err
+	}
+	conn := &redisConn{Conn: nc, r: bufio.NewReader(nc)}
+
+	if c.Password != "" {
+		reply, err := conn.exchange("AUTH", c.Password)
+		if err == nil {
+			if e, ok := reply.(redisError); ok {
+				err = e
+			}
+		}
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
+
+// release keeps conn for later commands or closes it.
+func (c *Redis) release(conn *redisConn) {
+	max := c.MaxIdle
+	if max <= 0 {
+		max = 4
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.idle) >= max {
+		conn.Close()
+		return
+	}
+	c.idle = append(c.idle, conn)
+}
+
+// redisError is an error reply, which leaves the connection usable.
+type redisError string
+
+func (e redisError) Error() string {
+	return "can: redis: " + string(e)
+}
+
+// exchange writes a command as an array of bulk strings and reads the reply.
+func (conn *redisConn) exchange(args ...string) (any, error) {
+	b := fmt.Appendf(nil, "*%d\r\n", len(args))
+	for _, arg := range args {
+		b = fmt.Appendf(b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := conn.Write(b); err != nil {
+		return nil, err
+	}
+
+	return conn.reply()
+}
+
+// errRedisProtocol is returned for replies that can't be parsed.
+var errRedisProtocol = errors.New("can: redis: invalid reply")
+
+// reply reads a simple string, error, integer or bulk string reply.
+func (conn *redisConn) reply() (any, error) {
+	line, err := conn.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, errRedisProtocol
+	}
+	kind, value := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return value, nil
+	case '-':
+		return redisError(value), nil
+	case ':':
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, errRedisProtocol
+		}
+		return n, nil
+	case '$':
+		size, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, errRedisProtocol
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(conn.r, data); err != nil {
+			return nil, err
+		}
+		return string(data[:size]), nil
+	}
+
+	return nil, errRedisProtocol
+}
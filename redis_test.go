@@ -0,0 +1,118 @@
+package can
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeRedis serves the commands Redis sends, keeping counters in memory.
+func fakeRedis(t *testing.T, password string) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	counters := make(map[string]int64)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				authed := password == ""
+				for {
+					args, err := readCommand(r)
+					if err != nil {
+						return
+					}
+					switch {
+					case args[0] == "AUTH" && args[1] == password:
+						authed = true
+						io.WriteString(conn, "+OK\r\n")
+					case args[0] == "AUTH":
+						io.WriteString(conn, "-WRONGPASS invalid password\r\n")
+					case !authed:
+						io.WriteString(conn, "-NOAUTH Authentication required\r\n")
+					case args[0] == "EVAL" && args[1] == incrScript:
+						n, _ := strconv.ParseInt(args[4], 10, 64)
+						counters[args[3]] += n
+						fmt.Fprintf(conn, ":%d\r\n", counters[args[3]])
+					default:
+						io.WriteString(conn, "-ERR unknown command\r\n")
+					}
+				}
+			}()
+		}
+	}()
+
+	return l.Addr().String()
+}
+
+// readCommand reads an array of bulk strings.
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, n)
+	for i := range args {
+		if line, err = r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		b := make([]byte, size+2)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		args[i] = string(b[:size])
+	}
+
+	return args, nil
+}
+
+func TestRedis(t *testing.T) {
+	c := &Redis{Addr: fakeRedis(t, "secret"), Password: "secret"}
+	ctx := context.Background()
+
+	for want := int64(1); want <= 3; want++ {
+		n, err := c.Incr(ctx, "u1", 1, time.Hour)
+		if err != nil || n != want {
+			t.Fatalf("expected count %d, got %d %v", want, n, err)
+		}
+	}
+	if n, err := c.Incr(ctx, "u1", 0, time.Hour); err != nil || n != 3 {
+		t.Fatalf("expected an n of 0 to read the count, got %d %v", n, err)
+	}
+	if len(c.idle) != 1 {
+		t.Fatalf("expected the connection to be reused, got %d idle", len(c.idle))
+	}
+
+	wrong := &Redis{Addr: c.Addr, Password: "nope"}
+	if _, err := wrong.Incr(ctx, "u1", 1, time.Hour); err == nil || !strings.Contains(err.Error(), "WRONGPASS") {
+		t.Fatalf("expected the auth error, got %v", err)
+	}
+
+	done, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := c.Incr(done, "u1", 1, time.Hour); err == nil {
+		t.Fatal("expected a done ctx to fail")
+	}
+}
@@ -0,0 +1,220 @@
+package can
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// ErrInvalidTuple is returned for relationship tuples that can't be parsed.
+var ErrInvalidTuple = errors.New("can: invalid relationship tuple")
+
+// Tuple is a relationship between a subject and an object, written as
+// "user:42 member project:7". Subjects and objects are type:id pairs. A
+// subject may also be a set of subjects, written object#relation: the
+// tuple "group:eng#member viewer doc:1" makes every member of group:eng
+// a viewer of doc:1.
+type Tuple struct {
+	Subject  string `json:"subject" yaml:"subject"`
+	Relation string `json:"relation" yaml:"relation"`
+	Object   string `json:"object" yaml:"object"`
+}
+
+// String formats the tuple as ParseTuple reads it.
+func (t Tuple) String() string {
+	return t.Subject + " " + t.Relation + " " + t.Object
+}
+
+// ParseTuple parses a tuple of the form "subject relation object".
+//
+// s - the tuple, e.g. user:42 member project:7
+//
+// returns - the tuple and ErrInvalidTuple if it is malformed
+func ParseTuple(s string) (Tuple, error) {
+	parts := strings.Fields(s)
+	if len(parts) != 3 {
+		return Tuple{}, fmt.Errorf("%w: %q", ErrInvalidTuple, s)
+	}
+
+	t := Tuple{Subject: parts[0], Relation: parts[1], Object: parts[2]}
+	object, relation, userset := strings.Cut(t.Subject, "#")
+	if !isObject(object) || (userset && !isRelation(relation)) || !isRelation(t.Relation) || !isObject(t.Object) {
+		return Tuple{}, fmt.Errorf("%w: %q", ErrInvalidTuple, s)
+	}
+
+	return t, nil
+}
+
+// DecodeTuples reads tuples from r, one per line. Blank lines and lines
+// starting with # are skipped.
+//
+// r - the reader
+//
+// returns - the tuples, or every malformed line joined into one error
+func DecodeTuples(r io.Reader) ([]Tuple, error) {
+	var (
+		tuples []Tuple
+		errs   []error
+	)
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		t, err := ParseTuple(line)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %w", n, err))
+			continue
+		}
+		tuples = append(tuples, t)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return tuples, nil
+}
+
+// isObject reports whether s is a type:id pair.
+func isObject(s string) bool {
+	typ, id, ok := strings.Cut(s, ":")
+	return ok && typ != "" && id != "" && !strings.Contains(s, "#")
+}
+
+// isRelation reports whether s can name a relation.
+func isRelation(s string) bool {
+	return s != "" && !strings.ContainsAny(s, ":#")
+}
+
+// Relations is a store of relationship tuples, for ownership and sharing
+// that roles can't express, such as documents shared with a user. It is
+// safe for concurrent use. The zero value is ready to use.
+type Relations struct {
+	// Implies maps a relation to the relations it implies on the same
+	// object, such as owner to editor and editor to viewer, so an owner
+	// is a viewer without a tuple saying so. It must be set before the
+	// first check.
+	Implies map[string][]string
+
+	mu sync.RWMutex
+	// subjects maps object#relation to the subjects holding the relation
+	subjects map[string]map[string]struct{}
+}
+
+// NewRelations returns a Relations holding tuples.
+func NewRelations(tuples ...Tuple) *Relations {
+	r := &Relations{}
+	r.Add(tuples...)
+
+	return r
+}
+
+// DefaultRelations is the store CanRelate consults.
+var DefaultRelations = &Relations{}
+
+// CanRelate checks a relationship in DefaultRelations, see Relations.CanRelate.
+func CanRelate(ctx context.Context, subject, relation, object string) bool {
+	return DefaultRelations.CanRelate(ctx, subject, relation, object)
+}
+
+// Add adds tuples to the store.
+func (r *Relations) Add(tuples ...Tuple) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.subjects == nil {
+		r.subjects = make(map[string]map[string]struct{})
+	}
+	for _, t := range tuples {
+		key := t.Object + "#" + t.Relation
+		if r.subjects[key] == nil {
+			r.subjects[key] = make(map[string]struct{})
+		}
+		r.subjects[key][t.Subject] = struct{}{}
+	}
+}
+
+// Remove removes tuples from the store.
+func (r *Relations) Remove(tuples ...Tuple) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, t := range tuples {
+		key := t.Object + "#" + t.Relation
+		delete(r.subjects[key], t.Subject)
+		if len(r.subjects[key]) == 0 {
+			delete(r.subjects, key)
+		}
+	}
+}
+
+// CanRelate checks whether subject holds relation on object, directly,
+// through a relation implying it (see Implies) or as a member of a
+// subject set holding it. Combine it with Can through the compare
+// function:
+//
+//	can.Can(ctx, role, "docs", can.Read, func() bool {
+//		return can.CanRelate(ctx, "user:42", "viewer", "doc:1")
+//	})
+//
+// ctx - a standard ctx, the check stops and denies once it is done
+//
+// subject - the subject, e.g. user:42
+//
+// relation - the relation, e.g. viewer
+//
+// object - the object, e.g. doc:1
+//
+// returns - true if the relationship holds
+func (r *Relations) CanRelate(ctx context.Context, subject, relation, object string) bool {
+	if subject == "" || relation == "" || object == "" {
+		return false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.relates(ctx, subject, relation, object, make(map[string]struct{}))
+}
+
+// relates is CanRelate without locking. visited holds the object#relation
+// pairs already checked, so cycles of subject sets and implications end.
+func (r *Relations) relates(ctx context.Context, subject, relation, object string, visited map[string]struct{}) bool {
+	key := object + "#" + relation
+	if _, ok := visited[key]; ok || ctx.Err() != nil {
+		return false
+	}
+	visited[key] = struct{}{}
+
+	subjects := r.subjects[key]
+	if _, ok := subjects[subject]; ok {
+		return true
+	}
+
+	for s := range subjects {
+		set, setRelation, ok := strings.Cut(s, "#")
+		if ok && r.relates(ctx, subject, setRelation, set, visited) {
+			return true
+		}
+	}
+
+	for implying, implied := range r.Implies {
+		for _, rel := range implied {
+			if rel == relation && r.relates(ctx, subject, implying, object, visited) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,79 @@
+package can
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCanRelate(t *testing.T) {
+	tuples, err := DecodeTuples(strings.NewReader(`
+# sharing
+user:42 member group:eng
+group:eng#member viewer doc:1
+user:7 owner doc:2
+group:eng#member member group:all
+group:all#member member group:eng
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewRelations(tuples...)
+	r.Implies = map[string][]string{"owner": {"editor"}, "editor": {"viewer"}}
+	ctx := context.Background()
+
+	tests := []struct {
+		subject, relation, object string
+		want                      bool
+	}{
+		{"user:42", "member", "group:eng", true},
+		{"user:42", "viewer", "doc:1", true},
+		{"user:42", "editor", "doc:1", false},
+		{"user:42", "member", "group:all", true},
+		{"user:7", "viewer", "doc:2", true},
+		{"user:7", "viewer", "doc:1", false},
+		{"user:9", "member", "group:all", false}, // cycles end
+	}
+
+	for _, tt := range tests {
+		if got := r.CanRelate(ctx, tt.subject, tt.relation, tt.object); got != tt.want {
+			t.Fatalf("CanRelate(%s %s %s) = %v, want %v", tt.subject, tt.relation, tt.object, got, tt.want)
+		}
+	}
+
+	r.Remove(Tuple{Subject: "user:42", Relation: "member", Object: "group:eng"})
+	if r.CanRelate(ctx, "user:42", "viewer", "doc:1") {
+		t.Fatal("expected removed tuples to no longer relate")
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if r.CanRelate(canceled, "user:7", "owner", "doc:2") {
+		t.Fatal("expected a canceled check to deny")
+	}
+
+	role := NewRole().Allow("docs", Read).Build()
+	if !Can(ctx, role, "docs", Read, func() bool { return r.CanRelate(ctx, "user:7", "viewer", "doc:2") }) {
+		t.Fatal("expected relationships to compose with Can")
+	}
+}
+
+func TestParseTuple(t *testing.T) {
+	tp, err := ParseTuple("group:eng#member viewer doc:1")
+	if err != nil || tp.String() != "group:eng#member viewer doc:1" {
+		t.Fatalf("unexpected tuple %v, %v", tp, err)
+	}
+
+	for _, s := range []string{"user:42 member", "user member project:7", "user:42 a:b project:7", "user:42# viewer doc:1", "user:42 viewer doc:1#x"} {
+		if _, err := ParseTuple(s); !errors.Is(err, ErrInvalidTuple) {
+			t.Fatalf("expected %q to be invalid, got %v", s, err)
+		}
+	}
+
+	_, err = DecodeTuples(strings.NewReader("user:1 viewer\nuser viewer doc:1\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 1") || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("expected every malformed line to be reported, got %v", err)
+	}
+}
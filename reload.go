@@ -0,0 +1,76 @@
+package can
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// ErrNoSource is returned by Store.Reload when the Store has no Source.
+var ErrNoSource = errors.New("can: store has no source")
+
+// FileSource returns a Source loading the role files matching globs with
+// OpenFiles, for Store.Source.
+func FileSource(globs ...string) func() (Roles, error) {
+	return func() (Roles, error) {
+		return OpenFiles(globs...)
+	}
+}
+
+// Reload loads the roles from Source and replaces the current roles
+// with them. The roles are only replaced once they loaded and passed
+// validation, so a malformed file never replaces a good set of roles.
+//
+// returns - the error of Source, in which case the current roles are
+// kept, or ErrNoSource
+func (s *Store) Reload() error {
+	if s.Source == nil {
+		return ErrNoSource
+	}
+
+	r, err := s.Source()
+	if err != nil {
+		return err
+	}
+
+	s.Set(r)
+	return nil
+}
+
+// ReloadOnSignal reloads store (see Store.Reload) every time the process
+// receives one of signals, SIGHUP when none are given, so operators can
+// apply role file changes with kill -HUP. Failed reloads are reported to
+// onError and the previous roles are kept. ReloadOnSignal blocks until
+// ctx is done.
+//
+// ctx - a standard ctx, canceling it stops listening for signals
+//
+// store - the store to reload, it must have a Source
+//
+// onError - called with every reload error, may be nil
+//
+// signals - the signals triggering a reload
+//
+// returns - the ctx error once it stops
+func ReloadOnSignal(ctx context.Context, store *Store, onError func(error), signals ...os.Signal) error {
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGHUP}
+	}
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, signals...)
+	defer signal.Stop(c)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c:
+			if err := store.Reload(); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
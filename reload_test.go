@@ -0,0 +1,36 @@
+package can
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "rbac.yml")
+	write := func(s string) {
+		if err := os.WriteFile(file, []byte(s), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := NewStore(nil)
+	if err := s.Reload(); !errors.Is(err, ErrNoSource) {
+		t.Fatalf("expected ErrNoSource, got %v", err)
+	}
+
+	s.Source = FileSource(file)
+	write("user:\n  users:\n    abilities: [read]\n")
+	if err := s.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	revision := s.Revision()
+
+	write("user:\n  users:\n    abilities: [fly]\n")
+	if err := s.Reload(); !errors.Is(err, ErrUnknownAbility) || s.Revision() != revision {
+		t.Fatalf("expected the invalid file to be rejected and the roles kept, got %v", err)
+	}
+
+}
@@ -0,0 +1,40 @@
+//go:build unix
+
+package can
+
+import (
+	"context"
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestReloadOnSignal(t *testing.T) {
+	s := NewStore(nil)
+	s.Source = func() (Roles, error) { return nil, ErrUnknownAbility }
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	done := make(chan error)
+	go func() { done <- ReloadOnSignal(ctx, s, func(err error) { errs <- err }, syscall.SIGUSR1) }()
+
+	// wait for the handler to be installed
+	time.Sleep(50 * time.Millisecond)
+	if err := syscall.Kill(os.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errs:
+		if !errors.Is(err, ErrUnknownAbility) {
+			t.Fatalf("unexpected reload error %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the signal to trigger a reload")
+	}
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the ctx error, got %v", err)
+	}
+}
@@ -0,0 +1,184 @@
+package can
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// schemaKind is the shape of a field of DiskPermission in a schema.
+type schemaKind int
+
+const (
+	abilityList schemaKind = iota
+	stringList
+	stringValue
+	abilityMap
+	labelMap
+	quotaMap
+)
+
+// schemaFields describes the fields of DiskPermission for JSONSchema
+// and CUESchema, keyed by their yaml name.
+var schemaFields = map[string]struct {
+	kind        schemaKind
+	description string
+}{
+	"abilities":    {abilityList, "abilities granted on the resource"},
+	"routes":       {stringList, "routes below the resource granted too, such as {id}/invoices"},
+	"resource":     {stringValue, "name of the resource the permission applies to"},
+	"fields":       {abilityMap, "fields of the resource an ability is restricted to"},
+	"environments": {stringList, "environments the grant is limited to"},
+	"labels":       {labelMap, "labels a check must match, one of the values of every key"},
+	"deny":         {abilityList, "abilities refused even if they are granted"},
+	"conditions":   {stringList, "names of the comparators that must allow a check"},
+	"audit":        {abilityList, "abilities whose checks must be audited"},
+	"cidr":         {stringList, "networks the grant is limited to, CIDR blocks or IP addresses"},
+	"quotas":       {quotaMap, "uses of an ability allowed per window, such as 5/day"},
+}
+
+// schemaAbilities are the names of the abilities role files may use.
+var schemaAbilities = []string{All.String(), Read.String(), Create.String(), Update.String(), Delete.String(), Skip.String()}
+
+// quotaPattern matches the quotas ParseQuota reads.
+const quotaPattern = `^\s*[0-9]+\s*/\s*(second|minute|hour|day|([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+)\s*$`
+
+// envPattern matches values holding ${VAR} placeholders, expanded when
+// the file is loaded.
+const envPattern = `\$\{`
+
+// permissionFields returns the yaml names of the fields of
+// DiskPermission, in order.
+func permissionFields() []string {
+	t := reflect.TypeOf(DiskPermission{})
+	names := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		names = append(names, name)
+	}
+
+	return names
+}
+
+// JSONSchema returns a JSON Schema (draft 2020-12) of role files, so
+// editors and CI can validate them before they are loaded. Like Strict
+// decoding, it rejects unknown permission fields. Abilities must be
+// written with their lowercase names.
+func JSONSchema() []byte {
+	ability := map[string]any{"anyOf": []any{
+		map[string]any{"enum": schemaAbilities},
+		map[string]any{"type": "string", "pattern": envPattern},
+	}}
+	stringArray := map[string]any{"type": "array", "items": map[string]any{"type": "string"}}
+
+	permission := func(ability any) map[string]any {
+		properties := make(map[string]any, len(schemaFields))
+		for _, name := range permissionFields() {
+			f := schemaFields[name]
+			var p map[string]any
+			switch f.kind {
+			case abilityList:
+				p = map[string]any{"type": "array", "items": ability}
+			case stringList:
+				p = map[string]any{"type": "array", "items": map[string]any{"type": "string"}}
+			case stringValue:
+				p = map[string]any{"type": "string"}
+			case abilityMap:
+				p = map[string]any{"type": "object", "propertyNames": ability, "additionalProperties": stringArray}
+			case labelMap:
+				p = map[string]any{"type": "object", "additionalProperties": stringArray}
+			case quotaMap:
+				p = map[string]any{"type": "object", "propertyNames": ability, "additionalProperties": map[string]any{"type": "string", "anyOf": []any{
+					map[string]any{"pattern": quotaPattern},
+					map[string]any{"pattern": envPattern},
+				}}}
+			}
+			p["description"] = f.description
+			properties[name] = p
+		}
+
+		return map[string]any{"type": "object", "properties": properties, "additionalProperties": false}
+	}
+
+	schema := map[string]any{
+		"$schema":     "https://json-schema.org/draft/2020-12/schema",
+		"title":       "can role file",
+		"description": "roles keyed by name, each mapping resources to their permission",
+		"type":        "object",
+		"properties": map[string]any{
+			IncludeKey: map[string]any{"description": "role files to include", "type": "array", "items": map[string]any{"type": "string"}},
+			TemplatesKey: map[string]any{
+				"description":          "role templates with {param} placeholders",
+				"type":                 "object",
+				"additionalProperties": map[string]any{"type": "object", "additionalProperties": map[string]any{"$ref": "#/$defs/templatePermission"}},
+			},
+			GroupsKey:  map[string]any{"description": "roles of identity provider groups", "type": "object", "additionalProperties": stringArray},
+			AliasesKey: map[string]any{"description": "old permission names and the names roles grant them under", "type": "object", "additionalProperties": map[string]any{"type": "string"}},
+		},
+		"additionalProperties": map[string]any{"$ref": "#/$defs/role"},
+		"$defs": map[string]any{
+			"role":               map[string]any{"type": "object", "additionalProperties": map[string]any{"$ref": "#/$defs/permission"}},
+			"permission":         permission(map[string]any{"$ref": "#/$defs/ability"}),
+			"templatePermission": permission(map[string]any{"type": "string"}),
+			"ability":            ability,
+		},
+	}
+
+	b, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+
+	return append(b, '\n')
+}
+
+// CUESchema returns CUE definitions of role files, the counterpart of
+// JSONSchema for configuration written or checked with CUE. #Roles is a
+// role file.
+func CUESchema() string {
+	var b strings.Builder
+	reserved := strings.Join([]string{IncludeKey, TemplatesKey, GroupsKey, AliasesKey}, "|")
+
+	fmt.Fprintf(&b, "// can role files, see JSONSchema of github.com/acmacalister/can\n\n")
+	fmt.Fprintf(&b, "#Roles: {\n")
+	fmt.Fprintf(&b, "\t%s?: [...string]\n", IncludeKey)
+	fmt.Fprintf(&b, "\t%s?: [string]: [string]: #TemplatePermission\n", TemplatesKey)
+	fmt.Fprintf(&b, "\t%s?: [string]: [...string]\n", GroupsKey)
+	fmt.Fprintf(&b, "\t%s?: [string]: string\n", AliasesKey)
+	fmt.Fprintf(&b, "\t[!~\"^(%s)$\"]: #Role\n", reserved)
+	fmt.Fprintf(&b, "}\n\n")
+	fmt.Fprintf(&b, "#Role: [string]: #Permission\n\n")
+
+	abilities := make([]string, len(schemaAbilities))
+	for i, a := range schemaAbilities {
+		abilities[i] = fmt.Sprintf("%q", a)
+	}
+	fmt.Fprintf(&b, "#Ability: %s | =~\"%s\"\n", strings.Join(abilities, " | "), strings.ReplaceAll(envPattern, `\`, `\\`))
+
+	for _, def := range []struct{ name, ability string }{{"Permission", "#Ability"}, {"TemplatePermission", "string"}} {
+		fmt.Fprintf(&b, "\n#%s: close({\n", def.name)
+		for _, name := range permissionFields() {
+			f := schemaFields[name]
+			var t string
+			switch f.kind {
+			case abilityList:
+				t = "[..." + def.ability + "]"
+			case stringList:
+				t = "[...string]"
+			case stringValue:
+				t = "string"
+			case abilityMap:
+				t = "[" + def.ability + "]: [...string]"
+			case labelMap:
+				t = "[string]: [...string]"
+			case quotaMap:
+				t = fmt.Sprintf("[%s]: =~%q | =~%q", def.ability, quotaPattern, envPattern)
+			}
+			fmt.Fprintf(&b, "\t// %s\n\t%s?: %s\n", f.description, name, t)
+		}
+		fmt.Fprintf(&b, "})\n")
+	}
+
+	return b.String()
+}
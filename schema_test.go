@@ -0,0 +1,53 @@
+package can
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestJSONSchema(t *testing.T) {
+	var schema struct {
+		Properties map[string]any `json:"properties"`
+		Defs       map[string]struct {
+			Properties           map[string]map[string]any `json:"properties"`
+			AdditionalProperties any                       `json:"additionalProperties"`
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal(JSONSchema(), &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{IncludeKey, TemplatesKey, GroupsKey, AliasesKey} {
+		if _, ok := schema.Properties[key]; !ok {
+			t.Errorf("expected the reserved key %q", key)
+		}
+	}
+
+	permission := schema.Defs["permission"]
+	if permission.AdditionalProperties != false {
+		t.Fatal("expected unknown permission fields to be rejected")
+	}
+	for _, name := range permissionFields() {
+		if _, ok := schemaFields[name]; !ok {
+			t.Errorf("field %q isn't described", name)
+		}
+		if p, ok := permission.Properties[name]; !ok || p["description"] == "" {
+			t.Errorf("expected field %q in the schema", name)
+		}
+	}
+}
+
+func TestCUESchema(t *testing.T) {
+	cue := CUESchema()
+	for _, want := range []string{"#Roles: {", "#Role: [string]: #Permission", `#Ability: "all" | "read"`, "#Permission: close({", "#TemplatePermission: close({"} {
+		if !strings.Contains(cue, want) {
+			t.Errorf("expected %q in:\n%s", want, cue)
+		}
+	}
+	for _, name := range permissionFields() {
+		if !strings.Contains(cue, "\t"+name+"?: ") {
+			t.Errorf("expected field %q in:\n%s", name, cue)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package can
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidScope is returned for scope strings that can't be parsed.
+var ErrInvalidScope = errors.New("can: invalid scope")
+
+// ScopeSeparator separates the resource from the ability in a scope
+// string such as users:read.
+const ScopeSeparator = ":"
+
+// ParsePermission parses a scope string of the form resource:ability,
+// as used by API keys and personal access tokens, into a permission and
+// an ability. Routes below the resource are separated by "/", so
+// users/profile:update is the update ability on users_profile. The
+// ability * is shorthand for all, skip is not accepted.
+//
+// scope - the scope string, e.g. users:read
+//
+// returns - the permission key, the ability and ErrInvalidScope if the
+// scope is malformed or names an unknown ability
+func ParsePermission(scope string) (string, Ability, error) {
+	i := strings.LastIndex(scope, ScopeSeparator)
+	if i <= 0 || i == len(scope)-1 {
+		return "", None, fmt.Errorf("%w: %q", ErrInvalidScope, scope)
+	}
+
+	name := scope[i+1:]
+	if name == "*" {
+		name = "all"
+	}
+	ability := StringToAbility(name)
+	if ability == None || ability == Skip {
+		return "", None, fmt.Errorf("%w: %q: unknown ability %q", ErrInvalidScope, scope, name)
+	}
+
+	resource, route, _ := strings.Cut(scope[:i], "/")
+	permission := PermissionKey(resource, route)
+	if resource == "" || permission == "" {
+		return "", None, fmt.Errorf("%w: %q", ErrInvalidScope, scope)
+	}
+
+	return permission, ability, nil
+}
+
+// FromScopes returns a copy of r that also grants every scope in
+// scopes, see ParsePermission for the format. r is usually empty, to
+// build the role of an API key from its scopes alone:
+//
+//	role, err := can.Role{}.FromScopes(key.Scopes)
+//
+// scopes - the scope strings, e.g. []string{"users:read", "books:*"}
+//
+// returns - the role and an error listing every invalid scope
+func (r Role) FromScopes(scopes []string) (Role, error) {
+	b := NewRole()
+	for permission, p := range r {
+		b.role[permission] = p.Clone()
+	}
+
+	var errs []error
+	for _, scope := range scopes {
+		permission, ability, err := ParsePermission(scope)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		b.Allow(permission, ability)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return b.role, nil
+}
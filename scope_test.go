@@ -0,0 +1,61 @@
+package can
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestParsePermission(t *testing.T) {
+	tests := []struct {
+		scope      string
+		permission string
+		ability    Ability
+		err        bool
+	}{
+		{"users:read", "users", Read, false},
+		{"users:*", "users", All, false},
+		{"users/profile:update", "users_profile", Update, false},
+		{"billing:DELETE", "billing", Delete, false},
+		{"users", "", None, true},
+		{":read", "", None, true},
+		{"users:", "", None, true},
+		{"users:write", "", None, true},
+		{"users:skip", "", None, true},
+	}
+
+	for _, tt := range tests {
+		permission, ability, err := ParsePermission(tt.scope)
+		if (err != nil) != tt.err || permission != tt.permission || ability != tt.ability {
+			t.Errorf("%q: expected %q %v %v, got %q %v %v", tt.scope, tt.permission, tt.ability, tt.err, permission, ability, err)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidScope) {
+			t.Errorf("%q: expected ErrInvalidScope, got %v", tt.scope, err)
+		}
+	}
+}
+
+func TestFromScopes(t *testing.T) {
+	role, err := Role{}.FromScopes([]string{"users:read", "books:*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	yes := func() bool { return true }
+	if !Can(ctx, role, "users", Read, yes) || Can(ctx, role, "users", Update, yes) || !Can(ctx, role, "books", Delete, yes) {
+		t.Fatalf("unexpected role %v", role)
+	}
+
+	if _, err := (Role{}).FromScopes([]string{"users:read", "nope", "books:write"}); err == nil || !errors.Is(err, ErrInvalidScope) {
+		t.Fatalf("expected invalid scopes to fail, got %v", err)
+	}
+
+	base := NewRole().Allow("users", Update).Build()
+	if role, err = base.FromScopes([]string{"users:read"}); err != nil || !Can(ctx, role, "users", Update, yes) || !Can(ctx, role, "users", Read, yes) {
+		t.Fatalf("expected the scopes to be added to the role, got %v %v", role, err)
+	}
+	if Can(ctx, base, "users", Read, yes) {
+		t.Fatal("expected the receiver not to be modified")
+	}
+}
@@ -0,0 +1,76 @@
+package can
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrSessionLimit is returned when starting a new session would exceed
+// the concurrent session limit of one of the subject's roles.
+var ErrSessionLimit = errors.New("can: session limit reached")
+
+// SessionStore is implemented by session backends so can can enforce
+// per-role concurrency limits. Implementations only need to report how
+// many sessions are currently active for a subject.
+type SessionStore interface {
+	// ActiveSessions returns the number of active sessions for subject.
+	ActiveSessions(ctx context.Context, subject string) (int, error)
+}
+
+// SessionLimits maps a role name to the maximum number of concurrent
+// sessions a subject holding that role may have. Roles without an entry,
+// or with a limit of zero or less, are unlimited. SessionLimits is easily
+// embedded in an application config next to DiskRoles (see testdata/config.yml).
+type SessionLimits map[string]int
+
+// Limit returns the effective session limit for a subject holding roles.
+// When several roles are limited the strictest one wins.
+//
+// roles - the role names held by the subject
+//
+// returns - the limit and true, or 0 and false if no role is limited
+func (l SessionLimits) Limit(roles ...string) (int, bool) {
+	limit, ok := 0, false
+	for _, role := range roles {
+		max, found := l[role]
+		if !found || max <= 0 {
+			continue
+		}
+		if !ok || max < limit {
+			limit, ok = max, true
+		}
+	}
+
+	return limit, ok
+}
+
+// Allow checks whether subject may start another session. It should be
+// called by the session integration before a new session is created.
+//
+// ctx - a standard ctx passed through to the session store
+//
+// store - the session store used to count active sessions
+//
+// subject - the identifier of the user or client starting a session
+//
+// roles - the role names held by the subject
+//
+// returns - nil if the session may start, ErrSessionLimit if the limit is
+// reached or the error from the session store
+func (l SessionLimits) Allow(ctx context.Context, store SessionStore, subject string, roles ...string) error {
+	limit, ok := l.Limit(roles...)
+	if !ok {
+		return nil
+	}
+
+	active, err := store.ActiveSessions(ctx, subject)
+	if err != nil {
+		return err
+	}
+
+	if active >= limit {
+		return ErrSessionLimit
+	}
+
+	return nil
+}
@@ -0,0 +1,49 @@
+package can
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+type sessionCounter map[string]int
+
+func (s sessionCounter) ActiveSessions(ctx context.Context, subject string) (int, error) {
+	return s[subject], nil
+}
+
+func TestSessionLimits(t *testing.T) {
+	f, err := os.OpenFile("testdata/config.yml", os.O_RDONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var c struct {
+		Sessions SessionLimits `yaml:"sessions"`
+	}
+	if err := yaml.NewDecoder(f).Decode(&c); err != nil {
+		t.Fatal(err)
+	}
+
+	store := sessionCounter{"alice": 1, "bob": 3}
+
+	if err := c.Sessions.Allow(context.Background(), store, "alice", "user", "admin"); !errors.Is(err, ErrSessionLimit) {
+		t.Fatalf("expected session limit for admin, got %v", err)
+	}
+
+	if err := c.Sessions.Allow(context.Background(), store, "bob", "user"); err != nil {
+		t.Fatalf("expected user to be unlimited, got %v", err)
+	}
+
+	if err := c.Sessions.Allow(context.Background(), store, "carol", "admin"); err != nil {
+		t.Fatalf("expected first admin session to be allowed, got %v", err)
+	}
+
+	if limit, ok := (SessionLimits{"a": 3, "b": 2, "c": 0}).Limit("a", "b", "c"); !ok || limit != 2 {
+		t.Fatalf("expected strictest limit 2, got %d", limit)
+	}
+}
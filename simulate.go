@@ -0,0 +1,117 @@
+package can
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Scenario is an expected decision, checked by Simulate.
+type Scenario struct {
+	// Name describes the scenario in reports, defaults to its check.
+	Name       string  `json:"name,omitempty" yaml:"name,omitempty"`
+	Role       string  `json:"role" yaml:"role"`
+	Permission string  `json:"permission" yaml:"permission"`
+	Ability    Ability `json:"ability" yaml:"ability"`
+	// Compare is the result of the compare function of the check, e.g.
+	// whether the subject owns the record.
+	Compare bool `json:"compare,omitempty" yaml:"compare,omitempty"`
+	// Labels are the labels of the check (see WithLabels).
+	Labels Labels `json:"labels,omitempty" yaml:"labels,omitempty"`
+	// Allowed is the expected decision.
+	Allowed bool `json:"allowed" yaml:"allowed"`
+}
+
+// String describes the scenario, e.g. "user can read users".
+func (s Scenario) String() string {
+	if s.Name != "" {
+		return s.Name
+	}
+
+	verb := "cannot"
+	if s.Allowed {
+		verb = "can"
+	}
+
+	return fmt.Sprintf("%s %s %s %s", s.Role, verb, s.Ability, s.Permission)
+}
+
+// ScenarioResult is the outcome of a Scenario.
+type ScenarioResult struct {
+	Scenario Scenario `json:"scenario"`
+	// Allowed is the decision the roles made.
+	Allowed bool `json:"allowed"`
+	// Explanation traces the decision, see Explain.
+	Explanation Explanation `json:"explanation"`
+}
+
+// Passed reports whether the decision is the expected one.
+func (r ScenarioResult) Passed() bool {
+	return r.Allowed == r.Scenario.Allowed
+}
+
+// Report is the outcome of a simulation.
+type Report struct {
+	Results []ScenarioResult `json:"results"`
+}
+
+// OK reports whether every scenario passed.
+func (r Report) OK() bool {
+	return len(r.Failures()) == 0
+}
+
+// Failures returns the results of the scenarios that didn't pass.
+func (r Report) Failures() []ScenarioResult {
+	var failures []ScenarioResult
+	for _, result := range r.Results {
+		if !result.Passed() {
+			failures = append(failures, result)
+		}
+	}
+
+	return failures
+}
+
+// String summarizes the report, with the explanation of every failure.
+func (r Report) String() string {
+	var b strings.Builder
+	failures := r.Failures()
+	for _, f := range failures {
+		fmt.Fprintf(&b, "FAIL %s\n", f.Scenario)
+		for _, step := range f.Explanation.Steps {
+			fmt.Fprintf(&b, "  - %s\n", step)
+		}
+	}
+	fmt.Fprintf(&b, "%d scenarios, %d failed\n", len(r.Results), len(failures))
+
+	return b.String()
+}
+
+// Simulate checks scenarios against a candidate set of roles, for CI
+// gates blocking a deploy when a role change accidentally grants or
+// revokes access. Scenarios naming an unknown role are decided with a
+// nil role, which is always denied.
+//
+// roles - the candidate roles
+//
+// scenarios - the expected decisions
+//
+// returns - a report with a result for every scenario, in order
+func Simulate(roles Roles, scenarios []Scenario) Report {
+	report := Report{Results: make([]ScenarioResult, 0, len(scenarios))}
+	for _, s := range scenarios {
+		ctx := context.Background()
+		if len(s.Labels) > 0 {
+			ctx = WithLabels(ctx, s.Labels)
+		}
+		compare := s.Compare
+
+		e := Explain(ctx, roles[s.Role], s.Permission, s.Ability, func() bool { return compare })
+		if _, ok := roles[s.Role]; !ok {
+			e.Steps = append([]string{fmt.Sprintf("role %q not found", s.Role)}, e.Steps...)
+		}
+		report.Results = append(report.Results, ScenarioResult{Scenario: s, Allowed: e.Allowed, Explanation: e})
+	}
+
+	return report
+}
@@ -0,0 +1,40 @@
+package can
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSimulate(t *testing.T) {
+	r, err := OpenFile("testdata/rbac.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scenarios := []Scenario{
+		{Role: "user", Permission: "users", Ability: Read, Compare: true, Allowed: true},
+		{Role: "user", Permission: "users", Ability: Read, Allowed: false, Name: "user can't read others"},
+		{Role: "user", Permission: "users", Ability: Delete, Allowed: true},
+		{Role: "ghost", Permission: "users", Ability: Read, Allowed: true},
+	}
+
+	report := Simulate(r, scenarios)
+	if report.OK() || len(report.Results) != 4 {
+		t.Fatalf("expected failures, got %+v", report)
+	}
+
+	failures := report.Failures()
+	if len(failures) != 2 || failures[0].Scenario.String() != "user can delete users" || failures[1].Scenario.Role != "ghost" {
+		t.Fatalf("unexpected failures %+v", failures)
+	}
+
+	out := report.String()
+	if !strings.Contains(out, "FAIL user can delete users\n  - matched permission \"users\"") ||
+		!strings.Contains(out, `role "ghost" not found`) || !strings.HasSuffix(out, "4 scenarios, 2 failed\n") {
+		t.Fatalf("unexpected report:\n%s", out)
+	}
+
+	if !Simulate(r, scenarios[:2]).OK() {
+		t.Fatal("expected passing scenarios to be OK")
+	}
+}
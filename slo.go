@@ -0,0 +1,140 @@
+package can
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SLOStats reports the decision latency observed by an SLO.
+type SLOStats struct {
+	Samples  int
+	P50      time.Duration
+	P99      time.Duration
+	Breaches uint64
+	Breached bool
+}
+
+// SLO is an Authorizer that measures the latency of another Authorizer
+// and surfaces when its p99 exceeds a budget, so slow remote checks are
+// caught before they degrade an API. A breach is reported through Stats
+// (for metrics), logged once when it starts and ends, and makes Healthy
+// return an error (for health checks). Decisions themselves are never
+// changed.
+type SLO struct {
+	authorizer Authorizer
+	threshold  time.Duration
+
+	// Window is the number of recent decisions the p99 is computed over.
+	// Defaults to 1000. It must be set before the first decision.
+	Window int
+	// Logf logs breaches. Defaults to log.Printf.
+	Logf func(format string, args ...any)
+
+	mu       sync.Mutex
+	samples  []time.Duration
+	next     int
+	count    int
+	breached bool
+	breaches uint64
+	p50, p99 time.Duration
+}
+
+// LatencySLO wraps an authorizer with a latency budget.
+//
+// a - the authorizer to measure
+//
+// threshold - the p99 latency budget of a decision
+//
+// returns - an SLO
+func LatencySLO(a Authorizer, threshold time.Duration) *SLO {
+	return &SLO{authorizer: a, threshold: threshold}
+}
+
+// Authorize implements the Authorizer interface.
+func (s *SLO) Authorize(ctx context.Context, role Role, permission string, ability Ability, compare func() bool) bool {
+	start := Now()
+	allowed := s.authorizer.Authorize(ctx, role, permission, ability, compare)
+	s.record(Now().Sub(start))
+
+	return allowed
+}
+
+// Stats returns the latency observed over the window.
+func (s *SLO) Stats() SLOStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return SLOStats{
+		Samples:  len(s.samples),
+		P50:      s.p50,
+		P99:      s.p99,
+		Breaches: s.breaches,
+		Breached: s.breached,
+	}
+}
+
+// Healthy returns an error while the p99 latency exceeds the budget.
+func (s *SLO) Healthy() error {
+	stats := s.Stats()
+	if stats.Breached {
+		return fmt.Errorf("can: authorization p99 latency %s exceeds %s", stats.P99, s.threshold)
+	}
+
+	return nil
+}
+
+// record adds a sample and re-evaluates the percentiles every tenth of
+// the window, so the hot path rarely pays for sorting.
+func (s *SLO) record(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	window := s.Window
+	if window <= 0 {
+		window = 1000
+	}
+
+	if len(s.samples) < window {
+		s.samples = append(s.samples, d)
+	} else {
+		s.samples[s.next] = d
+	}
+	s.next = (s.next + 1) % window
+
+	s.count++
+	every := window / 10
+	if every < 1 {
+		every = 1
+	}
+	if s.count%every != 0 {
+		return
+	}
+
+	sorted := append([]time.Duration(nil), s.samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	s.p50 = sorted[len(sorted)/2]
+	s.p99 = sorted[(len(sorted)*99)/100]
+
+	breached := s.p99 > s.threshold
+	switch {
+	case breached && !s.breached:
+		s.breaches++
+		s.logf("can: authorization p99 latency %s exceeds %s", s.p99, s.threshold)
+	case !breached && s.breached:
+		s.logf("can: authorization p99 latency %s back within %s", s.p99, s.threshold)
+	}
+	s.breached = breached
+}
+
+func (s *SLO) logf(format string, args ...any) {
+	if s.Logf != nil {
+		s.Logf(format, args...)
+		return
+	}
+
+	log.Printf(format, args...)
+}
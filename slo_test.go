@@ -0,0 +1,50 @@
+package can
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestLatencySLO(t *testing.T) {
+	delay := time.Duration(0)
+	a := AuthorizerFunc(func(ctx context.Context, role Role, permission string, ability Ability, compare func() bool) bool {
+		time.Sleep(delay)
+		return true
+	})
+
+	var logs []string
+	s := LatencySLO(a, 5*time.Millisecond)
+	s.Window = 10
+	s.Logf = func(format string, args ...any) { logs = append(logs, fmt.Sprintf(format, args...)) }
+
+	for i := 0; i < 10; i++ {
+		if !s.Authorize(context.Background(), nil, "users", Read, nil) {
+			t.Fatal("expected decisions to pass through")
+		}
+	}
+	if err := s.Healthy(); err != nil {
+		t.Fatal(err)
+	}
+
+	delay = 10 * time.Millisecond
+	s.Authorize(context.Background(), nil, "users", Read, nil)
+	if err := s.Healthy(); err == nil {
+		t.Fatal("expected a slow p99 to be unhealthy")
+	}
+	if stats := s.Stats(); stats.Breaches != 1 || stats.P99 < delay || stats.Samples != 10 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	delay = 0
+	for i := 0; i < 10; i++ {
+		s.Authorize(context.Background(), nil, "users", Read, nil)
+	}
+	if err := s.Healthy(); err != nil {
+		t.Fatalf("expected recovery, got %v", err)
+	}
+	if len(logs) != 2 {
+		t.Fatalf("expected breach and recovery to be logged once each, got %v", logs)
+	}
+}
@@ -0,0 +1,458 @@
+package can
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Consistency controls when a change made through a Store becomes
+// visible to checks on the same instance.
+type Consistency int
+
+const (
+	// Eventual only applies a change once it comes back from the shared
+	// backend, usually through Watch. Every instance sees changes at
+	// roughly the same time, but a Grant may not be visible right away.
+	// Changes saved before the backend reports them build on each other.
+	Eventual Consistency = iota
+	// ReadYourWrites applies a change locally as soon as it was saved to
+	// the shared backend, so a Grant followed by a check on the same
+	// instance always sees the new permission.
+	ReadYourWrites
+)
+
+// Persister saves roles to a shared backend, such as the KV a Watch
+// keeps the Store in sync with.
+type Persister interface {
+	Save(ctx context.Context, r Roles) error
+}
+
+// Store holds a set of Roles that can be swapped at runtime. It is safe
+// for concurrent use, so roles can be reloaded from a dynamic source
+// while requests are being authorized. Reads never take a lock: the
+// roles are published as immutable snapshots, and checks see either the
+// old or the new roles of a change, never a mix.
+type Store struct {
+	// Persister saves changes made with Grant and Revoke. When nil,
+	// changes are only applied locally.
+	Persister Persister
+	// Consistency controls when saved changes are applied locally.
+	Consistency Consistency
+	// Source loads the roles on Reload, such as FileSource. May be nil.
+	Source func() (Roles, error)
+	// MaxSnapshots bounds the number of effective roles SubjectRole keeps,
+	// one per distinct set of role names. Defaults to 1024.
+	MaxSnapshots int
+	// History is the number of versions kept for ActivateVersion, the
+	// current one included. Defaults to 10.
+	History int
+	// OnElevate is called with every elevation issued by Elevate, to
+	// record who was given access and why. May be nil.
+	OnElevate func(Elevation)
+	// OnRoleConflict is called when the roles of a subject define a
+	// permission in contradicting ways, once per snapshot (see
+	// SubjectRole). May be nil.
+	OnRoleConflict func(sub Subject, err error)
+
+	mu       sync.Mutex // serializes writers
+	state    atomic.Pointer[storeState]
+	versions []PolicyVersion
+	// elevations maps subject IDs to their elevations, see Elevate
+	elevations atomic.Pointer[map[string][]Elevation]
+
+	// wmu serializes Grant and Revoke so concurrent changes aren't lost.
+	wmu sync.Mutex
+	// saved holds the roles Grant and Revoke last saved and pending the
+	// revisions served or saved since, so changes an Eventual Store
+	// hasn't been sent back yet aren't lost by the next change.
+	saved   Roles
+	pending []string
+}
+
+// storeState is an immutable snapshot of the roles of a Store, only its
+// cache of effective roles is filled in as subjects are checked.
+type storeState struct {
+	roles    Roles
+	revision string
+	version  uint64
+	// snapshots maps sets of role names to their effective role
+	snapshots sync.Map
+	size      atomic.Int64
+}
+
+// load returns the current state of the Store.
+func (s *Store) load() *storeState {
+	if st := s.state.Load(); st != nil {
+		return st
+	}
+
+	return &storeState{}
+}
+
+// publish makes a new state current. Must be called with mu held.
+func (s *Store) publish(roles Roles, revision string, version uint64) {
+	s.load().forgetHashes(false)
+	for _, role := range roles {
+		rememberHash(role)
+	}
+	s.state.Store(&storeState{roles: roles, revision: revision, version: version})
+}
+
+// forgetHashes forgets the hashes of the snapshots of a replaced state,
+// and of its roles unless they are still served.
+func (st *storeState) forgetHashes(served bool) {
+	if !served {
+		for _, role := range st.roles {
+			forgetHash(role)
+		}
+	}
+	st.snapshots.Range(func(_, v any) bool {
+		if role := v.(snapshot).role; !st.serves(role) {
+			forgetHash(role)
+		}
+		return true
+	})
+}
+
+// serves reports whether role is one of the roles of st, rather than a
+// merged snapshot.
+func (st *storeState) serves(role Role) bool {
+	if role == nil {
+		return false
+	}
+	p := reflect.ValueOf(role).Pointer()
+	for _, r := range st.roles {
+		if reflect.ValueOf(r).Pointer() == p {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NewStore returns a Store that serves the given roles.
+// r - the initial set of roles, may be nil
+//
+// returns - a Store
+func NewStore(r Roles) *Store {
+	s := &Store{}
+	s.Set(r)
+
+	return s
+}
+
+// Roles returns the current set of roles. The returned map must not be
+// modified, use Set to replace it.
+func (s *Store) Roles() Roles {
+	return s.load().roles
+}
+
+// Role returns the role with the given name from the current set of roles.
+// name - the name of the role
+//
+// returns - the role and true if it exists
+func (s *Store) Role(name string) (Role, bool) {
+	role, ok := s.load().roles[name]
+	return role, ok
+}
+
+// SubjectRole returns the effective role of a subject: the union of its
+// roles and the roles of its active elevations (see Elevate). Unknown
+// role names are skipped. A permission that several roles define in
+// contradicting ways, such as with different networks, keeps the
+// definition of the subject's own roles, or of the first role by name,
+// and the conflict is reported to OnRoleConflict. The result is kept as
+// a snapshot shared by every subject with the same roles until the roles
+// of the Store change, so read heavy services only pay for the merge
+// once. The returned role must not be modified.
+//
+// sub - the subject
+//
+// returns - the role and true, or nil and false if the subject has no
+// known role
+func (s *Store) SubjectRole(sub Subject) (Role, bool) {
+	names := sortedNames(sub.Roles)
+	var elevated []string
+	for _, e := range s.Elevations(sub.ID) {
+		elevated = append(elevated, e.Role)
+	}
+	elevated = sortedNames(elevated)
+	key := strings.Join(names, "\x00") + "\x01" + strings.Join(elevated, "\x00")
+
+	st := s.load()
+	if v, ok := st.snapshots.Load(key); ok {
+		snap := v.(snapshot)
+		return snap.role, snap.ok
+	}
+
+	var snap snapshot
+	var err error
+	snap.role, snap.ok, err = mergeNamed(st.roles, append(names, elevated...))
+	if err != nil && s.OnRoleConflict != nil {
+		s.OnRoleConflict(sub, err)
+	}
+
+	max := s.MaxSnapshots
+	if max <= 0 {
+		max = 1024
+	}
+	if st.size.Load() >= int64(max) {
+		// start over with an empty cache, unless a concurrent change
+		// already replaced the state the snapshot was made of
+		next := &storeState{roles: st.roles, revision: st.revision, version: st.version}
+		if !s.state.CompareAndSwap(st, next) {
+			return snap.role, snap.ok
+		}
+		st.forgetHashes(true)
+		st = next
+	}
+	if _, loaded := st.snapshots.LoadOrStore(key, snap); !loaded {
+		st.size.Add(1)
+		if !st.serves(snap.role) {
+			rememberHash(snap.role)
+			if s.state.Load() != st {
+				// the state was replaced meanwhile, don't keep its snapshot
+				forgetHash(snap.role)
+			}
+		}
+	}
+
+	return snap.role, snap.ok
+}
+
+// Precompute makes the snapshots of the effective roles of subjects
+// ahead of their first check, for example when a service starts or
+// after a reload.
+func (s *Store) Precompute(subs ...Subject) {
+	for _, sub := range subs {
+		s.SubjectRole(sub)
+	}
+}
+
+// snapshot is the effective role of a set of role names.
+type snapshot struct {
+	role Role
+	ok   bool
+}
+
+// sortedNames returns a sorted copy of a set of role names.
+func sortedNames(names []string) []string {
+	sorted := append([]string(nil), names...)
+	sort.Strings(sorted)
+
+	return sorted
+}
+
+// mergeNamed returns the union of the named roles, in order, skipping
+// unknown names. Permissions are combined as by Merge, a permission the
+// roles define in contradicting ways keeps the definition of the first of
+// them and the conflicts are returned as an error wrapping
+// ErrMergeConflict. A subject's roles are combined rather than rejected,
+// so one conflicting role can't take the access of the others away.
+func mergeNamed(roles Roles, names []string) (Role, bool, error) {
+	known := make([]string, 0, len(names))
+	for _, name := range names {
+		if _, ok := roles[name]; ok {
+			known = append(known, name)
+		}
+	}
+	if len(known) == 0 {
+		return nil, false, nil
+	}
+	if len(known) == 1 {
+		return roles[known[0]], true, nil
+	}
+
+	merged := roles[known[0]].Clone()
+	var errs []error
+	for _, name := range known[1:] {
+		role := roles[name]
+		for _, key := range sortedKeys(role) {
+			existing, ok := merged[key]
+			if !ok {
+				merged[key] = role[key].Clone()
+				continue
+			}
+
+			combined, err := mergePermission(existing, role[key])
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%w: role %q permission %q: %v", ErrMergeConflict, name, key, err))
+				continue
+			}
+			merged[key] = combined
+		}
+	}
+
+	return merged, true, errors.Join(errs...)
+}
+
+// Set replaces the current set of roles, recording them as a new
+// version (see Versions) unless their contents are unchanged.
+// r - the new set of roles
+func (s *Store) Set(r Roles) {
+	if r == nil {
+		r = make(Roles)
+	}
+
+	revision := rolesRevision(r)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.versions) > 0 && revision == s.load().revision {
+		return
+	}
+
+	v := PolicyVersion{Version: 1, Revision: revision, Time: Now(), Roles: r}
+	if n := len(s.versions); n > 0 {
+		v.Version = s.versions[n-1].Version + 1
+	}
+	history := s.History
+	if history <= 0 {
+		history = 10
+	}
+	s.versions = append(s.versions, v)
+	if len(s.versions) > history {
+		s.versions = append([]PolicyVersion(nil), s.versions[len(s.versions)-history:]...)
+	}
+
+	s.publish(r, revision, v.Version)
+}
+
+// Revision returns an identifier of the current set of roles. It is
+// derived from their contents, so every instance serving the same policy
+// reports the same revision.
+func (s *Store) Revision() string {
+	return s.load().revision
+}
+
+// Grant adds abilities on a permission to the named role, creating the
+// role and permission if needed.
+//
+// ctx - a standard ctx passed to the Persister
+//
+// role - the name of the role
+//
+// permission - the permission key
+//
+// abilities - the abilities to add
+//
+// returns - the error from the Persister, in which case nothing changed
+func (s *Store) Grant(ctx context.Context, role, permission string, abilities ...Ability) error {
+	return s.update(ctx, role, func(r Role) {
+		p := r[permission]
+		if p.Abilities == nil {
+			p.Abilities = make(map[Ability]struct{})
+		}
+		for _, a := range abilities {
+			p.Abilities[a] = struct{}{}
+		}
+		r[permission] = p
+	})
+}
+
+// Revoke removes abilities on a permission from the named role. The
+// permission is removed once it has no abilities left, or when no
+// abilities are given.
+func (s *Store) Revoke(ctx context.Context, role, permission string, abilities ...Ability) error {
+	return s.update(ctx, role, func(r Role) {
+		p, ok := r[permission]
+		if !ok {
+			return
+		}
+		for _, a := range abilities {
+			delete(p.Abilities, a)
+		}
+		if len(abilities) == 0 || len(p.Abilities) == 0 {
+			delete(r, permission)
+		}
+	})
+}
+
+// update applies fn to a copy of the named role and publishes the result
+// according to the Store's Persister and Consistency.
+func (s *Store) update(ctx context.Context, name string, fn func(r Role)) error {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	st := s.load()
+	current := s.base(st)
+	next := make(Roles, len(current)+1)
+	for k, v := range current {
+		next[k] = v
+	}
+
+	role := current[name].Clone()
+	if role == nil {
+		role = make(Role)
+	}
+	fn(role)
+	next[name] = role
+
+	if s.Persister != nil {
+		if err := s.Persister.Save(ctx, next); err != nil {
+			return err
+		}
+		if s.Consistency == Eventual {
+			if len(s.pending) == 0 {
+				s.pending = []string{st.revision}
+			}
+			s.saved, s.pending = next, append(s.pending, rolesRevision(next))
+			return nil
+		}
+	}
+	s.saved, s.pending = nil, nil
+
+	s.Set(next)
+	return nil
+}
+
+// base returns the roles a change applies to: the roles last saved while
+// the served roles are the ones they were saved over or one of the saves
+// since, and the served roles once something else was applied. Must be
+// called with wmu held.
+func (s *Store) base(st *storeState) Roles {
+	for i, rev := range s.pending {
+		if rev == st.revision {
+			s.pending = s.pending[i:]
+			return s.saved
+		}
+	}
+	s.saved, s.pending = nil, nil
+
+	return st.roles
+}
+
+// Can looks up the named role and calls Can with it. Unknown roles are
+// never allowed.
+func (s *Store) Can(ctx context.Context, name string, permission string, ability Ability, compare func() bool) bool {
+	role, ok := s.Role(name)
+	if !ok {
+		return false
+	}
+
+	return Can(ctx, role, permission, ability, compare)
+}
+
+// rolesRevision returns a short hash of the roles' contents.
+func rolesRevision(r Roles) string {
+	names := make([]string, 0, len(r))
+	for name := range r {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := fnv.New64a()
+	for _, name := range names {
+		fmt.Fprintf(h, "%s\x00%d\x00", name, roleHash(r[name]))
+	}
+
+	return fmt.Sprintf("%016x", h.Sum64())
+}
@@ -0,0 +1,243 @@
+package can
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	r, err := OpenFile("testdata/rbac.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewStore(nil)
+	if s.Can(context.Background(), "admin", "users", Read, nil) {
+		t.Fatal("expected empty store to deny")
+	}
+
+	s.Set(r)
+	if !s.Can(context.Background(), "admin", "users", Read, nil) {
+		t.Fatal("expected admin to read users")
+	}
+
+	if _, ok := s.Role("missing"); ok {
+		t.Fatal("expected missing role")
+	}
+}
+
+func TestStoreRevision(t *testing.T) {
+	a, err := OpenFile("testdata/rbac.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := OpenFile("testdata/rbac.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewStore(a)
+	if s.Revision() != NewStore(b).Revision() {
+		t.Fatal("expected identical roles to share a revision")
+	}
+
+	before := s.Revision()
+	delete(b, "user")
+	s.Set(b)
+	if s.Revision() == before {
+		t.Fatal("expected revision to change with the roles")
+	}
+}
+
+type memoryPersister struct {
+	saved Roles
+	err   error
+}
+
+func (m *memoryPersister) Save(ctx context.Context, r Roles) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.saved = r
+	return nil
+}
+
+func TestStoreConsistency(t *testing.T) {
+	ctx := context.Background()
+
+	p := &memoryPersister{}
+	s := NewStore(nil)
+	s.Persister = p
+
+	if err := s.Grant(ctx, "user", "users", Read); err != nil {
+		t.Fatal(err)
+	}
+	if s.Can(ctx, "user", "users", Read, Compare(1, 1)) {
+		t.Fatal("expected eventual store to wait for the backend")
+	}
+	if !Can(ctx, p.saved["user"], "users", Read, Compare(1, 1)) {
+		t.Fatal("expected grant to be saved")
+	}
+	s.Set(p.saved) // what Watch does once the backend reports the change
+
+	s.Consistency = ReadYourWrites
+	if err := s.Grant(ctx, "user", "users", Update); err != nil {
+		t.Fatal(err)
+	}
+	if !s.Can(ctx, "user", "users", Update, Compare(1, 1)) {
+		t.Fatal("expected grant to be visible right away")
+	}
+
+	p.err = errors.New("backend down")
+	if err := s.Revoke(ctx, "user", "users"); err == nil {
+		t.Fatal("expected persister error")
+	}
+	if !s.Can(ctx, "user", "users", Update, Compare(1, 1)) {
+		t.Fatal("expected failed revoke to leave roles untouched")
+	}
+
+	p.err = nil
+	if err := s.Revoke(ctx, "user", "users", Update); err != nil {
+		t.Fatal(err)
+	}
+	if s.Can(ctx, "user", "users", Update, Compare(1, 1)) || !s.Can(ctx, "user", "users", Read, Compare(1, 1)) {
+		t.Fatal("expected only update to be revoked")
+	}
+}
+
+func TestStoreEventualGrants(t *testing.T) {
+	ctx := context.Background()
+	p := &memoryPersister{}
+	s := NewStore(Roles{"user": NewRole().Allow("users", Read).Build()})
+	s.Persister = p
+
+	// both grants are saved before the backend reports the first one
+	if err := s.Grant(ctx, "user", "books", Read); err != nil {
+		t.Fatal(err)
+	}
+	first := p.saved
+	if err := s.Grant(ctx, "user", "books", Update); err != nil {
+		t.Fatal(err)
+	}
+	if !Can(ctx, p.saved["user"], "books", Read, Compare(1, 1)) || !Can(ctx, p.saved["user"], "books", Update, Compare(1, 1)) {
+		t.Fatalf("expected the second grant to keep the first, got %v", p.saved["user"])
+	}
+
+	s.Set(first)
+	if err := s.Revoke(ctx, "user", "users"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p.saved["user"]["users"]; ok || !Can(ctx, p.saved["user"], "books", Update, Compare(1, 1)) {
+		t.Fatalf("expected changes not sent back yet to be kept, got %v", p.saved["user"])
+	}
+
+	// a change from elsewhere is the base of the next one
+	s.Set(Roles{"admin": NewRole().Allow("users", All).Build()})
+	if err := s.Grant(ctx, "user", "books", Read); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p.saved["admin"]; !ok || !Can(ctx, p.saved["user"], "books", Read, Compare(1, 1)) || Can(ctx, p.saved["user"], "books", Update, Compare(1, 1)) {
+		t.Fatalf("expected the grant to apply to the served roles, got %v", p.saved)
+	}
+}
+
+func TestStoreSnapshots(t *testing.T) {
+	s := NewStore(Roles{
+		"reader": NewRole().Allow("books", Read).Build(),
+		"writer": NewRole().Allow("books", Update).Build(),
+	})
+	s.Precompute(Subject{ID: "u1", Roles: []string{"reader", "writer"}})
+	if s.load().size.Load() != 1 {
+		t.Fatalf("expected one snapshot, got %d", s.load().size.Load())
+	}
+
+	// subjects with the same roles share the snapshot
+	role, ok := s.SubjectRole(Subject{ID: "u2", Roles: []string{"writer", "reader"}})
+	if !ok || s.load().size.Load() != 1 || !Can(context.Background(), role, "books", Update, func() bool { return true }) {
+		t.Fatalf("expected the merged role from the snapshot, got %v", role)
+	}
+
+	if err := s.Revoke(context.Background(), "writer", "books"); err != nil {
+		t.Fatal(err)
+	}
+	role, _ = s.SubjectRole(Subject{ID: "u2", Roles: []string{"writer", "reader"}})
+	if Can(context.Background(), role, "books", Update, func() bool { return true }) {
+		t.Fatal("expected a change of the roles to invalidate the snapshot")
+	}
+
+	s.MaxSnapshots = 1
+	s.SubjectRole(Subject{Roles: []string{"reader"}})
+	s.SubjectRole(Subject{Roles: []string{"writer"}})
+	if s.load().size.Load() != 1 {
+		t.Fatalf("expected snapshots to be bounded, got %d", s.load().size.Load())
+	}
+}
+
+// mutexStore is the hot path of a Store guarding its roles with a
+// sync.RWMutex, the design Store replaced, for comparison.
+type mutexStore struct {
+	mu    sync.RWMutex
+	roles Roles
+}
+
+func (s *mutexStore) Can(ctx context.Context, name string, permission string, ability Ability, compare func() bool) bool {
+	s.mu.RLock()
+	role, ok := s.roles[name]
+	s.mu.RUnlock()
+
+	return ok && Can(ctx, role, permission, ability, compare)
+}
+
+func (s *mutexStore) Set(r Roles) {
+	s.mu.Lock()
+	s.roles = r
+	s.mu.Unlock()
+}
+
+// benchmarkStore runs checks on every P while roles are being set in the
+// background.
+func benchmarkStore(b *testing.B, can func(ctx context.Context, name string, permission string, ability Ability, compare func() bool) bool, set func(Roles)) {
+	// two different sets of roles, so every set publishes new roles
+	versions := []Roles{
+		{"user": NewRole().Allow("books", Read).Build()},
+		{"user": NewRole().Allow("books", Read, Update).Build()},
+	}
+	set(versions[0])
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+				set(versions[i%2])
+			}
+		}
+	}()
+
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if !can(ctx, "user", "books", Read, func() bool { return true }) {
+				b.Error("expected the check to be allowed")
+				return
+			}
+		}
+	})
+}
+
+func BenchmarkStoreCan(b *testing.B) {
+	s := NewStore(nil)
+	benchmarkStore(b, s.Can, s.Set)
+}
+
+func BenchmarkMutexStoreCan(b *testing.B) {
+	s := &mutexStore{}
+	benchmarkStore(b, s.Can, s.Set)
+}
@@ -0,0 +1,60 @@
+package can
+
+import (
+	"context"
+	"net/http"
+)
+
+// Upgrade authorizes long lived connections, such as WebSocket upgrades
+// and server-sent event streams, before calling next. The connection is
+// checked like a Middleware read of its path. The role it was allowed
+// with is stored in the request context (see RoleFrom), for the handler
+// to authorize every message with CanMessage:
+//
+//	mux.Handle("GET /events", guard.Upgrade(events))
+//
+//	func events(w http.ResponseWriter, r *http.Request) {
+//		role, _ := can.RoleFrom(r.Context())
+//		for e := range feed {
+//			if can.CanMessage(r.Context(), role, e.Topic, can.Read) {
+//				send(w, e)
+//			}
+//		}
+//	}
+//
+// The role is resolved once per connection. Handlers of connections that
+// outlive role changes should resolve it again, e.g. with
+// Store.SubjectRole, and close the connection when the subject lost access.
+func (g *Guard) Upgrade(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !g.Check(w, r, g.permission(r), Read) {
+			return
+		}
+
+		if role, _ := g.role(r.Context()); role != nil {
+			r = r.WithContext(WithRole(r.Context(), role))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// CanMessage checks whether role may use ability on the messages of a
+// topic, such as Read to receive them or Create to publish them. Topics
+// are permission keys, so the roles of a REST API apply to its event
+// streams too, and hierarchical topics such as orders/42 fall back to
+// their parents (see HierarchySeparator). There is no ownership check,
+// abilities held on the topic are allowed.
+//
+// ctx - a standard ctx
+//
+// role - the role of the connection
+//
+// topic - the topic of the message
+//
+// ability - the ability to check
+//
+// returns - true if the message is allowed
+func CanMessage(ctx context.Context, role Role, topic string, ability Ability) bool {
+	return Can(ctx, role, topic, ability, func() bool { return true })
+}
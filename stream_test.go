@@ -0,0 +1,49 @@
+package can
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUpgrade(t *testing.T) {
+	roles := Roles{
+		"user":  NewRole().Allow("events", Read).Allow("orders", Read).Deny("orders/internal", Read).Build(),
+		"guest": NewRole().Allow("orders", Read).Build(),
+	}
+
+	var received []string
+	h := (&Guard{Store: NewStore(roles)}).Upgrade(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		role, ok := RoleFrom(r.Context())
+		if !ok {
+			t.Fatal("expected the role of the connection in the request context")
+		}
+		for _, topic := range []string{"orders/42", "orders/internal", "payments/1"} {
+			if CanMessage(r.Context(), role, topic, Read) {
+				received = append(received, topic)
+			}
+		}
+	}))
+
+	for _, tt := range []struct {
+		role   string
+		status int
+	}{{"user", http.StatusOK}, {"guest", http.StatusForbidden}} {
+		req := httptest.NewRequest(http.MethodGet, "/events", nil)
+		req = req.WithContext(WithSubject(req.Context(), Subject{ID: "u1", Roles: []string{tt.role}}))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != tt.status {
+			t.Fatalf("expected %s to get %d at upgrade, got %d", tt.role, tt.status, w.Code)
+		}
+	}
+
+	if len(received) != 1 || received[0] != "orders/42" {
+		t.Fatalf("expected only orders/42 to be received, got %v", received)
+	}
+
+	if CanMessage(context.Background(), roles["user"], "orders", Create) {
+		t.Fatal("expected publishing without the create ability to be denied")
+	}
+}
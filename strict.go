@@ -0,0 +1,182 @@
+package can
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrUnknownField is returned for keys of a permission in a role file
+// that aren't fields of DiskPermission, such as a misspelled abilites.
+var ErrUnknownField = errors.New("can: unknown field")
+
+// Strict makes OpenFile, Decode and the yaml decoding of Roles reject
+// unknown permission fields and report unknown abilities with their
+// position in the file, because a misspelled key would otherwise load as
+// a permission granting nothing. It defaults to true, set it to false to
+// ignore unknown fields, e.g. while rolling out a newer file format.
+var Strict = true
+
+// PositionError locates a problem of a role file.
+type PositionError struct {
+	// File is the name of the file, empty for roles that weren't read
+	// from a file, such as with Decode.
+	File   string
+	Line   int
+	Column int
+	Err    error
+}
+
+// Error implements the error interface, formatting the position like
+// the go tools do, e.g. rbac.yml:14:9.
+func (e *PositionError) Error() string {
+	if e.File == "" {
+		return fmt.Sprintf("line %d, column %d: %v", e.Line, e.Column, e.Err)
+	}
+
+	return fmt.Sprintf("%s:%d:%d: %v", e.File, e.Line, e.Column, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *PositionError) Unwrap() error {
+	return e.Err
+}
+
+// diskPermissionFields are the yaml keys of DiskPermission.
+var diskPermissionFields = func() map[string]struct{} {
+	fields := make(map[string]struct{})
+	t := reflect.TypeOf(DiskPermission{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		fields[name] = struct{}{}
+	}
+
+	return fields
+}()
+
+// checkNode reports the unknown fields and abilities of a yaml document
+// of roles, reserved keys already split off, unless Strict is false.
+// Every problem is reported as a PositionError, joined with errors.Join.
+func checkNode(doc *yaml.Node) error {
+	if !Strict {
+		return nil
+	}
+
+	var errs []error
+	at := func(n *yaml.Node, err error) {
+		errs = append(errs, &PositionError{Line: n.Line, Column: n.Column, Err: err})
+	}
+
+	root := resolve(doc)
+	if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		root = resolve(root.Content[0])
+	}
+
+	eachPair(root, func(roleKey, role *yaml.Node) {
+		eachPair(role, func(resourceKey, permission *yaml.Node) {
+			where := fmt.Sprintf("role %q resource %q", roleKey.Value, resourceKey.Value)
+			eachPair(permission, func(key, value *yaml.Node) {
+				if _, ok := diskPermissionFields[key.Value]; !ok {
+					at(key, fmt.Errorf("%w %q (%s)", ErrUnknownField, key.Value, where))
+					return
+				}
+
+				switch key.Value {
+				case "abilities", "deny", "audit":
+					for _, item := range resolve(value).Content {
+						if item = resolve(item); item.Kind == yaml.ScalarNode && parseAbility(item.Value) == None {
+							at(item, fmt.Errorf("%w %q (%s %s)", ErrUnknownAbility, item.Value, where, key.Value))
+						}
+					}
+				case "fields", "quotas":
+					eachPair(value, func(ability, _ *yaml.Node) {
+						if parseAbility(ability.Value) == None {
+							at(ability, fmt.Errorf("%w %q (%s %s)", ErrUnknownAbility, ability.Value, where, key.Value))
+						}
+					})
+				}
+			})
+		})
+	})
+
+	return errors.Join(errs...)
+}
+
+// eachPair calls fn with the keys and values of a mapping node, skipping
+// yaml merge keys. Other nodes are ignored and left to the decoder.
+func eachPair(n *yaml.Node, fn func(key, value *yaml.Node)) {
+	n = resolve(n)
+	if n.Kind != yaml.MappingNode {
+		return
+	}
+
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == "<<" {
+			continue
+		}
+		fn(n.Content[i], n.Content[i+1])
+	}
+}
+
+// resolve follows yaml aliases to the node they refer to.
+func resolve(n *yaml.Node) *yaml.Node {
+	for n.Kind == yaml.AliasNode && n.Alias != nil {
+		n = n.Alias
+	}
+
+	return n
+}
+
+// withPositions combines the problems checkNode found with those of
+// buildRole, whose unknown abilities checkNode already located.
+func withPositions(positions, err error) error {
+	if positions == nil {
+		return err
+	}
+	if err == nil {
+		return positions
+	}
+
+	errs := []error{positions}
+	for _, e := range leaves(err) {
+		if !errors.Is(e, ErrUnknownAbility) {
+			errs = append(errs, e)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// inFile attributes the problems of a role file to it: positions get its
+// name, the other problems are prefixed with it.
+func inFile(err error, file string) error {
+	var errs []error
+	for _, e := range leaves(err) {
+		if pe, ok := e.(*PositionError); ok {
+			pe.File = file
+			errs = append(errs, pe)
+			continue
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", file, e))
+	}
+
+	return errors.Join(errs...)
+}
+
+// leaves flattens errors joined with errors.Join.
+func leaves(err error) []error {
+	j, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return []error{err}
+	}
+
+	var errs []error
+	for _, e := range j.Unwrap() {
+		errs = append(errs, leaves(e)...)
+	}
+
+	return errs
+}
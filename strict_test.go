@@ -0,0 +1,62 @@
+package can
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStrict(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "rbac.yml")
+	yml := `user:
+  users:
+    abilities: [raed]
+    abilites: [update]
+  books:
+    abilities: [read]
+    routes: [search]
+    fields:
+      updat: [title]
+`
+	if err := os.WriteFile(file, []byte(yml), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := OpenFile(file)
+	if !errors.Is(err, ErrUnknownAbility) || !errors.Is(err, ErrUnknownField) {
+		t.Fatalf("expected unknown abilities and fields, got %v", err)
+	}
+	for _, want := range []string{
+		file + `:3:17: can: unknown ability "raed"`,
+		file + `:4:5: can: unknown field "abilites"`,
+		file + `:9:7: can: unknown ability "updat"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected the error to contain %q, got:\n%v", want, err)
+		}
+	}
+	if n := strings.Count(err.Error(), "raed"); n != 1 {
+		t.Errorf("expected the unknown ability to be reported once, got:\n%v", err)
+	}
+
+	var pe *PositionError
+	if !errors.As(err, &pe) || pe.File != file || pe.Line == 0 {
+		t.Fatalf("expected a position, got %#v", pe)
+	}
+
+	if _, err := Decode(strings.NewReader(yml)); err == nil || !strings.Contains(err.Error(), "line 4, column 5") {
+		t.Fatalf("expected the position without a file, got %v", err)
+	}
+
+	defer func() { Strict = true }()
+	Strict = false
+	r, err := Decode(strings.NewReader("user:\n  users:\n    abilities: [read]\n    future: true\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := r["user"]["users"]; !ok {
+		t.Fatal("expected unknown fields to be ignored")
+	}
+}
@@ -0,0 +1,16 @@
+package can
+
+import "slices"
+
+// SyntheticRole is the role of synthetic monitoring clients, such as
+// uptime checks. Give it the permissions the checks need like any other
+// role: their requests take the full authorization path, but a Guard
+// marks their decisions as Synthetic so audit sinks and access analytics
+// can tell them apart from real traffic.
+const SyntheticRole = "synthetic"
+
+// Synthetic reports whether the subject is a synthetic monitoring client,
+// that is whether it holds SyntheticRole.
+func (s Subject) Synthetic() bool {
+	return slices.Contains(s.Roles, SyntheticRole)
+}
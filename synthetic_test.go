@@ -0,0 +1,33 @@
+package can
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSynthetic(t *testing.T) {
+	roles := Roles{
+		SyntheticRole: NewRole().Allow("health", Read).Build(),
+		"user":        NewRole().Allow("health", Read).Build(),
+	}
+
+	var audited []Decision
+	g := &Guard{Store: NewStore(roles), Audit: func(ctx context.Context, d Decision) error {
+		audited = append(audited, d)
+		return nil
+	}}
+
+	for _, sub := range []Subject{{ID: "uptime", Roles: []string{SyntheticRole}}, {ID: "u1", Roles: []string{"user"}}} {
+		req := httptest.NewRequest(http.MethodGet, "/health", nil)
+		req = req.WithContext(WithSubject(req.Context(), sub))
+		if !g.Check(httptest.NewRecorder(), req, "health", Read) {
+			t.Fatalf("expected %s to be allowed", sub.ID)
+		}
+	}
+
+	if len(audited) != 2 || !audited[0].Synthetic || audited[1].Synthetic {
+		t.Fatalf("expected only the synthetic check to be marked, got %+v", audited)
+	}
+}
@@ -0,0 +1,316 @@
+package can
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TemplatesKey is the top level key of a role file holding role
+// templates, roles whose names and values contain {param} placeholders:
+//
+//	templates:
+//	  project_member:
+//	    project_{project}:
+//	      abilities: [read, update]
+//	      labels:
+//	        project: ["{project}"]
+//
+// Templates are not roles themselves, OpenFile and Decode skip them.
+// Load them with OpenTemplates and create roles with Instantiate. The
+// key is reserved and can't be used as a role name.
+const TemplatesKey = "templates"
+
+var (
+	// ErrUnknownTemplate is returned by Instantiate for a template that
+	// doesn't exist.
+	ErrUnknownTemplate = errors.New("can: unknown template")
+	// ErrMissingParameter is returned by Instantiate when a placeholder
+	// has no value.
+	ErrMissingParameter = errors.New("can: missing template parameter")
+	// ErrInvalidParameter is returned by Instantiate for a value that
+	// would widen a permission key, such as * or a value holding a
+	// separator.
+	ErrInvalidParameter = errors.New("can: invalid template parameter")
+)
+
+// Templates is a set of role templates keyed by name.
+type Templates map[string]DiskRole
+
+// OpenTemplates reads the templates of a yaml role file. The file's
+// roles and includes are ignored.
+// filename - yaml encoded file for parsing
+//
+// returns - the templates, empty if the file has none, and an error
+func OpenTemplates(filename string) (Templates, error) {
+	f, err := os.OpenFile(filename, os.O_RDONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	t, err := DecodeTemplates(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	return t, nil
+}
+
+// DecodeTemplates reads the templates of yaml encoded roles from r.
+func DecodeTemplates(r io.Reader) (Templates, error) {
+	t := make(Templates)
+	if err := decodeKey(r, TemplatesKey, &t); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// decodeKey decodes the value of a top level key of yaml encoded roles
+// into v, leaving v untouched if there is no such key.
+func decodeKey(r io.Reader, key string, v any) error {
+	var doc yaml.Node
+	if err := yaml.NewDecoder(r).Decode(&doc); err != nil {
+		return err
+	}
+	if err := expandNode(&doc); err != nil {
+		return err
+	}
+
+	if _, value := splitKey(&doc, key); value != nil {
+		return value.Decode(v)
+	}
+
+	return nil
+}
+
+// splitReserved removes the top level keys holding something other
+// than roles from a decoded yaml document.
+func splitReserved(doc *yaml.Node) {
+	splitKey(doc, TemplatesKey)
+	splitKey(doc, GroupsKey)
+	splitKey(doc, AliasesKey)
+}
+
+// Instantiate creates a role from the named template by replacing every
+// {param} placeholder with its value in params. In routes, placeholders
+// without a value are kept as url params, as in users/{id}/books. Values
+// substituted into permission keys can't be empty, * or hold
+// HierarchySeparator or Separator, so they name a single resource.
+//
+// name - the name of the template
+//
+// params - the placeholder values, e.g. {"project": "42"}
+//
+// returns - the role and an error if the template doesn't exist, a
+// placeholder has no value or the resulting role is invalid
+func (t Templates) Instantiate(name string, params map[string]string) (Role, error) {
+	tmpl, ok := t[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTemplate, name)
+	}
+
+	sub := substitution{params: params}
+	role := make(DiskRole, len(tmpl))
+	for resource, p := range tmpl {
+		resource, err := sub.key(resource)
+		if err != nil {
+			return nil, err
+		}
+		if role[resource], err = p.substitute(sub); err != nil {
+			return nil, err
+		}
+	}
+
+	r, err := ConfigErr(DiskRoles{name: role})
+	if err != nil {
+		return nil, err
+	}
+
+	return r[name], nil
+}
+
+// substitute replaces the placeholders of every string of the
+// permission, except the ability and label names of its maps. Every
+// field must be copied, so templates can't drop the restrictions of a
+// grant.
+func (p DiskPermission) substitute(subst substitution) (DiskPermission, error) {
+	sub := subst.value
+	var q DiskPermission
+	var err error
+	if q.Resource, err = subst.key(p.Resource); err != nil {
+		return q, err
+	}
+	if q.Abilities, err = substituteAll(p.Abilities, sub); err != nil {
+		return q, err
+	}
+	if q.Routes, err = substituteAll(p.Routes, subst.route); err != nil {
+		return q, err
+	}
+	if q.Environments, err = substituteAll(p.Environments, sub); err != nil {
+		return q, err
+	}
+	if q.Deny, err = substituteAll(p.Deny, sub); err != nil {
+		return q, err
+	}
+	if q.Conditions, err = substituteAll(p.Conditions, sub); err != nil {
+		return q, err
+	}
+	if q.Audit, err = substituteAll(p.Audit, sub); err != nil {
+		return q, err
+	}
+	if q.CIDR, err = substituteAll(p.CIDR, sub); err != nil {
+		return q, err
+	}
+	if q.Fields, err = substituteLists(p.Fields, sub); err != nil {
+		return q, err
+	}
+	if q.Labels, err = substituteLists(p.Labels, sub); err != nil {
+		return q, err
+	}
+	if p.Quotas != nil {
+		q.Quotas = make(map[string]string, len(p.Quotas))
+		for ability, quota := range p.Quotas {
+			if q.Quotas[ability], err = sub(quota); err != nil {
+				return q, err
+			}
+		}
+	}
+
+	return q, nil
+}
+
+// substituteLists calls sub on every string of the lists of m.
+func substituteLists(m map[string][]string, sub func(string) (string, error)) (map[string][]string, error) {
+	if m == nil {
+		return nil, nil
+	}
+
+	out := make(map[string][]string, len(m))
+	for k, list := range m {
+		var err error
+		if out[k], err = substituteAll(list, sub); err != nil {
+			return nil, err
+		}
+	}
+
+	return out, nil
+}
+
+// substituteAll calls sub on every string of list.
+func substituteAll(list []string, sub func(string) (string, error)) ([]string, error) {
+	if list == nil {
+		return nil, nil
+	}
+
+	out := make([]string, len(list))
+	for i, s := range list {
+		v, err := sub(s)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = v
+	}
+
+	return out, nil
+}
+
+// substitution replaces the {param} placeholders of a template.
+type substitution struct {
+	params map[string]string
+}
+
+// value substitutes the placeholders of s with any value.
+func (t substitution) value(s string) (string, error) {
+	return substitute(s, t.params, false, false)
+}
+
+// key substitutes the placeholders of a permission key, rejecting values
+// that would widen it.
+func (t substitution) key(s string) (string, error) {
+	return substitute(s, t.params, true, false)
+}
+
+// route is key for route templates, where placeholders without a value
+// are url params.
+func (t substitution) route(s string) (string, error) {
+	return substitute(s, t.params, true, true)
+}
+
+// substitute replaces the {param} placeholders in s. Braces that don't
+// enclose a parameter name are kept as is, and so are placeholders
+// without a value when routeParams is set. When key is set, the values
+// must name a single resource (see Instantiate).
+func substitute(s string, params map[string]string, key, routeParams bool) (string, error) {
+	if !strings.Contains(s, "{") {
+		return s, nil
+	}
+	template := s
+
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(s, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(s[start:], '}')
+		if end < 0 {
+			break
+		}
+		end += start
+
+		name := s[start+1 : end]
+		v, ok := params[name]
+		if !isParamName(name) || (!ok && routeParams) {
+			b.WriteString(s[:start+1])
+			s = s[start+1:]
+			continue
+		}
+		if !ok {
+			return "", fmt.Errorf("%w: %s", ErrMissingParameter, name)
+		}
+		if key && !isKeyValue(v) {
+			return "", fmt.Errorf("%w: %s=%q would widen %q", ErrInvalidParameter, name, v, template)
+		}
+		b.WriteString(s[:start])
+		b.WriteString(v)
+		s = s[end+1:]
+	}
+	b.WriteString(s)
+
+	return b.String(), nil
+}
+
+// isKeyValue reports whether v can replace a placeholder of a permission
+// key without matching other resources.
+func isKeyValue(v string) bool {
+	switch {
+	case v == "" || v == Wildcard || strings.ContainsAny(v, "{}"):
+		return false
+	case HierarchySeparator != "" && strings.Contains(v, HierarchySeparator):
+		return false
+	case Separator != "" && strings.Contains(v, Separator):
+		return false
+	}
+
+	return true
+}
+
+// isParamName reports whether s is a valid placeholder name.
+func isParamName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c != '_' && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,143 @@
+package can
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestTemplates(t *testing.T) {
+	r, err := OpenFile("testdata/templates.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := r[TemplatesKey]; ok || len(r) != 1 {
+		t.Fatalf("expected templates to be skipped, got %v", r)
+	}
+
+	tmpl, err := OpenTemplates("testdata/templates.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	role, err := tmpl.Instantiate("project_member", map[string]string{"project": "42"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	yes := func() bool { return true }
+	if !Can(ctx, role, "project_42", Update, yes) || !Can(ctx, role, "project_42_issues", Read, yes) {
+		t.Fatalf("expected the project to be granted, got %v", role)
+	}
+	if Can(ctx, role, "project_42", Delete, yes) || Can(ctx, role, "project_7", Read, yes) {
+		t.Fatal("expected only the instantiated project to be granted")
+	}
+	if !Can(WithLabels(ctx, Labels{"project": "42"}), role, "billing", Read, yes) {
+		t.Fatal("expected labels to be instantiated")
+	}
+
+	if _, err := tmpl.Instantiate("project_member", nil); !errors.Is(err, ErrMissingParameter) {
+		t.Fatalf("expected ErrMissingParameter, got %v", err)
+	}
+	if _, err := tmpl.Instantiate("nope", nil); !errors.Is(err, ErrUnknownTemplate) {
+		t.Fatalf("expected ErrUnknownTemplate, got %v", err)
+	}
+}
+
+func TestSubstitute(t *testing.T) {
+	got, err := substitute("a_{x}_{ y }_{}_{x}", map[string]string{"x": "1"}, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "a_1_{ y }_{}_1" {
+		t.Fatalf("unexpected substitution %q", got)
+	}
+}
+
+func TestInstantiateRestrictions(t *testing.T) {
+	tmpl := Templates{"vpn": DiskRole{"project_{project}": DiskPermission{
+		Abilities:  []string{"all"},
+		Conditions: []string{"{condition}"},
+		Audit:      []string{"delete"},
+		Quotas:     map[string]string{"create": "{limit}/day"},
+		CIDR:       []string{"{network}"},
+	}}}
+
+	role, err := tmpl.Instantiate("vpn", map[string]string{"project": "42", "condition": "owner", "limit": "5", "network": "10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := role["project_42"]
+	if len(p.Conditions) != 1 || p.Conditions[0] != "owner" || !p.RequiresAudit(Delete) || p.Quotas[Create].Limit != 5 || len(p.Networks) != 1 {
+		t.Fatalf("expected the restrictions of the template, got %+v", p)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/project/42", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	if Can(WithRequest(context.Background(), req), role, "project_42", Read, func() bool { return true }) {
+		t.Fatal("expected the cidr of the template to be enforced")
+	}
+}
+
+func TestInstantiateParameters(t *testing.T) {
+	tmpl := Templates{"member": DiskRole{"projects/{project}": DiskPermission{
+		Abilities: []string{"read"},
+		Routes:    []string{"{id}/books"},
+		Labels:    map[string][]string{"owner": {"{owner}"}},
+	}}}
+
+	role, err := tmpl.Instantiate("member", map[string]string{"project": "42", "owner": "a_b/c"})
+	if err != nil {
+		t.Fatalf("expected route params to be kept, got %v", err)
+	}
+	if _, ok := role["projects/42_books"]; !ok {
+		t.Fatalf("expected the route param to be dropped from the key, got %v", role)
+	}
+
+	for _, v := range []string{"*", "", "7/secrets", "7_books", "{x}"} {
+		_, err := tmpl.Instantiate("member", map[string]string{"project": v, "owner": "o"})
+		if !errors.Is(err, ErrInvalidParameter) {
+			t.Errorf("%q: expected ErrInvalidParameter, got %v", v, err)
+		}
+	}
+	if _, err := tmpl.Instantiate("member", map[string]string{"project": "42"}); !errors.Is(err, ErrMissingParameter) {
+		t.Fatalf("expected the labels to require their params, got %v", err)
+	}
+}
+
+// TestSubstitutePermission fails when a field of DiskPermission is added
+// without Instantiate copying it.
+func TestSubstitutePermission(t *testing.T) {
+	var p, want DiskPermission
+	pv, wv := reflect.ValueOf(&p).Elem(), reflect.ValueOf(&want).Elem()
+	for i := 0; i < pv.NumField(); i++ {
+		switch f := pv.Field(i); f.Interface().(type) {
+		case string:
+			f.SetString("{x}")
+			wv.Field(i).SetString("1")
+		case []string:
+			f.Set(reflect.ValueOf([]string{"{x}"}))
+			wv.Field(i).Set(reflect.ValueOf([]string{"1"}))
+		case map[string]string:
+			f.Set(reflect.ValueOf(map[string]string{"k": "{x}"}))
+			wv.Field(i).Set(reflect.ValueOf(map[string]string{"k": "1"}))
+		case map[string][]string:
+			f.Set(reflect.ValueOf(map[string][]string{"k": {"{x}"}}))
+			wv.Field(i).Set(reflect.ValueOf(map[string][]string{"k": {"1"}}))
+		default:
+			t.Fatalf("field %s has a type the test doesn't fill", pv.Type().Field(i).Name)
+		}
+	}
+
+	got, err := p.substitute(substitution{params: map[string]string{"x": "1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected every field to be substituted, got %+v, want %+v", got, want)
+	}
+}
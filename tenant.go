@@ -0,0 +1,77 @@
+package can
+
+import (
+	"context"
+	"net/http"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TenantRoles holds a set of Roles per tenant, so the same role name can
+// carry different permissions for every tenant. In yaml the tenants are
+// the top level keys, each holding roles in the same format as OpenFile.
+type TenantRoles map[string]Roles
+
+// UnmarshalYAML implement the yaml Unmarshaler interface
+func (t TenantRoles) UnmarshalYAML(value *yaml.Node) error {
+	var diskYaml map[string]DiskRoles
+	if err := value.Decode(&diskYaml); err != nil {
+		return err
+	}
+
+	for tenant, diskRoles := range diskYaml {
+		r, err := ConfigErr(diskRoles)
+		if err != nil {
+			return err
+		}
+		t[tenant] = r
+	}
+
+	return nil
+}
+
+// Role returns the named role of the tenant stored in ctx (see WithTenant).
+//
+// ctx - a ctx carrying the tenant
+//
+// name - the name of the role
+//
+// returns - the role and true, or nil and false if the tenant or role is unknown
+func (t TenantRoles) Role(ctx context.Context, name string) (Role, bool) {
+	tenant, ok := TenantFrom(ctx)
+	if !ok {
+		return nil, false
+	}
+
+	role, ok := t[tenant][name]
+	return role, ok
+}
+
+// Middleware resolves the tenant and role name of every request and
+// stores the tenant and the tenant's role in the request context, ready
+// for a Guard. Requests without a tenant are rejected with a 400,
+// requests whose role is unknown to the tenant continue without a role.
+//
+// tenant - returns the tenant of a request, from a header or subdomain for example
+//
+// role - returns the role name of a request, usually from the authenticated user
+//
+// returns - a middleware
+func (t TenantRoles) Middleware(tenant func(r *http.Request) string, role func(r *http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := tenant(r)
+			if id == "" {
+				http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+				return
+			}
+
+			ctx := WithTenant(r.Context(), id)
+			if rl, ok := t.Role(ctx, role(r)); ok {
+				ctx = WithRole(ctx, rl)
+			}
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
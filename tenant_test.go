@@ -0,0 +1,55 @@
+package can
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestTenantRoles(t *testing.T) {
+	f, err := os.OpenFile("testdata/tenants.yml", os.O_RDONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	tr := make(TenantRoles)
+	if err := yaml.NewDecoder(f).Decode(&tr); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := tr.Middleware(
+		func(r *http.Request) string { return r.Header.Get("X-Tenant") },
+		func(r *http.Request) string { return "user" },
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !Check(w, r, "users", Delete) {
+			return
+		}
+		tenant, _ := TenantFrom(r.Context())
+		w.Write([]byte(tenant))
+	}))
+
+	tests := []struct {
+		tenant string
+		status int
+	}{
+		{"acme", http.StatusForbidden},
+		{"globex", http.StatusOK},
+		{"initech", http.StatusUnauthorized},
+		{"", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodDelete, "/users/1", nil)
+		req.Header.Set("X-Tenant", tt.tenant)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != tt.status {
+			t.Fatalf("tenant %q: expected status %d, got %d", tt.tenant, tt.status, w.Code)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package can
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrUnknownVersion is returned by Store.ActivateVersion for versions
+// the Store doesn't keep.
+var ErrUnknownVersion = errors.New("can: unknown policy version")
+
+// PolicyVersion is a set of roles a Store served.
+type PolicyVersion struct {
+	// Version numbers the sets of roles of a Store in the order they
+	// were set, starting at 1.
+	Version uint64 `json:"version"`
+	// Revision identifies the contents of the roles, see Store.Revision.
+	Revision string `json:"revision"`
+	// Time is when the roles were set.
+	Time  time.Time `json:"time"`
+	Roles Roles     `json:"-"`
+}
+
+// Version returns the version of the current set of roles. Guards with
+// a Store tag their decisions with it (see Decision.PolicyVersion).
+func (s *Store) Version() uint64 {
+	return s.load().version
+}
+
+// Versions returns the versions kept by the Store, oldest first. Their
+// roles must not be modified.
+func (s *Store) Versions() []PolicyVersion {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]PolicyVersion(nil), s.versions...)
+}
+
+// ActivateVersion makes a version kept by the Store current again, to
+// roll back a bad change instantly. It doesn't record a new version, so
+// decisions are tagged with the version rolled back to. The next Set,
+// Grant or Revoke records a new version on top of it. Only this Store
+// is rolled back: with a Persister, the backend still holds the roles
+// that were rolled back, Save the version's roles to roll every
+// instance back.
+//
+// v - the version, see Versions
+//
+// returns - ErrUnknownVersion if the version isn't kept
+func (s *Store) ActivateVersion(v uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, pv := range s.versions {
+		if pv.Version == v {
+			s.publish(pv.Roles, pv.Revision, pv.Version)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %d", ErrUnknownVersion, v)
+}
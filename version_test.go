@@ -0,0 +1,51 @@
+package can
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVersions(t *testing.T) {
+	good := Roles{"user": NewRole().Allow("users", Read).Build()}
+	bad := Roles{"user": NewRole().Allow("users", All).Build()}
+
+	var audited Decision
+	s := NewStore(good)
+	s.History = 2
+	g := &Guard{Store: s, Audit: func(ctx context.Context, d Decision) error { audited = d; return nil }}
+	check := func() bool {
+		req := httptest.NewRequest(http.MethodDelete, "/users", nil)
+		req = req.WithContext(WithSubject(req.Context(), Subject{ID: "u1", Roles: []string{"user"}}))
+		return g.Check(httptest.NewRecorder(), req, "users", Delete)
+	}
+
+	s.Set(bad)
+	if !check() || audited.PolicyVersion != 2 {
+		t.Fatalf("expected the bad version to allow, got %+v", audited)
+	}
+
+	s.Set(bad) // unchanged roles don't make a version
+	if v := s.Versions(); len(v) != 2 || v[0].Version != 1 || v[1].Version != 2 {
+		t.Fatalf("unexpected versions %+v", v)
+	}
+
+	if err := s.ActivateVersion(1); err != nil {
+		t.Fatal(err)
+	}
+	if check() || audited.PolicyVersion != 1 || s.Revision() != s.Versions()[0].Revision {
+		t.Fatalf("expected the rollback to deny, got %+v", audited)
+	}
+
+	if err := s.Grant(context.Background(), "user", "books", Read); err != nil {
+		t.Fatal(err)
+	}
+	if v := s.Versions(); len(v) != 2 || v[1].Version != 3 || s.Version() != 3 {
+		t.Fatalf("expected the oldest version to be dropped, got %+v", v)
+	}
+	if err := s.ActivateVersion(1); !errors.Is(err, ErrUnknownVersion) {
+		t.Fatalf("expected ErrUnknownVersion, got %v", err)
+	}
+}
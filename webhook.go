@@ -0,0 +1,202 @@
+package can
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// WebhookRequest is the JSON body a Webhook POSTs to its endpoint.
+type WebhookRequest struct {
+	Subject    *Subject   `json:"subject,omitempty"`
+	Tenant     string     `json:"tenant,omitempty"`
+	Role       Role       `json:"role"`
+	Permission string     `json:"permission"`
+	Ability    Ability    `json:"ability"`
+	Compare    *bool      `json:"compare,omitempty"`
+	Attributes Attributes `json:"attributes,omitempty"`
+	Labels     Labels     `json:"labels,omitempty"`
+	Request    *Request   `json:"request,omitempty"`
+}
+
+// WebhookResponse is the JSON body a Webhook endpoint must reply with.
+type WebhookResponse struct {
+	Allow bool `json:"allow"`
+}
+
+// Webhook is an Authorizer that delegates decisions to a remote
+// authorization service over HTTP. Every check POSTs a WebhookRequest
+// to URL and expects a WebhookResponse with a 200 status.
+//
+// The compare function is evaluated locally and its result is sent
+// along, as are the subject, tenant, attributes, labels and request
+// stored in ctx.
+//
+// Used as the Authorizer of a Guard, can only enforces the decisions of
+// the remote service (policy enforcement point mode). Set Fallback to
+// decide with local roles while the service is unavailable.
+type Webhook struct {
+	// URL is the endpoint receiving authorization requests.
+	URL string
+	// BatchURL is the endpoint receiving batches of authorization
+	// requests (see AuthorizeBatch), as a JSON list of WebhookRequest to
+	// be answered with a list of WebhookResponse in the same order. Empty
+	// means every check of a batch is sent to URL on its own.
+	BatchURL string
+	// Header is added to every request, useful for authentication.
+	Header http.Header
+	// Timeout bounds every request in addition to the ctx deadline. Zero means no timeout.
+	Timeout time.Duration
+	// Fallback decides when the endpoint can't be reached or returns an
+	// invalid response, e.g. Local to use the roles in the request
+	// context. Takes precedence over FailOpen.
+	Fallback Authorizer
+	// FailOpen allows the request when the endpoint can't be reached or
+	// returns an invalid response and there is no Fallback. By default
+	// such requests are denied. Requests whose ctx is done are decided by
+	// can.OnUndecided instead.
+	FailOpen bool
+	// OnError is called with every error from the endpoint, may be nil.
+	OnError func(error)
+	// Client is the http client used for requests. Defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// NewWebhookRequest describes a check for a remote authorization
+// service. It evaluates compare and collects the subject, tenant,
+// attributes, labels and request stored in ctx.
+func NewWebhookRequest(ctx context.Context, role Role, permission string, ability Ability, compare func() bool) WebhookRequest {
+	body := WebhookRequest{
+		Role:       role,
+		Permission: permission,
+		Ability:    ability,
+		Attributes: AttributesFrom(ctx),
+	}
+	if sub, ok := SubjectFrom(ctx); ok {
+		body.Subject = &sub
+	}
+	body.Tenant, _ = TenantFrom(ctx)
+	if labels := LabelsFrom(ctx); len(labels) > 0 {
+		body.Labels = labels
+	}
+	if req, ok := RequestFrom(ctx); ok {
+		body.Request = &req
+	}
+	if compare != nil {
+		result := compare()
+		body.Compare = &result
+	}
+
+	return body
+}
+
+// Authorize implements the Authorizer interface.
+func (wh *Webhook) Authorize(ctx context.Context, role Role, permission string, ability Ability, compare func() bool) bool {
+	return wh.AuthorizeOutcome(ctx, role, permission, ability, compare).Allow()
+}
+
+// AuthorizeOutcome implements the OutcomeAuthorizer interface, checks
+// whose request was cut off by ctx are Undecided.
+func (wh *Webhook) AuthorizeOutcome(ctx context.Context, role Role, permission string, ability Ability, compare func() bool) Outcome {
+	body := NewWebhookRequest(ctx, role, permission, ability, compare)
+
+	var resp WebhookResponse
+	if err := wh.do(ctx, wh.URL, body, &resp); err != nil {
+		if wh.OnError != nil {
+			wh.OnError(err)
+		}
+		if ctx.Err() != nil {
+			// the caller gave up, neither the fallback nor FailOpen decide
+			return Undecided
+		}
+		if wh.Fallback != nil {
+			return Decide(ctx, wh.Fallback, role, permission, ability, compare)
+		}
+		return outcome(wh.FailOpen)
+	}
+
+	return outcome(resp.Allow)
+}
+
+// do sends body to url and decodes the response into out.
+func (wh *Webhook) do(ctx context.Context, url string, body, out any) error {
+	if wh.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, wh.Timeout)
+		defer cancel()
+	}
+
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	for k, v := range wh.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient(wh.Client).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("can: webhook returned %s", resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// AuthorizeBatch implements the BatchAuthorizer interface, deciding all
+// checks in a single request to BatchURL.
+func (wh *Webhook) AuthorizeBatch(ctx context.Context, role Role, checks []BatchCheck) []bool {
+	if wh.BatchURL == "" {
+		return authorizeEach(ctx, wh, role, checks)
+	}
+
+	body := make([]WebhookRequest, len(checks))
+	for i, c := range checks {
+		body[i] = NewWebhookRequest(ctx, role, c.Permission, c.Ability, c.Compare)
+	}
+
+	var resp []WebhookResponse
+	err := wh.do(ctx, wh.BatchURL, body, &resp)
+	if err == nil && len(resp) != len(checks) {
+		err = fmt.Errorf("can: webhook returned %d decisions for %d checks", len(resp), len(checks))
+	}
+	if err != nil {
+		if wh.OnError != nil {
+			wh.OnError(err)
+		}
+		allowed := make([]bool, len(checks))
+		switch {
+		case ctx.Err() != nil:
+			for i := range allowed {
+				allowed[i] = undecided()
+			}
+		case wh.Fallback != nil:
+			allowed = authorizeEach(ctx, wh.Fallback, role, checks)
+		default:
+			for i := range allowed {
+				allowed[i] = wh.FailOpen
+			}
+		}
+		return allowed
+	}
+
+	allowed := make([]bool, len(checks))
+	for i, r := range resp {
+		allowed[i] = r.Allow
+	}
+
+	return allowed
+}
@@ -0,0 +1,121 @@
+package can
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWebhook(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req WebhookRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		switch req.Permission {
+		case "slow":
+			time.Sleep(100 * time.Millisecond)
+		case "broken":
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		_, hasUsers := req.Role["users"]
+		allow := hasUsers && req.Ability == Read && req.Compare != nil && *req.Compare && req.Attributes["ip"] == "10.0.0.1" && req.Subject != nil && req.Subject.ID == "u1" && req.Tenant == "acme"
+		json.NewEncoder(w).Encode(WebhookResponse{Allow: allow})
+	}))
+	defer srv.Close()
+
+	r, err := OpenFile("testdata/rbac.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := WithAttributes(context.Background(), Attributes{"ip": "10.0.0.1"})
+	ctx = WithTenant(WithSubject(ctx, Subject{ID: "u1"}), "acme")
+	wh := &Webhook{URL: srv.URL}
+
+	if !wh.Authorize(ctx, r["user"], "users", Read, Compare(1, 1)) {
+		t.Fatal("expected webhook to allow")
+	}
+
+	if wh.Authorize(ctx, r["user"], "users", Read, Compare(1, 2)) {
+		t.Fatal("expected webhook to deny on compare")
+	}
+
+	if wh.Authorize(ctx, r["user"], "broken", Read, nil) {
+		t.Fatal("expected fail closed on error")
+	}
+
+	wh.FailOpen = true
+	if !wh.Authorize(ctx, r["user"], "broken", Read, nil) {
+		t.Fatal("expected fail open on error")
+	}
+
+	var errs int
+	wh = &Webhook{URL: srv.URL, OnError: func(error) { errs++ }}
+	deadline, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if wh.Authorize(deadline, r["user"], "slow", Read, nil) || errs != 1 {
+		t.Fatal("expected ctx deadline to deny")
+	}
+}
+
+func TestWebhookEnforcement(t *testing.T) {
+	var received WebhookRequest
+	up := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !up {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&received)
+		json.NewEncoder(w).Encode(WebhookResponse{Allow: received.Subject != nil && received.Subject.ID == "u1"})
+	}))
+	defer srv.Close()
+
+	r, err := OpenFile("testdata/rbac.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := &Guard{
+		Store:      NewStore(r),
+		Authorizer: &Webhook{URL: srv.URL, Fallback: Local},
+		Labels:     Labels{EnvironmentLabel: "production"},
+	}
+
+	// the subject's roles are unknown locally, the remote service decides
+	req := httptest.NewRequest(http.MethodDelete, "/books", nil)
+	req = req.WithContext(WithSubject(req.Context(), Subject{ID: "u1", Roles: []string{"editor"}}))
+	if !g.Check(httptest.NewRecorder(), req, "books", Delete) {
+		t.Fatal("expected the remote decision to be enforced")
+	}
+	if received.Request == nil || received.Request.Method != http.MethodDelete || received.Request.Path != "/books" {
+		t.Fatalf("expected the request to be forwarded, got %+v", received.Request)
+	}
+	if received.Labels[EnvironmentLabel] != "production" {
+		t.Fatalf("expected labels to be forwarded, got %v", received.Labels)
+	}
+
+	// while the remote service is down, local roles decide
+	up = false
+	req = req.WithContext(WithSubject(req.Context(), Subject{ID: "u1", Roles: []string{"user"}}))
+	if g.Check(httptest.NewRecorder(), req, "books", Delete) {
+		t.Fatal("expected the fallback to deny")
+	}
+	if !g.Check(httptest.NewRecorder(), req, "books", Read) {
+		t.Fatal("expected the fallback to allow")
+	}
+
+	w := httptest.NewRecorder()
+	req = req.WithContext(WithSubject(req.Context(), Subject{ID: "u1", Roles: []string{"editor"}}))
+	if g.Check(w, req, "books", Read) || w.Code != http.StatusForbidden {
+		t.Fatalf("expected a subject without local roles to be forbidden, got %d", w.Code)
+	}
+}